@@ -6,9 +6,11 @@ require (
 	github.com/foize/go.fifo v0.0.0-20130327144150-3a04cfeec121
 	github.com/fsnotify/fsnotify v1.6.0
 	github.com/gorilla/websocket v1.5.0
+	github.com/klauspost/compress v1.16.7
 	github.com/sacOO7/go-logger v0.0.0-20180719173527-9ac9add5a50d
 	github.com/sacOO7/gowebsocket v0.0.0-20221109081133-70ac927be105
 	github.com/stretchr/testify v1.8.1
+	github.com/ulikunitz/xz v0.5.11
 	gopkg.in/yaml.v3 v3.0.1
 )
 
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ansiMode tells what to do with the ANSI escape codes of the log lines
+type ansiMode string
+
+const (
+	// ansiRender turns the SGR sequences into styles
+	ansiRender ansiMode = "render"
+	// ansiStrip removes the escape codes
+	ansiStrip ansiMode = "strip"
+	// ansiRaw leaves the escape codes as they are
+	ansiRaw ansiMode = "raw"
+)
+
+// ansiBareCSIRegexp matches the sequences whose escape char has been lost, which are only recognized when they are SGR ones
+var ansiBareCSIRegexp = regexp.MustCompile(`^\[[0-9;]*m$`)
+
+// ansiColors are the CSS colors of the 16 standard ANSI colors, the 8 bright ones being the last
+var ansiColors = [16]string{
+	"#000000", "#cd3131", "#0dbc79", "#e5e510", "#2472c8", "#bc3fbc", "#11a8cd", "#e5e5e5",
+	"#666666", "#f14c4c", "#23d18b", "#f5f543", "#3b8eea", "#d670d6", "#29b8db", "#ffffff",
+}
+
+// isAnsiSequence returns whether the given CSI-like sequence is really an escape code, and not e.g. "[INFO"
+func isAnsiSequence(sequence string) bool {
+	return sequence[0] == '\x1b' || ansiBareCSIRegexp.MatchString(sequence)
+}
+
+// applySGR updates the style with the given SGR parameters, like "1;38;5;208"
+func (state *textStyle) applySGR(params string) {
+	codes := strings.FieldsFunc(params, func(r rune) bool { return r == ';' || r == ':' })
+	if len(codes) == 0 {
+		codes = []string{"0"}
+	}
+	for i := 0; i < len(codes); i++ {
+		code, err := strconv.Atoi(codes[i])
+		if err != nil {
+			continue
+		}
+		switch {
+		case code == 0:
+			*state = textStyle{}
+		case code == 1:
+			state.bold = true
+		case code == 2:
+			state.faint = true
+		case code == 3:
+			state.italic = true
+		case code == 4:
+			state.underline = true
+		case code == 9:
+			state.strikethrough = true
+		case code == 22:
+			state.bold, state.faint = false, false
+		case code == 23:
+			state.italic = false
+		case code == 24:
+			state.underline = false
+		case code == 29:
+			state.strikethrough = false
+		case code >= 30 && code <= 37:
+			state.setForeground(ansiColors[code-30])
+		case code >= 90 && code <= 97:
+			state.setForeground(ansiColors[code-90+8])
+		case code == 39:
+			state.setForeground("")
+		case code >= 40 && code <= 47:
+			state.background = ansiColors[code-40]
+		case code >= 100 && code <= 107:
+			state.background = ansiColors[code-100+8]
+		case code == 49:
+			state.background = ""
+		case code == 38 || code == 48:
+			color, consumed := parseExtendedColor(codes[i+1:])
+			i += consumed
+			if code == 38 {
+				state.setForeground(color)
+			} else {
+				state.background = color
+			}
+		}
+	}
+}
+
+// parseExtendedColor parses the parameters following a 38 or 48 code, which are either 5;n or 2;r;g;b,
+// and returns the CSS color with the number of parameters used
+func parseExtendedColor(params []string) (color string, consumed int) {
+	if len(params) == 0 {
+		return "", 0
+	}
+	values := make([]int, 0, 4)
+	for _, param := range params {
+		value, err := strconv.Atoi(param)
+		if err != nil || value < 0 || value > 255 {
+			break
+		}
+		values = append(values, value)
+	}
+	switch {
+	case len(values) >= 2 && values[0] == 5:
+		return ansi256Color(values[1]), 2
+	case len(values) >= 4 && values[0] == 2:
+		return fmt.Sprintf("#%02x%02x%02x", values[1], values[2], values[3]), 4
+	}
+	return "", len(values)
+}
+
+// ansi256Color returns the CSS color of the given color of the 256 colors palette
+func ansi256Color(index int) string {
+	switch {
+	case index < 16:
+		return ansiColors[index]
+	case index < 232:
+		index -= 16
+		levels := [6]int{0, 95, 135, 175, 215, 255}
+		return fmt.Sprintf("#%02x%02x%02x", levels[index/36], levels[index/6%6], levels[index%6])
+	default:
+		gray := 8 + (index-232)*10
+		return fmt.Sprintf("#%02x%02x%02x", gray, gray, gray)
+	}
+}
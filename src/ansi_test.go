@@ -0,0 +1,48 @@
+package main
+
+import (
+	"html/template"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAnsi(t *testing.T) {
+	highlighter := &lineHighlighter{ansi: ansiRender}
+	text, runs := highlighter.parseFormatting("\x1b[0;31mred\x1b[0m plain \x1b[1;4;38;5;208mbold\x1b[22m\x1b[48;2;1;2;3mtrue\x1b[K", true)
+	assert.Equal(t, "red plain boldtrue", text)
+	assert.Equal(t, []styledRun{
+		{start: 0, style: "color: #cd3131;"},
+		{start: 3, style: ""},
+		{start: 10, style: "color: #ff8700; font-weight: bold; text-decoration: underline;"},
+		{start: 14, style: "color: #ff8700; background-color: #010203; text-decoration: underline;"},
+	}, runs)
+
+	text, runs = highlighter.parseFormatting("[0;93m[12:34:56] [Server thread/INFO][m: done", true)
+	assert.Equal(t, "[12:34:56] [Server thread/INFO]: done", text, "Codes without their escape char should be recognized")
+	assert.Equal(t, []styledRun{{start: 0, style: "color: #f5f543;"}, {start: 31, style: ""}}, runs)
+
+	text, runs = highlighter.parseFormatting("[INFO] no codes [42] [Main]", true)
+	assert.Equal(t, "[INFO] no codes [42] [Main]", text)
+	assert.Empty(t, runs)
+
+	assert.Equal(t, "#808080", ansi256Color(244))
+	assert.Equal(t, "#5fd7ff", ansi256Color(81))
+}
+
+func TestHighlightAnsi(t *testing.T) {
+	highlighter, _ := compileSyntaxHighlighting("serv", SyntaxHighlightingConfig{
+		{Field: "time", Regex: `^\[\d{2}:\d{2}:\d{2}]`},
+	}, nil)
+	const line = "\x1b[32m[12:34:56] ok\x1b[0m <b>"
+
+	highlighter.ansi = ansiRender
+	assert.Equal(t, template.HTML(`<span data-field="time"><span style="color: #0dbc79;">[12:34:56]</span></span>`+
+		`<span style="color: #0dbc79;"> ok</span> &lt;b&gt;`), highlighter.highlight(line))
+
+	highlighter.ansi = ansiStrip
+	assert.Equal(t, template.HTML(`<span data-field="time">[12:34:56]</span> ok &lt;b&gt;`), highlighter.highlight(line))
+
+	highlighter.ansi = ansiRaw
+	assert.Equal(t, template.HTML("\x1b[32m[12:34:56] ok\x1b[0m &lt;b&gt;"), highlighter.highlight(line), "The rules should not match the codes")
+}
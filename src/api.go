@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiLogEvent is a log event sent by the REST API
+type apiLogEvent struct {
+	// The lines of the event, separated by new lines
+	Content string `json:"content"`
+	Level   string `json:"level,omitempty"`
+	// The time of the event, omitted if it has none
+	Time *time.Time `json:"time,omitempty"`
+	// The fields of the event by key, omitted if it has none
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+// apiLogs is the response of the logs endpoint of the REST API
+type apiLogs struct {
+	Server string        `json:"server"`
+	Events []apiLogEvent `json:"events"`
+	// The number of events of every level, omitted if the server has no level rule
+	Levels map[string]int `json:"levels,omitempty"`
+}
+
+// apiLogsHandler sends the latest log events of a server as JSON on /api/logs/{server},
+// the server having the format used by the websocket (server or server=>instance).
+// The n query param sets the number of lines to read, like the max lines count of the web interface,
+// and the q query param filters their events with the query language
+func apiLogsHandler(w http.ResponseWriter, r *http.Request, config Config) {
+	server := strings.TrimPrefix(r.URL.Path, "/api/logs/")
+	logFilePath, parser, found := getLogFilePathOf(config, server)
+	if !found {
+		prettier(w, "Unknown server: "+server, nil, http.StatusNotFound)
+		return
+	}
+
+	linesCount := defaultMaxLinesCount
+	if n := r.URL.Query().Get("n"); n != "" {
+		var err error
+		linesCount, err = strconv.Atoi(n)
+		if err != nil || linesCount < 0 {
+			prettier(w, "Invalid lines count: "+n, nil, http.StatusBadRequest)
+			return
+		}
+	}
+
+	query, err := extractQuery(r)
+	if err != nil {
+		prettier(w, "Invalid query: "+err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	response := apiLogs{Server: server, Events: []apiLogEvent{}}
+	var levels []string
+	events := parser.group(getServerLogs(logFilePath, linesCount))
+	times := parser.eventTimes(events, getLogFileReferenceTime(logFilePath))
+	for i, lines := range events {
+		event := parser.newEvent(server, lines)
+		apiEvent := apiLogEvent{Content: event.Content, Level: event.Level, Fields: event.Fields}
+		if !times[i].IsZero() {
+			apiEvent.Time = &times[i]
+		}
+		if query != nil && !query.matches(logRecord{text: event.Content, level: event.Level, time: times[i], fields: event.Fields}) {
+			continue
+		}
+		response.Events = append(response.Events, apiEvent)
+		levels = append(levels, event.Level)
+	}
+	if parser.hasLevels() {
+		response.Levels = make(map[string]int)
+		for _, count := range countLevels(levels) {
+			response.Levels[count.Level] = count.Count
+		}
+	}
+	prettier(w, "Logs of server "+server, response, http.StatusOK)
+}
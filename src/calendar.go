@@ -0,0 +1,177 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// calendarMonthLayout is the layout of the months of the calendar in the urls
+const calendarMonthLayout = "2006-01"
+
+// calendarDay is a day of the calendar of the archives
+type calendarDay struct {
+	// The day of the month, 0 for the days of the other months completing the first and last weeks
+	Day  int
+	Date string
+	// The archives of the day, which are its parts when there are several
+	Archives []catalogEntry
+}
+
+// archiveCalendar holds the archives of a server by day
+type archiveCalendar struct {
+	// The archives of every day, ordered by part, by date like 2026-10-17
+	days map[string][]catalogEntry
+	// The months having archives, like 2026-10, in ascending order
+	months []string
+}
+
+// newArchiveCalendar returns the calendar of the given archives, whose days are found in their names,
+// or are the days of their modification if they have none
+func newArchiveCalendar(archives []catalogEntry) *archiveCalendar {
+	calendar := &archiveCalendar{days: make(map[string][]catalogEntry)}
+	months := make(map[string]bool)
+	for _, archive := range archives {
+		date := archive.Date
+		if date == "" {
+			date = archive.ModTime.In(time.Local).Format(searchDateLayout)
+		}
+		calendar.days[date] = append(calendar.days[date], archive)
+		if month := date[:len(calendarMonthLayout)]; !months[month] {
+			months[month] = true
+			calendar.months = append(calendar.months, month)
+		}
+	}
+	// the parts of a day are numbered in their names, like 2026-10-17-2.log.gz before 2026-10-17-10.log.gz
+	for _, parts := range calendar.days {
+		sort.SliceStable(parts, func(i, j int) bool {
+			if len(parts[i].Name) != len(parts[j].Name) {
+				return len(parts[i].Name) < len(parts[j].Name)
+			}
+			return parts[i].Name < parts[j].Name
+		})
+	}
+	sort.Strings(calendar.months)
+	return calendar
+}
+
+// weeks returns the days of the given month, from Monday to Sunday
+func (calendar *archiveCalendar) weeks(month time.Time) [][]calendarDay {
+	first := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.Local)
+	// the days of the previous month completing the first week, which starts on Monday
+	padding := (int(first.Weekday()) + 6) % 7
+	var weeks [][]calendarDay
+	week := make([]calendarDay, padding, 7)
+	for day := first; day.Month() == first.Month(); day = day.AddDate(0, 0, 1) {
+		date := day.Format(searchDateLayout)
+		week = append(week, calendarDay{Day: day.Day(), Date: date, Archives: calendar.days[date]})
+		if len(week) == 7 {
+			weeks = append(weeks, week)
+			week = make([]calendarDay, 0, 7)
+		}
+	}
+	if len(week) > 0 {
+		weeks = append(weeks, week[:7])
+	}
+	return weeks
+}
+
+// neighbours returns the closest months having archives before and after the given one, empty if there are none
+func (calendar *archiveCalendar) neighbours(month string) (previous, next string) {
+	i := sort.SearchStrings(calendar.months, month)
+	if i > 0 {
+		previous = calendar.months[i-1]
+	}
+	if i < len(calendar.months) && calendar.months[i] == month {
+		i++
+	}
+	if i < len(calendar.months) {
+		next = calendar.months[i]
+	}
+	return previous, next
+}
+
+// CalendarWebData contains the data of the archives calendar page
+type CalendarWebData struct {
+	Server            string
+	Instance          string
+	ServerDisplayName string
+	// The month shown, like October 2026
+	Month string
+	// The closest months having archives, like 2026-09, empty if there are none
+	PreviousMonth, NextMonth string
+	Weeks                    [][]calendarDay
+	// The number of archives of the month
+	ArchivesCount int
+}
+
+// calendarHandler serves the calendar of the archives of a server on /calendar/{server}, which shows the days having archives
+// and links to them. The month query param selects the month, like 2026-10, the one of the newest archive by default.
+func calendarHandler(w http.ResponseWriter, r *http.Request, templateCommonData CommonWebData, config Config) {
+	server := strings.TrimPrefix(r.URL.Path, "/calendar/")
+	instance := r.URL.Query().Get("instance")
+	archives, found, err := getServerArchives(config, server, instance)
+	if !found || errors.Is(err, errArchivesDisabled) || errors.Is(err, errInstanceRequired) {
+		http.Redirect(w, r, config.UrlPrefix+"/", http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		handleTemplateError(w, http.StatusInternalServerError, err)
+		return
+	}
+	page, err := archives.catalog.page(catalogQuery{sort: "date", page: 1, pageSize: math.MaxInt32},
+		func(id string) string { return archives.url + id })
+	if err != nil {
+		handleTemplateError(w, http.StatusInternalServerError, err)
+		return
+	}
+	calendar := newArchiveCalendar(page.Archives)
+
+	month, err := time.ParseInLocation(calendarMonthLayout, r.URL.Query().Get("month"), time.Local)
+	if err != nil {
+		month = time.Now()
+		if len(calendar.months) > 0 {
+			month, _ = time.ParseInLocation(calendarMonthLayout, calendar.months[len(calendar.months)-1], time.Local)
+		}
+	}
+
+	// the page has the navbar of the index, as it is not about the logs of a single server
+	funcMap := getFuncMapFor("", true, false, false)
+	funcMap["partNumber"] = func(i int) int { return i + 1 }
+	tmpl, err := parseTemplates(funcMap, "calendar", "navbar")
+	if err != nil {
+		handleTemplateError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	data := CalendarWebData{
+		Server:            server,
+		Instance:          instance,
+		ServerDisplayName: archives.displayName,
+		Month:             month.Format("January 2006"),
+		Weeks:             calendar.weeks(month),
+	}
+	data.PreviousMonth, data.NextMonth = calendar.neighbours(month.Format(calendarMonthLayout))
+	for _, week := range data.Weeks {
+		for _, day := range week {
+			data.ArchivesCount += len(day.Archives)
+		}
+	}
+
+	templateCommonData.ExecDate = time.Now().Format("15:04:05")
+	err = tmpl.Execute(w, struct {
+		CommonWebData
+		CalendarWebData
+	}{
+		CommonWebData:   templateCommonData,
+		CalendarWebData: data,
+	})
+	if doDebug {
+		if err != nil {
+			printError(err)
+		}
+	}
+}
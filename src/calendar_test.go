@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArchiveCalendar(t *testing.T) {
+	calendar := newArchiveCalendar([]catalogEntry{
+		{Name: "2026-08-31-1.log.gz", Date: "2026-08-31"},
+		{Name: "2026-10-17-10.log.gz", Date: "2026-10-17"},
+		{Name: "2026-10-17-2.log.gz", Date: "2026-10-17"},
+		{Name: "syslog.1", ModTime: time.Date(2026, time.December, 1, 12, 0, 0, 0, time.Local)},
+	})
+	assert.Equal(t, []string{"2026-08", "2026-10", "2026-12"}, calendar.months, "The archives without date should be in the month of their modification")
+
+	weeks := calendar.weeks(time.Date(2026, time.October, 1, 0, 0, 0, 0, time.Local))
+	if assert.Len(t, weeks, 5) {
+		assert.Equal(t, 0, weeks[0][2].Day, "October 2026 starts on a Thursday")
+		assert.Equal(t, 1, weeks[0][3].Day)
+		assert.Equal(t, "2026-10-17", weeks[2][5].Date)
+		if assert.Len(t, weeks[2][5].Archives, 2, "The parts of a day should be together") {
+			assert.Equal(t, "2026-10-17-2.log.gz", weeks[2][5].Archives[0].Name, "The parts should be ordered by number")
+		}
+		assert.Equal(t, 31, weeks[4][5].Day)
+		assert.Len(t, weeks[4], 7, "The last week should be completed")
+	}
+
+	previous, next := calendar.neighbours("2026-10")
+	assert.Equal(t, "2026-08", previous)
+	assert.Equal(t, "2026-12", next)
+	previous, next = calendar.neighbours("2026-09")
+	assert.Equal(t, "2026-08", previous, "The months without archives should have neighbours too")
+	assert.Equal(t, "2026-10", next)
+	previous, next = calendar.neighbours("2026-08")
+	assert.Empty(t, previous)
+	assert.Equal(t, "2026-10", next)
+}
@@ -0,0 +1,730 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	defaultCatalogPageSize = 50
+	maxCatalogPageSize     = 500
+	// The duration after which the archives are listed again even if their directory has not changed,
+	// as the changes of network filesystems are not always notified
+	catalogMaxAge = time.Minute
+)
+
+// catalogSorts are the orders of the archives of the catalog, by the name of the sort param
+var catalogSorts = map[string]func(a, b *catalogEntry) bool{
+	"name":            func(a, b *catalogEntry) bool { return a.Name < b.Name },
+	"date":            func(a, b *catalogEntry) bool { return a.ModTime.Before(b.ModTime) },
+	"size":            func(a, b *catalogEntry) bool { return a.Size < b.Size },
+	"compressed-size": func(a, b *catalogEntry) bool { return a.CompressedSize < b.CompressedSize },
+	"lines":           func(a, b *catalogEntry) bool { return a.Lines < b.Lines },
+}
+
+// archiveDetails are the details of an archive that can only be known by reading it
+type archiveDetails struct {
+	// The size and modification time of the archive when it was read, which must not have changed for the details to be valid
+	size    int64
+	modTime time.Time
+
+	uncompressedSize int64
+	lines            int
+	// The times of the first and last events of the archive, zero if they have none
+	firstTime, lastTime time.Time
+}
+
+// isValidFor returns whether the details were read from the given archive as it is now
+func (details archiveDetails) isValidFor(entry archiveEntry) bool {
+	return details.size == entry.size && details.modTime.Equal(entry.modTime)
+}
+
+// archiveCatalog is the list of the archives of a directory, which is cached and listed again only when the directory changes.
+// The details of the archives are read in the background. It can be safely used by several goroutines.
+type archiveCatalog struct {
+	dir, pattern string
+	// The parser of the server, used to find the times of the events of the archives
+	parser *logParser
+
+	mutex sync.Mutex
+	// The archives, from the newest to the oldest
+	entries  []archiveEntry
+	listedAt time.Time
+	// Whether the directory has changed since the archives were listed
+	stale bool
+	// The watcher notifying the catalog of the changes of the directories of its archives, nil if they are not watched
+	watcher *archivesWatcher
+	watched map[string]bool
+	// The details of the archives, by full name
+	details map[string]archiveDetails
+	// Whether the details of the archives are being read
+	reading bool
+}
+
+// archiveCatalogs holds the catalogs of all the archives directories, by directory and pattern
+type archiveCatalogs struct {
+	mutex    sync.Mutex
+	catalogs map[string]*archiveCatalog
+}
+
+// errInstanceRequired is returned when the archives of a dynamic server are requested without an instance
+var errInstanceRequired = errors.New("the instance of the dynamic server is required")
+
+// catalogs is the cache of the archives of every server, and of every instance of the dynamic servers
+var catalogs = &archiveCatalogs{catalogs: make(map[string]*archiveCatalog)}
+
+// get returns the catalog of the given archives directory, which is created and starts being watched if it does not exist yet
+func (catalogs *archiveCatalogs) get(dir, pattern string, parser *logParser) *archiveCatalog {
+	key := dir + "\x00" + pattern
+	catalogs.mutex.Lock()
+	defer catalogs.mutex.Unlock()
+	catalog, found := catalogs.catalogs[key]
+	if !found {
+		catalog = newArchiveCatalog(dir, pattern, parser)
+		catalogs.catalogs[key] = catalog
+	}
+	return catalog
+}
+
+// newArchiveCatalog returns the catalog of the given archives directory, whose changes are watched when possible
+func newArchiveCatalog(dir, pattern string, parser *logParser) *archiveCatalog {
+	return &archiveCatalog{
+		dir:     dir,
+		pattern: pattern,
+		parser:  parser,
+		stale:   true,
+		watcher: catalogsWatcher,
+		watched: make(map[string]bool),
+		details: make(map[string]archiveDetails),
+	}
+}
+
+// invalidate makes the archives be listed again on the next request
+func (catalog *archiveCatalog) invalidate() {
+	catalog.mutex.Lock()
+	catalog.stale = true
+	catalog.mutex.Unlock()
+}
+
+// unwatch makes the given directory be watched again once the archives are listed, as it has been removed or renamed
+func (catalog *archiveCatalog) unwatch(dir string) {
+	catalog.mutex.Lock()
+	delete(catalog.watched, dir)
+	catalog.stale = true
+	catalog.mutex.Unlock()
+}
+
+// archivesWatcher watches the directories of the archives of every catalog with a single watcher, as the number of
+// watchers is limited per user (fs.inotify.max_user_instances on Linux), and notifies the catalogs of their changes.
+// It can be safely used by several goroutines.
+type archivesWatcher struct {
+	mutex sync.Mutex
+	// The watcher, created on the first directory to watch. It is nil if it could not be created.
+	watcher *fsnotify.Watcher
+	err     error
+	// The catalogs to notify of the changes of every watched directory
+	catalogs map[string][]*archiveCatalog
+}
+
+// catalogsWatcher is the watcher of the directories of the archives of all the catalogs
+var catalogsWatcher = &archivesWatcher{catalogs: make(map[string][]*archiveCatalog)}
+
+// watch makes the given catalog be notified of the changes of the given directory
+func (watcher *archivesWatcher) watch(dir string, catalog *archiveCatalog) error {
+	watcher.mutex.Lock()
+	defer watcher.mutex.Unlock()
+	if watcher.watcher == nil && watcher.err == nil {
+		watcher.watcher, watcher.err = fsnotify.NewWatcher()
+		if watcher.err != nil {
+			printError(fmt.Errorf("failed to watch the archives directories, they will be listed on every request: %w", watcher.err))
+		} else {
+			go watcher.dispatch(watcher.watcher)
+		}
+	}
+	if watcher.err != nil {
+		return watcher.err
+	}
+
+	catalogs, watched := watcher.catalogs[dir]
+	if !watched {
+		if err := watcher.watcher.Add(dir); err != nil {
+			return err
+		}
+	}
+	for _, other := range catalogs {
+		if other == catalog {
+			return nil
+		}
+	}
+	watcher.catalogs[dir] = append(catalogs, catalog)
+	return nil
+}
+
+// dispatch invalidates the catalogs of the directories changed according to the events of the given watcher
+func (watcher *archivesWatcher) dispatch(fsWatcher *fsnotify.Watcher) {
+	for {
+		select {
+		case event, ok := <-fsWatcher.Events:
+			if !ok {
+				return
+			}
+			// the event is about a file of a watched directory, or about the directory itself
+			dir := filepath.Dir(event.Name)
+			watcher.mutex.Lock()
+			catalogs := watcher.catalogs[dir]
+			removed, isDir := watcher.catalogs[event.Name]
+			if isDir && event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+				// a renamed directory is still watched under its new name, and a recreated one is not watched anymore
+				_ = fsWatcher.Remove(event.Name)
+				delete(watcher.catalogs, event.Name)
+			} else {
+				removed = nil
+			}
+			watcher.mutex.Unlock()
+
+			for _, catalog := range catalogs {
+				catalog.invalidate()
+			}
+			for _, catalog := range removed {
+				catalog.unwatch(event.Name)
+			}
+		case err, ok := <-fsWatcher.Errors:
+			if !ok {
+				return
+			}
+			// some events may have been missed
+			debugPrint("Archives watcher error: " + err.Error())
+			watcher.mutex.Lock()
+			var all []*archiveCatalog
+			for _, catalogs := range watcher.catalogs {
+				all = append(all, catalogs...)
+			}
+			watcher.mutex.Unlock()
+			for _, catalog := range all {
+				catalog.invalidate()
+			}
+		}
+	}
+}
+
+// list returns the archives of the directory, from the newest to the oldest
+func (catalog *archiveCatalog) list() ([]archiveEntry, error) {
+	catalog.mutex.Lock()
+	defer catalog.mutex.Unlock()
+	if catalog.stale || time.Since(catalog.listedAt) > catalogMaxAge {
+		entries, err := listArchivedLogFiles(catalog.dir, catalog.pattern)
+		if err != nil {
+			return nil, err
+		}
+		catalog.entries, catalog.listedAt, catalog.stale = entries, time.Now(), false
+		catalog.watchDirectories()
+		catalog.readDetailsInBackground()
+	}
+	return catalog.entries, nil
+}
+
+// find returns the archive of the catalog with the given identifier. The archives which are not listed by the catalog,
+// like the files outside its directory or not matching its pattern, are never found.
+func (catalog *archiveCatalog) find(id string) (archiveEntry, bool, error) {
+	entries, err := catalog.list()
+	if err != nil {
+		return archiveEntry{}, false, err
+	}
+	for _, entry := range entries {
+		if entry.Id == id {
+			return entry, true, nil
+		}
+	}
+	return archiveEntry{}, false, nil
+}
+
+// watchDirectories watches the directory of the catalog and the ones holding its archives, if they are not watched yet.
+// The mutex of the catalog must be held.
+func (catalog *archiveCatalog) watchDirectories() {
+	if catalog.watcher == nil {
+		return
+	}
+	// the directories are named like in the events of the watcher
+	dirs := []string{filepath.Clean(catalog.dir)}
+	for _, entry := range catalog.entries {
+		// the members of the bundles are watched with their bundles
+		dirs = append(dirs, filepath.Dir(catalog.locationOf(entry).path))
+	}
+	for _, dir := range dirs {
+		if catalog.watched[dir] {
+			continue
+		}
+		if err := catalog.watcher.watch(dir, catalog); err != nil {
+			// the directory may not exist yet, it is listed again on every request meanwhile
+			debugPrint("Failed to watch archives directory " + dir + ": " + err.Error())
+			catalog.stale = true
+			continue
+		}
+		catalog.watched[dir] = true
+	}
+}
+
+// locationOf returns the location of the given archive of the catalog
+func (catalog *archiveCatalog) locationOf(entry archiveEntry) archiveLocation {
+	return archiveLocation{path: filepath.Join(catalog.dir, entry.relativePath), member: entry.member}
+}
+
+// missingDetails returns the archives whose details are not known or are outdated, from the newest to the oldest.
+// The details of the archives which do not exist anymore are forgotten. The mutex of the catalog must be held.
+func (catalog *archiveCatalog) missingDetails() []archiveEntry {
+	var missing []archiveEntry
+	current := make(map[string]bool, len(catalog.entries))
+	for _, entry := range catalog.entries {
+		current[entry.Id] = true
+		if details, found := catalog.details[entry.Id]; !found || !details.isValidFor(entry) {
+			missing = append(missing, entry)
+		}
+	}
+	for id := range catalog.details {
+		if !current[id] {
+			delete(catalog.details, id)
+		}
+	}
+	return missing
+}
+
+// readDetailsInBackground reads the missing details of the archives in a new goroutine, unless they are already being read.
+// The mutex of the catalog must be held.
+func (catalog *archiveCatalog) readDetailsInBackground() {
+	if catalog.reading {
+		return
+	}
+	missing := catalog.missingDetails()
+	if len(missing) == 0 {
+		return
+	}
+	catalog.reading = true
+	go func() {
+		for len(missing) > 0 {
+			for _, entry := range missing {
+				details, err := readArchiveDetails(catalog.locationOf(entry), catalog.parser)
+				if err != nil {
+					debugPrint("Failed to read the details of archive " + entry.Name + ": " + err.Error())
+				}
+				// the archive is not read again until it changes, even if it failed
+				details.size, details.modTime = entry.size, entry.modTime
+				catalog.mutex.Lock()
+				catalog.details[entry.Id] = details
+				catalog.mutex.Unlock()
+			}
+			// the archives may have changed meanwhile
+			catalog.mutex.Lock()
+			missing = catalog.missingDetails()
+			if len(missing) == 0 {
+				catalog.reading = false
+			}
+			catalog.mutex.Unlock()
+		}
+	}()
+}
+
+// knownDetailsOf returns the details of the given archive of the catalog, or false if they have not been read yet,
+// which is done in the background once the archives are listed
+func (catalog *archiveCatalog) knownDetailsOf(entry archiveEntry) (archiveDetails, bool) {
+	catalog.mutex.Lock()
+	defer catalog.mutex.Unlock()
+	details, found := catalog.details[entry.Id]
+	return details, found && details.isValidFor(entry)
+}
+
+// readArchiveDetails reads the given archive to count its lines and find the times of its first and last events
+func readArchiveDetails(location archiveLocation, parser *logParser) (archiveDetails, error) {
+	content, err := uncompress(location)
+	if err != nil {
+		return archiveDetails{}, err
+	}
+	lines := splitLogLines(content)
+	details := archiveDetails{uncompressedSize: int64(len(content)), lines: len(lines)}
+	if !parser.hasTimestamps() {
+		return details, nil
+	}
+	reference := parser.archiveReferenceTime(location)
+	for _, line := range lines {
+		if details.firstTime = parser.extractTime(line, reference); !details.firstTime.IsZero() {
+			break
+		}
+	}
+	for i := len(lines) - 1; i >= 0; i-- {
+		if details.lastTime = parser.extractTime(lines[i], reference); !details.lastTime.IsZero() {
+			break
+		}
+	}
+	return details, nil
+}
+
+// catalogEntry is an archive of the catalog, as served by the API
+type catalogEntry struct {
+	Name string `json:"name"`
+	// The identifier of the archive, as found in the urls of the viewer
+	Id             string    `json:"id"`
+	ModTime        time.Time `json:"mod-time"`
+	CompressedSize int64     `json:"compressed-size"`
+	// The day found in the name of the archive, like 2026-10-17, empty if it has none
+	Date string `json:"date,omitempty"`
+	// The details below are missing until the archive has been read in the background
+	Size      int64      `json:"size,omitempty"`
+	Lines     int        `json:"lines,omitempty"`
+	FirstTime *time.Time `json:"first-time,omitempty"`
+	LastTime  *time.Time `json:"last-time,omitempty"`
+	// The url of the archive in the viewer, empty for a bundle
+	Url string `json:"url"`
+	// The identifier of the zip or tar file holding the archive, empty if it is not a member of a bundle
+	Bundle string `json:"bundle,omitempty"`
+	// The number of archives of a bundle, which is listed like a directory holding them; 0 for an archive
+	Members int `json:"members,omitempty"`
+
+	// The period of the events of the archive, which is the day of its name or its modification time if it has not been read yet
+	start, end time.Time
+}
+
+// catalogQuery selects a page of the archives of a catalog
+type catalogQuery struct {
+	// The text the names of the archives must contain, case-insensitively
+	name string
+	// The bounds of the days of the archives, zero if they are not set
+	from, to time.Time
+	// Whether the members of the bundles are listed in one entry per bundle, unless dir selects the bundle whose members are listed
+	grouped bool
+	// The identifier of the bundle whose members are listed, empty for the archives of the directory
+	dir        string
+	sort       string
+	descending bool
+	// The page to return, from 1
+	page, pageSize int
+}
+
+// catalogPage is a page of the archives of a catalog
+type catalogPage struct {
+	Archives []catalogEntry `json:"archives"`
+	// The number of archives matching the filters of the query
+	Total int `json:"total"`
+	Page  int `json:"page"`
+	Pages int `json:"pages"`
+	// Whether the details of some archives are still being read
+	Reading bool `json:"reading,omitempty"`
+}
+
+// parseCatalogQuery reads the query from the given params: name, from and to (days), dir (the identifier of a bundle to list),
+// sort (name, date, size, compressed-size or lines), order (asc or desc), page and per-page. The newest archives come first by default.
+// The zip and tar files are listed like directories holding their members.
+func parseCatalogQuery(params url.Values) (query catalogQuery, err error) {
+	query.name = strings.ToLower(params.Get("name"))
+	query.grouped, query.dir = true, params.Get("dir")
+	for param, bound := range map[string]*time.Time{"from": &query.from, "to": &query.to} {
+		if value := params.Get(param); value != "" {
+			if *bound, err = time.ParseInLocation(searchDateLayout, value, time.Local); err != nil {
+				return query, fmt.Errorf("%s must be a date like 2006-01-02", param)
+			}
+		}
+	}
+	if !query.to.IsZero() {
+		query.to = query.to.AddDate(0, 0, 1) // the whole last day is included
+	}
+
+	query.sort = params.Get("sort")
+	if query.sort == "" {
+		query.sort = "date"
+	}
+	if _, found := catalogSorts[query.sort]; !found {
+		return query, fmt.Errorf("unknown sort %q", query.sort)
+	}
+	switch order := params.Get("order"); order {
+	case "":
+		query.descending = query.sort != "name"
+	case "asc", "desc":
+		query.descending = order == "desc"
+	default:
+		return query, fmt.Errorf("order must be asc or desc, not %q", order)
+	}
+
+	if query.page, err = parseBoundedInt(params.Get("page"), 1, 1, math.MaxInt32); err != nil {
+		return query, fmt.Errorf("page %w", err)
+	}
+	if query.pageSize, err = parseBoundedInt(params.Get("per-page"), defaultCatalogPageSize, 1, maxCatalogPageSize); err != nil {
+		return query, fmt.Errorf("per-page %w", err)
+	}
+	return query, nil
+}
+
+// archivePeriod returns the period of the events of the given archive as far as it is known without reading it,
+// which is the day of its name if it has one, or else its modification time
+func archivePeriod(parser *logParser, entry archiveEntry) (start, end time.Time) {
+	if day, found := parser.archiveDay(entry.Name); found {
+		// the archive holds the events of the day of its name
+		return day, day.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	return entry.modTime, entry.modTime
+}
+
+// page returns the archives matching the given query, with their details if they are known.
+// The url of each archive is made with the given function.
+func (catalog *archiveCatalog) page(query catalogQuery, urlOf func(id string) string) (catalogPage, error) {
+	entries, err := catalog.list()
+	if err != nil {
+		return catalogPage{}, err
+	}
+
+	catalog.mutex.Lock()
+	page := catalogPage{Archives: []catalogEntry{}, Reading: catalog.reading}
+	var matching []*catalogEntry
+	// the bundles, in the order of their newest members
+	var bundles []*catalogEntry
+	bundlesById := make(map[string]*catalogEntry)
+	for _, entry := range entries {
+		if query.grouped && entry.bundle != query.dir && (query.dir != "" || entry.bundle == "") {
+			continue
+		}
+		archive := &catalogEntry{
+			Name:           entry.Name,
+			Id:             entry.Id,
+			ModTime:        entry.modTime,
+			CompressedSize: entry.size,
+			Bundle:         entry.bundle,
+		}
+		archive.start, archive.end = archivePeriod(catalog.parser, entry)
+		if day, found := catalog.parser.archiveDay(entry.Name); found {
+			archive.Date = day.Format(searchDateLayout)
+		}
+		if details, found := catalog.details[entry.Id]; found && details.isValidFor(entry) {
+			archive.Size, archive.Lines = details.uncompressedSize, details.lines
+			if !details.firstTime.IsZero() {
+				firstTime, lastTime := details.firstTime, details.lastTime
+				archive.FirstTime, archive.LastTime = &firstTime, &lastTime
+				archive.start, archive.end = firstTime, lastTime
+			}
+		}
+		if query.grouped && query.dir == "" && entry.bundle != "" {
+			bundle, found := bundlesById[entry.bundle]
+			if !found {
+				bundle = &catalogEntry{
+					Name:  strings.SplitN(entry.Name, "/", 2)[0],
+					Id:    entry.bundle,
+					start: archive.start,
+					end:   archive.end,
+				}
+				bundlesById[entry.bundle] = bundle
+				bundles = append(bundles, bundle)
+			}
+			bundle.add(archive)
+			continue
+		}
+		if query.matches(archive) {
+			matching = append(matching, archive)
+		}
+	}
+	for _, bundle := range bundles {
+		if query.matches(bundle) {
+			matching = append(matching, bundle)
+		}
+	}
+	catalog.mutex.Unlock()
+
+	less := catalogSorts[query.sort]
+	sort.SliceStable(matching, func(i, j int) bool {
+		if query.descending {
+			return less(matching[j], matching[i])
+		}
+		return less(matching[i], matching[j])
+	})
+
+	page.Total, page.Page = len(matching), query.page
+	page.Pages = (len(matching) + query.pageSize - 1) / query.pageSize
+	for i := (query.page - 1) * query.pageSize; i < len(matching) && i < query.page*query.pageSize; i++ {
+		archive := *matching[i]
+		if archive.Members == 0 {
+			archive.Url = urlOf(archive.Id)
+		}
+		page.Archives = append(page.Archives, archive)
+	}
+	return page, nil
+}
+
+// add counts the given member in the bundle, whose sizes, lines and period are the ones of all its members
+func (bundle *catalogEntry) add(member *catalogEntry) {
+	bundle.Members++
+	bundle.CompressedSize += member.CompressedSize
+	bundle.Size += member.Size
+	bundle.Lines += member.Lines
+	if member.ModTime.After(bundle.ModTime) {
+		bundle.ModTime = member.ModTime
+	}
+	if member.FirstTime != nil && (bundle.FirstTime == nil || member.FirstTime.Before(*bundle.FirstTime)) {
+		bundle.FirstTime = member.FirstTime
+	}
+	if member.LastTime != nil && (bundle.LastTime == nil || member.LastTime.After(*bundle.LastTime)) {
+		bundle.LastTime = member.LastTime
+	}
+	if member.start.Before(bundle.start) {
+		bundle.start = member.start
+	}
+	if member.end.After(bundle.end) {
+		bundle.end = member.end
+	}
+}
+
+// matches returns whether the given archive has the name and the period of the query
+func (query catalogQuery) matches(archive *catalogEntry) bool {
+	if query.name != "" && !strings.Contains(strings.ToLower(archive.Name), query.name) {
+		return false
+	}
+	if !query.from.IsZero() && archive.end.Before(query.from) {
+		return false
+	}
+	return query.to.IsZero() || archive.start.Before(query.to)
+}
+
+// serverArchives are the archives of a server, or of an instance of a dynamic server
+type serverArchives struct {
+	catalog *archiveCatalog
+	// The url of the archives in the viewer, to which their full names are appended
+	url         string
+	displayName string
+}
+
+// getServerArchives returns the archives of the given server, or of one of its instances if it is a dynamic server
+func getServerArchives(config Config, server, instance string) (archives serverArchives, found bool, err error) {
+	for _, servCfg := range config.Servers.Classic {
+		if servCfg.ServerTag != server {
+			continue
+		}
+		if !servCfg.archivesEnabled {
+			return archives, true, errArchivesDisabled
+		}
+		return serverArchives{
+			catalog:     catalogs.get(servCfg.getArchivedLogsDirPath(), servCfg.ArchivedLogFilenameFormat, servCfg.parser),
+			url:         config.UrlPrefix + "/archive/" + server + "/",
+			displayName: servCfg.DisplayName,
+		}, true, nil
+	}
+
+	for _, servCfg := range config.Servers.Dynamic {
+		if servCfg.ServerTag != server {
+			continue
+		}
+		if !servCfg.archivesEnabled {
+			return archives, true, errArchivesDisabled
+		}
+		if instance == "" {
+			return archives, true, errInstanceRequired
+		}
+		catalog, found, err := getDynamicArchivesCatalog(servCfg, instance)
+		if !found || err != nil {
+			return archives, found, err
+		}
+		return serverArchives{
+			catalog:     catalog,
+			url:         config.UrlPrefix + "/dyn-archive/" + server + "/" + url.PathEscape(instance) + "/",
+			displayName: strings.ReplaceAll(servCfg.DisplayName, "%id%", instance),
+		}, true, nil
+	}
+
+	return archives, false, nil
+}
+
+// getDynamicArchivesCatalog returns the catalog of the archives of an instance of a dynamic server. The instance is only
+// found if the server has a log file for it, so that an identifier given by a client is never put in a path otherwise.
+func getDynamicArchivesCatalog(servCfg DynamicServerConfig, instance string) (catalog *archiveCatalog, found bool, err error) {
+	locations, err := getDynamicArchivesLocations(servCfg, instance)
+	if err != nil {
+		return nil, true, err
+	}
+	if len(locations) == 0 {
+		return nil, false, nil
+	}
+	return catalogs.get(locations[0].dir, locations[0].pattern, servCfg.parser), true, nil
+}
+
+// serverLogFile is the live log file of a server, or of an instance of a dynamic server
+type serverLogFile struct {
+	path string
+	// The url of the log file in the viewer
+	url         string
+	displayName string
+	parser      *logParser
+}
+
+// getServerLogFile returns the live log file of the given server, or of one of its instances if it is a dynamic server
+func getServerLogFile(config Config, server, instance string) (logFile serverLogFile, found bool, err error) {
+	for _, servCfg := range config.Servers.Classic {
+		if servCfg.ServerTag == server {
+			return serverLogFile{
+				path:        servCfg.getLogFilePath(),
+				url:         config.UrlPrefix + "/server/" + server,
+				displayName: servCfg.DisplayName,
+				parser:      servCfg.parser,
+			}, true, nil
+		}
+	}
+	if instance == "" {
+		for _, servCfg := range config.Servers.Dynamic {
+			if servCfg.ServerTag == server {
+				return logFile, true, errInstanceRequired
+			}
+		}
+		return logFile, false, nil
+	}
+	servCfg, path, found := getDynamicServerConfigAndLogsPath(config.Servers.Dynamic, server, instance)
+	if !found {
+		return logFile, false, nil
+	}
+	return serverLogFile{
+		path:        path,
+		url:         config.UrlPrefix + "/dynamic/" + server + "/" + instance,
+		displayName: strings.ReplaceAll(servCfg.DisplayName, "%id%", instance),
+		parser:      servCfg.parser,
+	}, true, nil
+}
+
+// apiArchivesHandler serves a page of the archives of a server on /api/archives/{server}, see parseCatalogQuery for the params.
+// The instance query param is required for a dynamic server.
+func apiArchivesHandler(w http.ResponseWriter, r *http.Request, config Config) {
+	server := strings.TrimPrefix(r.URL.Path, "/api/archives/")
+	instance := r.URL.Query().Get("instance")
+	archives, found, err := getServerArchives(config, server, instance)
+	if !found {
+		prettier(w, "Unknown server: "+server, nil, http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, errArchivesDisabled) {
+		prettier(w, "No archives for server "+server, nil, http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, errInstanceRequired) {
+		prettier(w, "Invalid request: "+err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		printError(err)
+		prettier(w, "Failed to list the archives of server "+server, nil, http.StatusInternalServerError)
+		return
+	}
+	query, err := parseCatalogQuery(r.URL.Query())
+	if err != nil {
+		prettier(w, "Invalid request: "+err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	page, err := archives.catalog.page(query, func(id string) string { return archives.url + id })
+	if err != nil {
+		printError(err)
+		prettier(w, "Failed to list the archives of server "+server, nil, http.StatusInternalServerError)
+		return
+	}
+	prettier(w, "Archives of server "+server+", page "+strconv.Itoa(page.Page)+" of "+strconv.Itoa(page.Pages), page, http.StatusOK)
+}
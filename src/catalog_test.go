@@ -0,0 +1,161 @@
+package main
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestCatalog returns the catalog of a new directory holding three archives, the newest being the last one
+func newTestCatalog(t *testing.T) (*archiveCatalog, string) {
+	dir := t.TempDir()
+	writeTestArchive(t, dir, "2026-10-15-1.log.gz", "[08:00:00] start", "[09:30:00] stop")
+	writeTestArchive(t, dir, "2026-10-16-1.log.gz", "[10:00:00] start", "  continued", "[11:00:00] stop")
+	writeTestArchive(t, dir, "2026-10-17-1.log.gz", "no time")
+	for i, name := range []string{"2026-10-15-1.log.gz", "2026-10-16-1.log.gz", "2026-10-17-1.log.gz"} {
+		modTime := time.Date(2026, 10, 15+i, 23, 0, 0, 0, time.Local)
+		assert.NoError(t, os.Chtimes(filepath.Join(dir, name), modTime, modTime))
+	}
+	timestamp, _ := TimestampConfig{Regex: `^\[(?P<timestamp>\d{2}:\d{2}:\d{2})]`, Layout: "15:04:05"}.compile(nil)
+	return newArchiveCatalog(dir, "*.log.gz", &logParser{timestamp: timestamp}), dir
+}
+
+// waitForDetails lists the archives of the catalog until their details are all read
+func waitForDetails(t *testing.T, catalog *archiveCatalog) {
+	assert.Eventually(t, func() bool {
+		page, err := catalog.page(catalogQuery{sort: "date", page: 1, pageSize: 10}, func(string) string { return "" })
+		return err == nil && !page.Reading && page.Archives[0].Size > 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func archiveNames(page catalogPage) []string {
+	names := make([]string, 0, len(page.Archives))
+	for _, archive := range page.Archives {
+		names = append(names, archive.Name)
+	}
+	return names
+}
+
+func TestArchiveCatalogList(t *testing.T) {
+	catalog, dir := newTestCatalog(t)
+	entries, err := catalog.list()
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 3) {
+		assert.Equal(t, "2026-10-17-1.log.gz", entries[0].Name, "The newest archives should come first")
+		assert.Equal(t, "2026-10-15-1.log.gz", entries[2].Name)
+	}
+
+	writeTestArchive(t, dir, "2026-10-18-1.log.gz", "new")
+	assert.Eventually(t, func() bool {
+		entries, err = catalog.list()
+		return err == nil && len(entries) == 4
+	}, 5*time.Second, 10*time.Millisecond, "The new archives should be listed once their directory changed")
+	assert.NoError(t, os.Remove(filepath.Join(dir, "2026-10-15-1.log.gz")))
+	assert.Eventually(t, func() bool {
+		entries, err = catalog.list()
+		return err == nil && len(entries) == 3
+	}, 5*time.Second, 10*time.Millisecond, "The removed archives should not be listed anymore")
+}
+
+func TestArchivesWatcher(t *testing.T) {
+	root := t.TempDir()
+	watcher := &archivesWatcher{catalogs: make(map[string][]*archiveCatalog)}
+	var watched []*archiveCatalog
+	for _, name := range []string{"a", "b"} {
+		dir := filepath.Join(root, name)
+		assert.NoError(t, os.Mkdir(dir, 0755))
+		writeTestArchive(t, dir, "2026-10-16-1.log.gz", "old")
+		catalog := newArchiveCatalog(dir+"/", "*.log.gz", nil)
+		catalog.watcher = watcher
+		_, err := catalog.list()
+		assert.NoError(t, err)
+		watched = append(watched, catalog)
+	}
+	watcher.mutex.Lock()
+	assert.Len(t, watcher.catalogs, 2, "Every directory should be watched by the same watcher")
+	watcher.mutex.Unlock()
+
+	writeTestArchive(t, filepath.Join(root, "b"), "2026-10-17-1.log.gz", "new")
+	assert.Eventually(t, func() bool {
+		entries, err := watched[1].list()
+		return err == nil && len(entries) == 2
+	}, 5*time.Second, 10*time.Millisecond, "The catalog of the changed directory should be notified")
+
+	// a directory removed and created again is watched again
+	assert.NoError(t, os.RemoveAll(filepath.Join(root, "a")))
+	assert.Eventually(t, func() bool {
+		entries, err := watched[0].list()
+		return err == nil && len(entries) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.NoError(t, os.Mkdir(filepath.Join(root, "a"), 0755))
+	_, err := watched[0].list()
+	assert.NoError(t, err)
+	writeTestArchive(t, filepath.Join(root, "a"), "2026-10-18-1.log.gz", "recreated")
+	assert.Eventually(t, func() bool {
+		entries, err := watched[0].list()
+		return err == nil && len(entries) == 1
+	}, 5*time.Second, 10*time.Millisecond, "The recreated directory should be watched again")
+}
+
+func TestArchiveCatalogDetails(t *testing.T) {
+	catalog, _ := newTestCatalog(t)
+	waitForDetails(t, catalog)
+	page, err := catalog.page(catalogQuery{sort: "name", page: 1, pageSize: 10}, func(id string) string { return "/archive/serv/" + id })
+	if !assert.NoError(t, err) || !assert.Len(t, page.Archives, 3) {
+		return
+	}
+	archive := page.Archives[1]
+	assert.Equal(t, "2026-10-16-1.log.gz", archive.Name)
+	assert.Equal(t, "2026-10-16", archive.Date)
+	assert.Equal(t, 3, archive.Lines)
+	assert.Equal(t, int64(len("[10:00:00] start\n  continued\n[11:00:00] stop\n")), archive.Size)
+	assert.Greater(t, archive.CompressedSize, int64(0))
+	assert.Equal(t, "/archive/serv/"+archive.Id, archive.Url)
+	if assert.NotNil(t, archive.FirstTime) && assert.NotNil(t, archive.LastTime) {
+		assert.Equal(t, time.Date(2026, 10, 16, 10, 0, 0, 0, time.Local), *archive.FirstTime, "The date should be taken from the name of the archive")
+		assert.Equal(t, time.Date(2026, 10, 16, 11, 0, 0, 0, time.Local), *archive.LastTime)
+	}
+	assert.Nil(t, page.Archives[2].FirstTime, "An archive without timestamps has no period")
+	assert.Equal(t, 1, page.Archives[2].Lines)
+}
+
+func TestArchiveCatalogPages(t *testing.T) {
+	catalog, _ := newTestCatalog(t)
+	waitForDetails(t, catalog)
+	for params, expected := range map[string][]string{
+		"":                              {"2026-10-17-1.log.gz", "2026-10-16-1.log.gz", "2026-10-15-1.log.gz"},
+		"sort=name":                     {"2026-10-15-1.log.gz", "2026-10-16-1.log.gz", "2026-10-17-1.log.gz"},
+		"sort=lines&order=asc":          {"2026-10-17-1.log.gz", "2026-10-15-1.log.gz", "2026-10-16-1.log.gz"},
+		"per-page=2&page=2":             {"2026-10-15-1.log.gz"},
+		"name=16":                       {"2026-10-16-1.log.gz"},
+		"from=2026-10-16":               {"2026-10-17-1.log.gz", "2026-10-16-1.log.gz"},
+		"from=2026-10-16&to=2026-10-16": {"2026-10-16-1.log.gz"},
+		"to=2026-10-14":                 {},
+	} {
+		values, _ := url.ParseQuery(params)
+		query, err := parseCatalogQuery(values)
+		if !assert.NoError(t, err, params) {
+			continue
+		}
+		page, err := catalog.page(query, func(string) string { return "" })
+		if assert.NoError(t, err, params) {
+			assert.Equal(t, expected, archiveNames(page), params)
+		}
+	}
+
+	values, _ := url.ParseQuery("per-page=2")
+	query, _ := parseCatalogQuery(values)
+	page, _ := catalog.page(query, func(string) string { return "" })
+	assert.Equal(t, 3, page.Total)
+	assert.Equal(t, 2, page.Pages)
+
+	for _, params := range []string{"sort=unknown", "order=up", "page=0", "per-page=1000", "from=yesterday"} {
+		values, _ := url.ParseQuery(params)
+		_, err := parseCatalogQuery(values)
+		assert.Error(t, err, params)
+	}
+}
@@ -3,6 +3,8 @@ package main
 import (
 	"bytes"
 	"fmt"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -18,26 +20,43 @@ const (
 	// Send pings to client with this period. Must be less than pongWait.
 	pingPeriod = (pongWait * 9) / 10
 
-	// Maximum message size allowed from peer.
-	maxMessageSize = 512
+	// Maximum message size allowed from peer, which can hold a query.
+	maxMessageSize = 2048
 )
 
 // Client is a middleman between the websocket connection and the hub.
 type Client struct {
-	// Whether the client is connected or not
-	connected bool
-
 	// The Hub the client is connected to
 	hub *Hub
 
-	// The server the client is currently subscribed to
-	server string
-
 	// The websocket connection.
 	conn *websocket.Conn
 
-	// Buffered channel of outbound messages.
+	// Buffered channel of outbound messages, closed by the hub when the client is disconnected.
 	send chan []byte
+
+	// The format of the messages sent to the client
+	format messageFormat
+
+	// The name of the authenticated user using the client, if known
+	viewer string
+
+	// The query filtering the add events sent to the client, nil if they are all sent.
+	// It is set by the hub goroutine when the client subscribes.
+	filter *logQuery
+
+	// The following fields are only accessed by the hub goroutine, until the send channel is closed.
+
+	// The late messages of the client, concatenated to be sent at once
+	coalesced []byte
+	// The number of messages in coalesced
+	coalescedCount int
+	// The number of lines dropped since the last lag report
+	dropped int
+	// The number of lines dropped since the client is connected
+	totalDropped int
+	// The reason why the hub closed the connection, if any
+	closeReason string
 }
 
 func (c *Client) writer() {
@@ -56,7 +75,11 @@ func (c *Client) writer() {
 			}
 			if !ok {
 				// The hub closed the channel.
-				_ = c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				closeMessage := []byte{}
+				if c.closeReason != "" {
+					closeMessage = websocket.FormatCloseMessage(websocket.CloseTryAgainLater, c.closeReason)
+				}
+				_ = c.conn.WriteMessage(websocket.CloseMessage, closeMessage)
 				return
 			}
 
@@ -107,7 +130,7 @@ func (c *Client) readPump() {
 		err = c.conn.SetReadDeadline(time.Now().Add(pongWait))
 		return err
 	})
-	for c.connected {
+	for {
 		_, message, err := c.conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
@@ -120,27 +143,17 @@ func (c *Client) readPump() {
 	}
 }
 
-func (c *Client) handleMessage(server string) {
-	serverTag, serverId, valid := parseWSServer(server)
-	if valid { // Dynamic server (server=>instance)
-		if instances, found := c.hub.clientsByDynamicServer[serverTag]; found {
-			if clients, found := instances[serverId]; found {
-				c.server = server
-				c.hub.clientsByDynamicServerMutex.Lock()
-				c.hub.clientsByDynamicServer[serverTag][serverId] = append(clients, c)
-				c.hub.clientsByDynamicServerMutex.Unlock()
-				return
-			}
-		}
-	} else { // Classic server
-		if clients, found := c.hub.clientsByServer[server]; found {
-			c.server = server
-			c.hub.clientsByServerMutex.Lock()
-			c.hub.clientsByServer[server] = append(clients, c)
-			c.hub.clientsByServerMutex.Unlock()
-			return
+// handleMessage subscribes the client to the server of the message,
+// which can be followed by the url-encoded query filtering its events: server?q=level>=warn
+func (c *Client) handleMessage(message string) {
+	server, rawQuery, _ := strings.Cut(message, "?")
+	sub := subscription{client: c, server: server}
+	if rawQuery != "" {
+		values, err := url.ParseQuery(rawQuery)
+		if err == nil {
+			sub.filter, err = parseQuery(values.Get("q"))
 		}
+		sub.filterError = err
 	}
-	debugPrint("Unknown server: " + server)
-	c.send <- append(Event{Type: eventError, Message: "Unknown server: " + server}.Json(), messageSeparator...)
+	c.hub.subscribe <- sub
 }
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// bundleMemberSeparator separates the path of a bundle (a zip or tar file) from the name of one of its members
+// in the keys of the archives, like 2026-10.zip!/2026-10-17.log. The keys are never used as paths.
+const bundleMemberSeparator = "!/"
+
+// archiveLocation is where an archive is stored, in a file of its own or as a member of a bundle
+type archiveLocation struct {
+	// The path of the archive, or of its bundle
+	path string
+	// The name of the archive in its bundle, empty if it is not a member of a bundle
+	member string
+}
+
+// name returns the name of the file of the archive
+func (location archiveLocation) name() string {
+	if location.member != "" {
+		return path.Base(location.member)
+	}
+	return filepath.Base(location.path)
+}
+
+func (location archiveLocation) String() string {
+	if location.member != "" {
+		return location.path + bundleMemberSeparator + location.member
+	}
+	return location.path
+}
+
+// compressionFormat is a compression format of the archives, recognized by the first bytes of the files
+type compressionFormat struct {
+	name  string
+	magic []byte
+	// newReader returns a reader of the decompressed content of the given reader
+	newReader func(reader io.Reader) (io.ReadCloser, error)
+}
+
+var compressionFormats = []compressionFormat{
+	{"gzip", []byte{0x1f, 0x8b}, func(reader io.Reader) (io.ReadCloser, error) {
+		return gzip.NewReader(reader)
+	}},
+	{"bzip2", []byte("BZh"), func(reader io.Reader) (io.ReadCloser, error) {
+		return io.NopCloser(bzip2.NewReader(reader)), nil
+	}},
+	{"zstd", []byte{0x28, 0xb5, 0x2f, 0xfd}, func(reader io.Reader) (io.ReadCloser, error) {
+		decoder, err := zstd.NewReader(reader, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	}},
+	{"xz", []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}, func(reader io.Reader) (io.ReadCloser, error) {
+		xzReader, err := xz.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(xzReader), nil
+	}},
+}
+
+// isZlibHeader returns whether the given bytes start with a zlib header without preset dictionary, which has no magic number:
+// its first byte tells the deflate method and the second one makes both a multiple of 31
+func isZlibHeader(header []byte) bool {
+	return len(header) >= 2 && header[0]&0x0f == 8 && header[0]>>4 <= 7 && header[1]&0x20 == 0 &&
+		(uint16(header[0])<<8|uint16(header[1]))%31 == 0
+}
+
+// multiCloser closes several readers, from the last opened to the first one
+type multiCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (closer *multiCloser) Close() error {
+	var err error
+	for i := len(closer.closers) - 1; i >= 0; i-- {
+		if closeErr := closer.closers[i].Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// decompressingReader returns a reader of the decompressed content of the given reader, whose format is found
+// in its first bytes, or of the content itself if it is not compressed. The name of the format is empty in that case.
+func decompressingReader(reader io.Reader) (io.ReadCloser, string, error) {
+	buffered := bufio.NewReader(reader)
+	// a short content cannot be compressed, it is read as it is
+	header, _ := buffered.Peek(6)
+	for _, format := range compressionFormats {
+		if bytes.HasPrefix(header, format.magic) {
+			decompressed, err := format.newReader(buffered)
+			if err != nil {
+				return nil, format.name, fmt.Errorf("invalid %s archive: %w", format.name, err)
+			}
+			return decompressed, format.name, nil
+		}
+	}
+	if isZlibHeader(header) {
+		decompressed, err := zlib.NewReader(buffered)
+		if err != nil {
+			return nil, "zlib", fmt.Errorf("invalid zlib archive: %w", err)
+		}
+		return decompressed, "zlib", nil
+	}
+	return io.NopCloser(buffered), "", nil
+}
+
+// openArchive returns a reader of the decompressed content of the archive at the given location
+func openArchive(location archiveLocation) (io.ReadCloser, error) {
+	if location.member != "" {
+		return openBundleMember(location.path, location.member, true)
+	}
+	file, err := os.Open(location.path)
+	if err != nil {
+		return nil, err
+	}
+	decompressed, _, err := decompressingReader(file)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return &multiCloser{Reader: decompressed, closers: []io.Closer{file, decompressed}}, nil
+}
+
+// openRawArchive returns a reader of the content of the archive at the given location as it is stored, which is extracted
+// from its bundle if it is a member of one
+func openRawArchive(location archiveLocation) (io.ReadCloser, error) {
+	if location.member != "" {
+		return openBundleMember(location.path, location.member, false)
+	}
+	return os.Open(location.path)
+}
+
+// bundleMember is a log file held by a bundle
+type bundleMember struct {
+	name    string
+	modTime time.Time
+	// The size of the member in the bundle, which is its compressed size in a zip file
+	size int64
+}
+
+// bundleMemberName returns the cleaned name of a member of a bundle, or false if it is not safe to read the member:
+// its name is absolute, escapes the bundle with .. or holds the separator of the bundle paths
+func bundleMemberName(name string) (string, bool) {
+	if path.IsAbs(name) || strings.Contains(name, bundleMemberSeparator) {
+		return "", false
+	}
+	name = path.Clean(name)
+	return name, fs.ValidPath(name)
+}
+
+// bundleKind returns the kind of the bundle at the given path, zip or tar (which may be compressed),
+// or an empty string if it is not a bundle
+func bundleKind(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+
+	decompressed, format, err := decompressingReader(file)
+	if err != nil {
+		// the archive is listed anyway, the error is shown when it is read
+		return "", nil
+	}
+	defer func(decompressed io.Closer) {
+		_ = decompressed.Close()
+	}(decompressed)
+	header := make([]byte, 512)
+	n, _ := io.ReadFull(decompressed, header)
+	header = header[:n]
+	if format == "" && bytes.HasPrefix(header, []byte("PK\x03\x04")) {
+		return "zip", nil
+	}
+	// the magic of the tar headers follows the name, mode, owners, size, time, checksum, type and link of the first file
+	if len(header) >= 262 && bytes.Equal(header[257:262], []byte("ustar")) {
+		return "tar", nil
+	}
+	return "", nil
+}
+
+// listBundleMembers returns the log files held by the given bundle, without its directories
+// and the members whose names are not safe, see bundleMemberName
+func listBundleMembers(bundlePath, kind string) ([]bundleMember, error) {
+	var members []bundleMember
+	add := func(member bundleMember) {
+		name, safe := bundleMemberName(member.name)
+		if !safe {
+			debugPrint("Ignoring the member " + member.name + " of " + bundlePath + ", its name is not safe")
+			return
+		}
+		member.name = name
+		members = append(members, member)
+	}
+	switch kind {
+	case "zip":
+		zipReader, err := zip.OpenReader(bundlePath)
+		if err != nil {
+			return nil, err
+		}
+		defer func(zipReader *zip.ReadCloser) {
+			_ = zipReader.Close()
+		}(zipReader)
+		for _, file := range zipReader.File {
+			if !file.FileInfo().IsDir() {
+				add(bundleMember{name: file.Name, modTime: file.Modified, size: int64(file.CompressedSize64)})
+			}
+		}
+	case "tar":
+		tarReader, closer, err := openTar(bundlePath)
+		if err != nil {
+			return nil, err
+		}
+		defer func(closer io.Closer) {
+			_ = closer.Close()
+		}(closer)
+		for {
+			header, err := tarReader.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			if header.Typeflag == tar.TypeReg {
+				add(bundleMember{name: header.Name, modTime: header.ModTime, size: header.Size})
+			}
+		}
+	default:
+		return nil, fmt.Errorf("%s is not a zip or tar file", bundlePath)
+	}
+	return members, nil
+}
+
+// openTar returns a reader of the given tar file, which may be compressed, and the closer of the file
+func openTar(tarPath string) (*tar.Reader, io.Closer, error) {
+	file, err := os.Open(tarPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	decompressed, _, err := decompressingReader(file)
+	if err != nil {
+		_ = file.Close()
+		return nil, nil, err
+	}
+	return tar.NewReader(decompressed), &multiCloser{closers: []io.Closer{file, decompressed}}, nil
+}
+
+// openBundleMember returns a reader of the content of the given member of the bundle,
+// which is decompressed if it is compressed itself and decompress is true.
+// Only the members with safe names can be read, as they are listed by listBundleMembers.
+func openBundleMember(bundlePath, member string, decompress bool) (io.ReadCloser, error) {
+	member, safe := bundleMemberName(member)
+	if !safe {
+		return nil, fmt.Errorf("%s has no member with an unsafe name", bundlePath)
+	}
+	kind, err := bundleKind(bundlePath)
+	if err != nil {
+		return nil, err
+	}
+	switch kind {
+	case "zip":
+		zipReader, err := zip.OpenReader(bundlePath)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range zipReader.File {
+			if name, safe := bundleMemberName(file.Name); file.FileInfo().IsDir() || !safe || name != member {
+				continue
+			}
+			content, err := file.Open()
+			if err != nil {
+				_ = zipReader.Close()
+				return nil, err
+			}
+			if !decompress {
+				return &multiCloser{Reader: content, closers: []io.Closer{zipReader, content}}, nil
+			}
+			decompressed, _, err := decompressingReader(content)
+			if err != nil {
+				_ = content.Close()
+				_ = zipReader.Close()
+				return nil, err
+			}
+			return &multiCloser{Reader: decompressed, closers: []io.Closer{zipReader, content, decompressed}}, nil
+		}
+		_ = zipReader.Close()
+	case "tar":
+		tarReader, closer, err := openTar(bundlePath)
+		if err != nil {
+			return nil, err
+		}
+		for {
+			header, err := tarReader.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				_ = closer.Close()
+				return nil, err
+			}
+			if name, safe := bundleMemberName(header.Name); header.Typeflag != tar.TypeReg || !safe || name != member {
+				continue
+			}
+			if !decompress {
+				return &multiCloser{Reader: tarReader, closers: []io.Closer{closer}}, nil
+			}
+			decompressed, _, err := decompressingReader(tarReader)
+			if err != nil {
+				_ = closer.Close()
+				return nil, err
+			}
+			return &multiCloser{Reader: decompressed, closers: []io.Closer{closer, decompressed}}, nil
+		}
+		_ = closer.Close()
+	default:
+		return nil, fmt.Errorf("%s is not a zip or tar file", bundlePath)
+	}
+	return nil, fmt.Errorf("%s has no member %s", bundlePath, member)
+}
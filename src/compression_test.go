@@ -0,0 +1,163 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/hex"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/ulikunitz/xz"
+)
+
+// compressTestContent returns the given content compressed with the writer returned by newWriter
+func compressTestContent(t *testing.T, content string, newWriter func(io.Writer) (io.WriteCloser, error)) []byte {
+	var buffer bytes.Buffer
+	writer, err := newWriter(&buffer)
+	if err != nil {
+		t.Fatalf("failed to create writer: %v", err)
+	}
+	_, _ = writer.Write([]byte(content))
+	assert.NoError(t, writer.Close())
+	return buffer.Bytes()
+}
+
+func TestUncompressFormats(t *testing.T) {
+	dir := t.TempDir()
+	const content = "[12:00:00] bzip2\n"
+	// the standard library has no bzip2 writer, this is the content compressed by the bzip2 command
+	bzipped, _ := hex.DecodeString("425a6839314159265359ddcd44d4000005db80001040007010000a102040102000310340d0200686922d290765c86c24f8bb9229c28486ee6a26a0")
+	for name, compressed := range map[string][]byte{
+		"plain.log": []byte(content),
+		"empty.log": {},
+		"gzip.log.gz": compressTestContent(t, content, func(w io.Writer) (io.WriteCloser, error) {
+			return gzip.NewWriter(w), nil
+		}),
+		"bzip2.log.bz2": bzipped,
+		"zstd.log.zst": compressTestContent(t, content, func(w io.Writer) (io.WriteCloser, error) {
+			return zstd.NewWriter(w)
+		}),
+		"xz.log.xz": compressTestContent(t, content, func(w io.Writer) (io.WriteCloser, error) {
+			return xz.NewWriter(w)
+		}),
+		"zlib.log.z": compressTestContent(t, content, func(w io.Writer) (io.WriteCloser, error) {
+			return zlib.NewWriter(w), nil
+		}),
+	} {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.WriteFile(path, compressed, 0644))
+		uncompressed, err := uncompress(archiveLocation{path: path})
+		if assert.NoError(t, err, name) {
+			if name == "empty.log" {
+				assert.Empty(t, uncompressed)
+			} else {
+				assert.Equal(t, content, string(uncompressed), name)
+			}
+		}
+	}
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "broken.log.gz"), []byte{0x1f, 0x8b, 0x00}, 0644))
+	_, err := uncompress(archiveLocation{path: filepath.Join(dir, "broken.log.gz")})
+	assert.Error(t, err)
+}
+
+// writeTestBundles writes a zip file holding two logs, one of which is gzipped, and a gzipped tar file holding one log
+func writeTestBundles(t *testing.T, dir string) {
+	var zipped bytes.Buffer
+	zipWriter := zip.NewWriter(&zipped)
+	for name, content := range map[string][]byte{
+		"2026-09-01-1.log": []byte("[10:00:00] zipped\n"),
+		"sub/2026-09-02-1.log.gz": compressTestContent(t, "[11:00:00] gzipped in zip\n", func(w io.Writer) (io.WriteCloser, error) {
+			return gzip.NewWriter(w), nil
+		}),
+	} {
+		writer, err := zipWriter.CreateHeader(&zip.FileHeader{Name: name, Modified: time.Date(2026, 9, 3, 0, 0, 0, 0, time.UTC)})
+		assert.NoError(t, err)
+		_, _ = writer.Write(content)
+	}
+	assert.NoError(t, zipWriter.Close())
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "2026-09.zip"), zipped.Bytes(), 0644))
+
+	tarred := compressTestContent(t, "", func(w io.Writer) (io.WriteCloser, error) {
+		gzWriter := gzip.NewWriter(w)
+		tarWriter := tar.NewWriter(gzWriter)
+		content := []byte("[12:00:00] tarred\n")
+		assert.NoError(t, tarWriter.WriteHeader(&tar.Header{Name: "./2026-09-04-1.log", Mode: 0644, Size: int64(len(content)), ModTime: time.Now()}))
+		_, _ = tarWriter.Write(content)
+		assert.NoError(t, tarWriter.Close())
+		return gzWriter, nil
+	})
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "2026-09-b.tar.gz"), tarred, 0644))
+}
+
+func TestBundles(t *testing.T) {
+	dir := t.TempDir()
+	writeTestBundles(t, dir)
+	writeTestArchive(t, dir, "2026-10-16-1.log.gz", "[10:00:00] alone")
+
+	entries, err := listArchivedLogFiles(dir, "*")
+	if !assert.NoError(t, err) {
+		return
+	}
+	contents := make(map[string]string)
+	for _, entry := range entries {
+		content, err := uncompress(archiveLocation{path: filepath.Join(dir, entry.relativePath), member: entry.member})
+		assert.NoError(t, err, entry.Name)
+		contents[entry.Name] = string(content)
+	}
+	assert.Equal(t, map[string]string{
+		"2026-09.zip/2026-09-01-1.log":        "[10:00:00] zipped\n",
+		"2026-09.zip/sub/2026-09-02-1.log.gz": "[11:00:00] gzipped in zip\n",
+		"2026-09-b.tar.gz/2026-09-04-1.log":   "[12:00:00] tarred\n",
+		"2026-10-16-1.log.gz":                 "[10:00:00] alone\n",
+	}, contents, "The members of the bundles should be listed and read like archives")
+
+	_, err = uncompress(archiveLocation{path: filepath.Join(dir, "2026-09.zip"), member: "unknown.log"})
+	assert.Error(t, err)
+
+	// the members with unsafe names are neither listed nor read
+	var zipped bytes.Buffer
+	zipWriter := zip.NewWriter(&zipped)
+	for _, name := range []string{"../../outside.log", "/absolute.log", "a!/b.log", "safe.log"} {
+		writer, err := zipWriter.Create(name)
+		assert.NoError(t, err)
+		_, _ = writer.Write([]byte("[10:00:00] " + name + "\n"))
+	}
+	assert.NoError(t, zipWriter.Close())
+	unsafePath := filepath.Join(t.TempDir(), "unsafe.zip")
+	assert.NoError(t, os.WriteFile(unsafePath, zipped.Bytes(), 0644))
+	members, err := listBundleMembers(unsafePath, "zip")
+	if assert.NoError(t, err) && assert.Len(t, members, 1) {
+		assert.Equal(t, "safe.log", members[0].name)
+	}
+	for _, name := range []string{"../../outside.log", "/absolute.log", "a!/b.log"} {
+		_, err = openBundleMember(unsafePath, name, true)
+		assert.Error(t, err, name)
+	}
+
+	catalog := newArchiveCatalog(dir, "*", nil)
+	values := url.Values{"sort": {"name"}}
+	query, _ := parseCatalogQuery(values)
+	page, err := catalog.page(query, func(id string) string { return "/archive/serv/" + id })
+	if assert.NoError(t, err) && assert.Len(t, page.Archives, 3) {
+		assert.Equal(t, []string{"2026-09-b.tar.gz", "2026-09.zip", "2026-10-16-1.log.gz"}, archiveNames(page), "The bundles should be listed like directories")
+		zipped := page.Archives[1]
+		assert.Equal(t, 2, zipped.Members)
+		assert.Empty(t, zipped.Url)
+
+		values["dir"] = []string{zipped.Id}
+		query, _ = parseCatalogQuery(values)
+		page, err = catalog.page(query, func(id string) string { return "/archive/serv/" + id })
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"2026-09.zip/2026-09-01-1.log", "2026-09.zip/sub/2026-09-02-1.log.gz"}, archiveNames(page))
+	}
+}
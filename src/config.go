@@ -3,11 +3,12 @@ package main
 import (
 	"errors"
 	"fmt"
-	"html/template"
+	"net/http"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -18,8 +19,10 @@ var serverTagRegexp = regexp.MustCompile(`[\w\-.]{1,64}`)
 
 // SyntaxHighlightingConfig represents the syntax highlighting rules of a server
 type SyntaxHighlightingConfig []struct {
-	Field template.JS `yaml:"field" json:"field"`
-	Regex template.JS `yaml:"regex" json:"regex"`
+	// The name of the style to apply
+	Field string `yaml:"field" json:"field"`
+	// The RE2 regexp selecting the part of the log line to highlight
+	Regex string `yaml:"regex" json:"regex"`
 }
 
 // ServerConfig represents the properties of a server
@@ -32,8 +35,28 @@ type ServerConfig struct {
 	pathPrefix  string
 	// The regexps for the syntax highlighting for the logs of this server
 	SyntaxHighlightingRegexps SyntaxHighlightingConfig `yaml:"syntax-highlighting"`
+	// What to do with the ANSI escape codes of the logs: render (default), strip or raw
+	Ansi string `yaml:"ansi"`
+	// What to do with the Minecraft formatting codes of the logs, like §a: render or strip, they are left as they are if empty
+	MinecraftColors string `yaml:"minecraft-colors"`
+	// Whether the Minecraft formatting codes can also start with &, like &c, which is also the & of the urls or of words like R&D
+	MinecraftAmpersandCodes bool `yaml:"minecraft-ampersand-codes"`
+	// The rule grouping the lines of a same event, like a stack trace
+	Multiline MultilineConfig `yaml:"multiline"`
 	// A pointer to the logs style dictionnary
 	styles *map[string]string
+	// The rule extracting the severity level of the logs
+	Level LevelConfig `yaml:"level"`
+	// The rule extracting the time of the logs
+	Timestamp TimestampConfig `yaml:"timestamp"`
+	// The format of the log lines: text (default) or json
+	Format string `yaml:"format"`
+	// The keys of the JSON log lines, for the json format
+	Json JsonFormatConfig `yaml:"json"`
+	// The regexp extracting the fields of the logs with its named groups, e.g. (?P<status>\d{3}), to show them in a table
+	Fields string `yaml:"fields"`
+	// The compiled rules of the server
+	parser *logParser
 }
 
 type ClassicServerConfig struct {
@@ -44,6 +67,8 @@ type ClassicServerConfig struct {
 	ArchivedLogsDirPath string `yaml:"archived-logs-dir-path"`
 	// The format of the archived log filenames - only for classic servers
 	ArchivedLogFilenameFormat string `yaml:"archived-logs-filename-format"`
+	// The Go layout of the date in the archived log filenames, like 20060102, 2006-01-02 by default
+	ArchivedLogsDateFormat string `yaml:"archived-logs-date-format"`
 	// Whether archive logs reading is enabled or not
 	archivesEnabled bool
 }
@@ -63,6 +88,8 @@ type DynamicServerConfig struct {
 	ArchivedLogsRootDir string `yaml:"archived-logs-root-dir"`
 	// The pattern of the archived logs files
 	ArchivedLogsFilePattern string `yaml:"archived-logs-file-pattern"`
+	// The Go layout of the date in the archived log filenames, like 20060102, 2006-01-02 by default
+	ArchivedLogsDateFormat string `yaml:"archived-logs-date-format"`
 	// Whether archive logs reading is enabled or not
 	archivesEnabled bool
 }
@@ -90,6 +117,17 @@ type Config struct {
 	// The real value of DelayBeforeRewatch
 	delayBeforeRewatch time.Duration
 
+	// What to do with the web clients that do not read the logs fast enough: coalesce (default), drop or disconnect
+	SlowClientPolicy string `yaml:"slow-client-policy"`
+	// The real value of SlowClientPolicy
+	slowClientPolicy slowClientPolicy
+
+	// The header set by the authenticating reverse proxy with the name of the user (e.g. `X-Forwarded-User`).
+	// When set, the names of the viewers of each server are shown
+	AuthUserHeader string `yaml:"auth-user-header"`
+	// The users allowed to access the admin endpoints when auth-user-header is set, everyone is allowed if empty
+	AdminUsers []string `yaml:"admin-users"`
+
 	// An optional prefix that will be added in front of each log file path,
 	// for instance when the filesystem is mounted as a volume in a container at e.g. /mnt
 	PathPrefix string `yaml:"path-prefix"`
@@ -99,6 +137,22 @@ type Config struct {
 	// The styles as a map like name:css
 	styles map[string]string
 
+	// The options of the Prometheus metrics exposed on /metrics
+	Metrics struct {
+		// Whether the lines matching each syntax highlighting field should be counted, per server
+		CountSyntaxHighlightingFields bool `yaml:"count-syntax-highlighting-fields"`
+	} `yaml:"metrics"`
+
+	// The options of the on-disk index of the archived logs, which speeds up their search
+	ArchivesIndex struct {
+		// The directory of the index, the archives are not indexed if empty
+		Dir string `yaml:"dir"`
+		// The maximum size of the index, like 500MB, the oldest archives being left out of it. It is unlimited if empty
+		MaxSize string `yaml:"max-size"`
+		// The real value of MaxSize, in bytes
+		maxSize int64
+	} `yaml:"archives-index"`
+
 	// All the servers to list and listen to logs
 	Servers struct {
 		// The classic servers, whose log file path is static
@@ -121,12 +175,34 @@ func (config Config) String() string {
 	str += fmt.Sprintf("url-prefix: %s\n", config.UrlPrefix)
 	str += fmt.Sprintf("debug: %t\n", config.Debug)
 	str += fmt.Sprintf("delay-before-rewatch: %s\n", config.delayBeforeRewatch)
+	str += fmt.Sprintf("slow-client-policy: %s\n", config.slowClientPolicy)
+	if config.AuthUserHeader != "" {
+		str += fmt.Sprintf("auth-user-header: %s\n", config.AuthUserHeader)
+		str += fmt.Sprintf("admin-users: %s\n", strings.Join(config.AdminUsers, ", "))
+	}
 	str += fmt.Sprintf("style-file-path: %s\n", config.StyleFilePath)
+	str += fmt.Sprintf("metrics.count-syntax-highlighting-fields: %t\n", config.Metrics.CountSyntaxHighlightingFields)
+	if config.ArchivesIndex.Dir != "" {
+		str += fmt.Sprintf("archives-index.dir: %s\n", config.ArchivesIndex.Dir)
+		if config.ArchivesIndex.maxSize > 0 {
+			str += fmt.Sprintf("archives-index.max-size: %s\n", config.ArchivesIndex.MaxSize)
+		}
+	}
 	str += "classic servers:\n"
 	for _, servCfg := range config.Servers.Classic {
 		str += "\t" + servCfg.ServerTag + ":\n"
 		str += "\t\tdisplay-name: " + servCfg.DisplayName + "\n"
 		str += "\t\tlog-file-path: " + servCfg.getLogFilePath() + "\n"
+		str += "\t\tansi: " + string(servCfg.parser.highlighter.ansi) + "\n"
+		if servCfg.MinecraftColors != "" {
+			str += "\t\tminecraft-colors: " + servCfg.MinecraftColors + "\n"
+		}
+		if servCfg.MinecraftAmpersandCodes {
+			str += "\t\tminecraft-ampersand-codes: true\n"
+		}
+		if servCfg.parser.json != nil {
+			str += "\t\tformat: " + formatJSON + "\n"
+		}
 		if servCfg.archivesEnabled {
 			str += "\t\tarchived-logs-dir-path: " + servCfg.getArchivedLogsDirPath() + "\n"
 			str += "\t\tarchived-logs-filename-format: " + servCfg.ArchivedLogFilenameFormat + "\n"
@@ -140,6 +216,16 @@ func (config Config) String() string {
 		str += "\t\tdisplay-name: " + servCfg.DisplayName + "\n"
 		str += "\t\tlog-file-pattern: " + servCfg.getLogFilePattern() + "\n"
 		str += "\t\tinstance-identifier: " + servCfg.InstanceIdentifier + "\n"
+		str += "\t\tansi: " + string(servCfg.parser.highlighter.ansi) + "\n"
+		if servCfg.MinecraftColors != "" {
+			str += "\t\tminecraft-colors: " + servCfg.MinecraftColors + "\n"
+		}
+		if servCfg.MinecraftAmpersandCodes {
+			str += "\t\tminecraft-ampersand-codes: true\n"
+		}
+		if servCfg.parser.json != nil {
+			str += "\t\tformat: " + formatJSON + "\n"
+		}
 		if servCfg.archivesEnabled {
 			str += "\t\tarchived-logs-root-dir: " + servCfg.getArchivedLogsRootDir() + "\n"
 			str += "\t\tarchived-logs-file-pattern: " + servCfg.ArchivedLogsFilePattern + "\n"
@@ -150,6 +236,20 @@ func (config Config) String() string {
 	return str
 }
 
+// isAdmin returns whether the user who sent the request is allowed to access the admin endpoints
+func (config Config) isAdmin(r *http.Request) bool {
+	if config.AuthUserHeader == "" || len(config.AdminUsers) == 0 {
+		return true
+	}
+	user := r.Header.Get(config.AuthUserHeader)
+	for _, admin := range config.AdminUsers {
+		if user != "" && user == admin {
+			return true
+		}
+	}
+	return false
+}
+
 // getWebServerAddress returns the address the web server will listen to with the format expected by http.ListenAndServ
 func (config Config) getWebServerAddress() string {
 	return ":" + strconv.FormatUint(uint64(config.Port), 10)
@@ -181,6 +281,20 @@ func loadConfigFrom(configPath string) (Config, error) {
 	}
 	config.delayBeforeRewatch = delay
 
+	switch policy := slowClientPolicy(config.SlowClientPolicy); policy {
+	case "":
+		config.slowClientPolicy = policyCoalesce
+	case policyCoalesce, policyDrop, policyDisconnect:
+		config.slowClientPolicy = policy
+	default:
+		return Config{}, fmt.Errorf("invalid slow-client-policy %q: must be one of %q, %q or %q", policy, policyCoalesce, policyDrop, policyDisconnect)
+	}
+
+	config.ArchivesIndex.maxSize, err = parseByteSize(config.ArchivesIndex.MaxSize)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to parse archives-index.max-size: %w", err)
+	}
+
 	config.styles, err = loadStyles(config.StyleFilePath)
 	if err != nil {
 		return Config{}, fmt.Errorf("failed to load log styles file: %w", err)
@@ -193,22 +307,22 @@ func loadConfigFrom(configPath string) (Config, error) {
 	for servIndex := range config.Servers.Classic {
 		servCfg := config.Servers.Classic[servIndex]
 		servCfg.pathPrefix = config.PathPrefix
+		servCfg.styles = &config.styles
 		err = servCfg.load(servIndex)
 		if err != nil {
 			return Config{}, err
 		}
-		servCfg.styles = &config.styles
 
 		config.Servers.Classic[servIndex] = servCfg
 	}
 	for servIndex := range config.Servers.Dynamic {
 		servCfg := config.Servers.Dynamic[servIndex]
 		servCfg.pathPrefix = config.PathPrefix
+		servCfg.styles = &config.styles
 		err = servCfg.load(servIndex)
 		if err != nil {
 			return Config{}, err
 		}
-		servCfg.styles = &config.styles
 
 		config.Servers.Dynamic[servIndex] = servCfg
 	}
@@ -260,6 +374,9 @@ func (servCfg *ClassicServerConfig) load(servIndex int) error {
 		if servCfg.ArchivedLogFilenameFormat == "" {
 			return fmt.Errorf("no archive log filename format provided for classic server %q", servCfg.ServerTag)
 		}
+		if err = servCfg.compileArchivedLogsDateFormat("classic", servCfg.ArchivedLogsDateFormat); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -295,11 +412,27 @@ func (servCfg *DynamicServerConfig) load(servIndex int) error {
 		if servCfg.ArchivedLogsFilePattern == "" {
 			return fmt.Errorf("no archived logs file pattern provided for dynamic server %q", servCfg.ServerTag)
 		}
+		if err = servCfg.compileArchivedLogsDateFormat("dynamic", servCfg.ArchivedLogsDateFormat); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
+// compileArchivedLogsDateFormat sets the rule finding the day of the archives of the server, if their date format is not the default one
+func (servCfg *ServerConfig) compileArchivedLogsDateFormat(servType, layout string) error {
+	if layout == "" {
+		return nil
+	}
+	rule, err := compileArchiveDateFormat(layout)
+	if err != nil {
+		return fmt.Errorf("invalid archived-logs-date-format for %s server %q: %w", servType, servCfg.ServerTag, err)
+	}
+	servCfg.parser.archiveDates = rule
+	return nil
+}
+
 // loadCommon verifies and adapt the values of the ServerConfig, while returning any fatal error.
 // The servIndex param is used to identify the server in case the server-tag property is not defined
 func (servCfg *ServerConfig) loadCommon(servType string, servIndex int) error {
@@ -317,16 +450,65 @@ func (servCfg *ServerConfig) loadCommon(servType string, servIndex int) error {
 		servCfg.DisplayName = servCfg.ServerTag
 	}
 
-	for i, regexField := range servCfg.SyntaxHighlightingRegexps {
-		if regexField.Field == "" {
-			printError(fmt.Errorf("invalid syntax highlighting field name for %s server %q, it will be ignored", servType, servCfg.ServerTag))
-			servCfg.SyntaxHighlightingRegexps = append(servCfg.SyntaxHighlightingRegexps[:i], servCfg.SyntaxHighlightingRegexps[i+1:]...)
-			continue
+	servCfg.parser = new(logParser)
+	var errs []error
+	highlighter, errs := compileSyntaxHighlighting(servCfg.ServerTag, servCfg.SyntaxHighlightingRegexps, *servCfg.styles)
+	for _, err := range errs {
+		printError(err)
+	}
+	servCfg.parser.highlighter = highlighter
+
+	switch mode := ansiMode(servCfg.Ansi); mode {
+	case "":
+		highlighter.ansi = ansiRender
+	case ansiRender, ansiStrip, ansiRaw:
+		highlighter.ansi = mode
+	default:
+		return fmt.Errorf("invalid ansi mode for %s server %q: must be one of %q, %q or %q", servType, servCfg.ServerTag, ansiRender, ansiStrip, ansiRaw)
+	}
+
+	switch mode := minecraftMode(servCfg.MinecraftColors); mode {
+	case minecraftNone, minecraftRender, minecraftStrip:
+		highlighter.minecraft = mode
+	default:
+		return fmt.Errorf("invalid minecraft-colors mode for %s server %q: must be %q or %q", servType, servCfg.ServerTag, minecraftRender, minecraftStrip)
+	}
+	highlighter.minecraftAmpersand = servCfg.MinecraftAmpersandCodes
+
+	var err error
+	servCfg.parser.multiline, err = servCfg.Multiline.compile(highlighter)
+	if err != nil {
+		return fmt.Errorf("invalid multiline rule for %s server %q: %w", servType, servCfg.ServerTag, err)
+	}
+
+	switch servCfg.Format {
+	case "", formatText:
+	case formatJSON:
+		servCfg.parser.json, err = servCfg.Json.compile(servCfg.Level.Mapping)
+		if err != nil {
+			return fmt.Errorf("invalid level mapping for %s server %q: %w", servType, servCfg.ServerTag, err)
 		}
-		if regexField.Regex == "" {
-			servCfg.SyntaxHighlightingRegexps[i].Regex = `/.^/` // Regexp that matches nothing
+	default:
+		return fmt.Errorf("invalid format for %s server %q: must be %q or %q", servType, servCfg.ServerTag, formatText, formatJSON)
+	}
+
+	// the level mapping of a JSON server is used without regex, for the values of its level key
+	if servCfg.parser.json == nil || servCfg.Level.Regex != "" {
+		servCfg.parser.level, err = servCfg.Level.compile(highlighter)
+		if err != nil {
+			return fmt.Errorf("invalid level rule for %s server %q: %w", servType, servCfg.ServerTag, err)
 		}
 	}
 
+	servCfg.parser.fields, err = compileFieldsRule(servCfg.Fields, highlighter)
+	if err != nil {
+		return fmt.Errorf("invalid fields rule for %s server %q: %w", servType, servCfg.ServerTag, err)
+	}
+
+	servCfg.parser.timestamp, err = servCfg.Timestamp.compile(highlighter)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp rule for %s server %q: %w", servType, servCfg.ServerTag, err)
+	}
+
 	return nil
 }
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxDownloadedArchives is the maximum number of archives of a zip download
+const maxDownloadedArchives = 200
+
+// compressionExtensions are the extensions removed from the names of the archives downloaded decompressed
+var compressionExtensions = []string{".gz", ".bz2", ".zst", ".xz", ".zz", ".z"}
+
+// archiveStream is a seekable reader of the content of an archive, which is read from the start again to seek backward.
+// It lets the decompressed archives be served by ranges without being held in memory.
+type archiveStream struct {
+	open func() (io.ReadCloser, error)
+	// The size of the content, -1 until it is known
+	size   int64
+	offset int64
+	// The reader of the content and its offset, nil until the content is read
+	reader       io.ReadCloser
+	readerOffset int64
+}
+
+func (stream *archiveStream) Read(p []byte) (int, error) {
+	if stream.reader != nil && stream.readerOffset > stream.offset {
+		_ = stream.reader.Close()
+		stream.reader = nil
+	}
+	if stream.reader == nil {
+		reader, err := stream.open()
+		if err != nil {
+			return 0, err
+		}
+		stream.reader, stream.readerOffset = reader, 0
+	}
+	if stream.readerOffset < stream.offset {
+		skipped, err := io.CopyN(io.Discard, stream.reader, stream.offset-stream.readerOffset)
+		stream.readerOffset += skipped
+		if err != nil {
+			return 0, err
+		}
+	}
+	n, err := stream.reader.Read(p)
+	stream.offset += int64(n)
+	stream.readerOffset += int64(n)
+	return n, err
+}
+
+func (stream *archiveStream) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += stream.offset
+	case io.SeekEnd:
+		if stream.size < 0 {
+			reader, err := stream.open()
+			if err != nil {
+				return 0, err
+			}
+			stream.size, err = io.Copy(io.Discard, reader)
+			_ = reader.Close()
+			if err != nil {
+				stream.size = -1
+				return 0, err
+			}
+		}
+		offset += stream.size
+	}
+	if offset < 0 {
+		return 0, errors.New("seek before the start of the archive")
+	}
+	stream.offset = offset
+	return offset, nil
+}
+
+func (stream *archiveStream) Close() error {
+	if stream.reader == nil {
+		return nil
+	}
+	return stream.reader.Close()
+}
+
+// acceptsGzip returns whether the client accepts gzip encoded responses, which it does with a quality above 0
+// given to gzip, or to * if gzip is not listed
+func acceptsGzip(r *http.Request) bool {
+	gzipQuality, anyQuality := -1.0, -1.0
+	for _, encoding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(encoding, ";")
+		quality := 1.0
+		for _, param := range strings.Split(params, ";") {
+			key, value, _ := strings.Cut(param, "=")
+			if strings.EqualFold(strings.TrimSpace(key), "q") {
+				// an invalid quality is a refusal
+				quality, _ = strconv.ParseFloat(strings.TrimSpace(value), 64)
+			}
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "gzip":
+			gzipQuality = quality
+		case "*":
+			anyQuality = quality
+		}
+	}
+	if gzipQuality >= 0 {
+		return gzipQuality > 0
+	}
+	return anyQuality > 0
+}
+
+// serveDownload sends the given content as an attachment, by ranges if they are requested.
+// A text is gzipped on the fly for the clients which accept it, unless a range is requested.
+func serveDownload(w http.ResponseWriter, r *http.Request, name string, modTime time.Time, content io.ReadSeeker, isText bool) {
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	if !isText {
+		serveContent(w, r, name, modTime, content)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Vary", "Accept-Encoding")
+	if r.Header.Get("Range") != "" || !acceptsGzip(r) {
+		serveContent(w, r, name, modTime, content)
+		return
+	}
+
+	w.Header().Set("Content-Encoding", "gzip")
+	w.Header().Set("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+	if r.Method == http.MethodHead {
+		return
+	}
+	gzWriter := gzip.NewWriter(w)
+	if _, err := io.Copy(gzWriter, content); err != nil {
+		// the headers are already sent, the download is cut short
+		debugPrint("Failed to send " + name + ": " + err.Error())
+	}
+	_ = gzWriter.Close()
+}
+
+// serveContent serves the given content with http.ServeContent, which seeks its end to find its size. An archive stream
+// whose size is not known yet is sent as it is read instead, without its length, unless a range or its headers are
+// requested, so that it is not read once more beforehand.
+func serveContent(w http.ResponseWriter, r *http.Request, name string, modTime time.Time, content io.ReadSeeker) {
+	stream, isStream := content.(*archiveStream)
+	if !isStream || stream.size >= 0 || r.Method != http.MethodGet || r.Header.Get("Range") != "" {
+		http.ServeContent(w, r, name, modTime, content)
+		return
+	}
+	if w.Header().Get("Content-Type") == "" {
+		contentType := mime.TypeByExtension(filepath.Ext(name))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		w.Header().Set("Content-Type", contentType)
+	}
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+	if _, err := io.Copy(w, stream); err != nil {
+		// the headers are already sent, the download is cut short
+		debugPrint("Failed to send " + name + ": " + err.Error())
+	}
+}
+
+// decompressedName returns the name of the given archive once decompressed
+func decompressedName(name string) string {
+	for _, extension := range compressionExtensions {
+		if strings.HasSuffix(strings.ToLower(name), extension) {
+			return name[:len(name)-len(extension)]
+		}
+	}
+	return name
+}
+
+// downloadHandler serves the logs of a server as files on /download/{server}: its live log file as it is when the request
+// is received, or the zip of the archives given by the archive query params (identifiers). One archive is served on
+// /download/{server}/{id}. The archives are sent as they are stored, or decompressed if the decompress query param is set.
+// The instance query param is required for a dynamic server.
+func downloadHandler(w http.ResponseWriter, r *http.Request, config Config) {
+	server, id, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/download/"), "/")
+	params := r.URL.Query()
+	instance := params.Get("instance")
+	decompress := params.Get("decompress") != "" && params.Get("decompress") != "false"
+
+	if id == "" && !params.Has("archive") {
+		downloadLiveLogs(w, r, config, server, instance)
+		return
+	}
+
+	archives, found, err := getServerArchives(config, server, instance)
+	if !found || errors.Is(err, errArchivesDisabled) {
+		prettier(w, "No archives for server "+server, nil, http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, errInstanceRequired) {
+		prettier(w, "Invalid request: "+err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		printError(err)
+		prettier(w, "Failed to list the archives of server "+server, nil, http.StatusInternalServerError)
+		return
+	}
+
+	if id == "" {
+		downloadArchivesZip(w, archives.catalog, server, params["archive"], decompress)
+		return
+	}
+	entry, found, err := archives.catalog.find(id)
+	if err != nil {
+		printError(err)
+		prettier(w, "Failed to list the archives of server "+server, nil, http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		prettier(w, "Unknown archive", nil, http.StatusNotFound)
+		return
+	}
+	downloadArchive(w, r, archives.catalog, entry, decompress)
+}
+
+// downloadLiveLogs serves the live log file of a server up to its size when the request is received
+func downloadLiveLogs(w http.ResponseWriter, r *http.Request, config Config, server, instance string) {
+	logFile, found, err := getServerLogFile(config, server, instance)
+	if !found {
+		prettier(w, "Unknown server: "+server, nil, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		prettier(w, "Invalid request: "+err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+	file, err := os.Open(logFile.path)
+	if err != nil {
+		printError(err)
+		prettier(w, "Failed to read the logs of server "+server, nil, http.StatusInternalServerError)
+		return
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+	info, err := file.Stat()
+	if err != nil {
+		printError(err)
+		prettier(w, "Failed to read the logs of server "+server, nil, http.StatusInternalServerError)
+		return
+	}
+	// the lines written during the download are not part of the snapshot
+	snapshot := io.NewSectionReader(file, 0, info.Size())
+	name := strings.TrimSuffix(filepath.Base(logFile.path), filepath.Ext(logFile.path)) + "-" + time.Now().Format("2006-01-02-150405") + filepath.Ext(logFile.path)
+	serveDownload(w, r, name, info.ModTime(), snapshot, true)
+}
+
+// downloadArchive serves an archive as it is stored, or decompressed
+func downloadArchive(w http.ResponseWriter, r *http.Request, catalog *archiveCatalog, entry archiveEntry, decompress bool) {
+	location := catalog.locationOf(entry)
+	name := location.name()
+	if !decompress {
+		if location.member == "" {
+			file, err := os.Open(location.path)
+			if err != nil {
+				printError(err)
+				prettier(w, "Failed to read archive "+entry.Name, nil, http.StatusInternalServerError)
+				return
+			}
+			defer func(file *os.File) {
+				_ = file.Close()
+			}(file)
+			serveDownload(w, r, name, entry.modTime, file, false)
+			return
+		}
+		stream := &archiveStream{open: func() (io.ReadCloser, error) { return openRawArchive(location) }, size: -1}
+		defer func(stream io.Closer) {
+			_ = stream.Close()
+		}(stream)
+		serveDownload(w, r, name, entry.modTime, stream, false)
+		return
+	}
+
+	// the archive is opened before anything is sent, in case it cannot be read. Its decompressed size is only counted
+	// if a range or the headers are requested.
+	reader, err := openArchive(location)
+	if err != nil {
+		printError(err)
+		prettier(w, "Failed to read archive "+entry.Name, nil, http.StatusInternalServerError)
+		return
+	}
+	stream := &archiveStream{open: func() (io.ReadCloser, error) { return openArchive(location) }, size: -1, reader: reader}
+	defer func(stream io.Closer) {
+		_ = stream.Close()
+	}(stream)
+	serveDownload(w, r, decompressedName(name), entry.modTime, stream, true)
+}
+
+// downloadArchivesZip streams the zip of the given archives of a server, which are named in it like in the catalog
+func downloadArchivesZip(w http.ResponseWriter, catalog *archiveCatalog, server string, ids []string, decompress bool) {
+	if len(ids) > maxDownloadedArchives {
+		prettier(w, fmt.Sprintf("Invalid request: at most %d archives can be downloaded at once", maxDownloadedArchives), nil, http.StatusBadRequest)
+		return
+	}
+	entries := make([]archiveEntry, 0, len(ids))
+	for _, id := range ids {
+		entry, found, err := catalog.find(id)
+		if err != nil {
+			printError(err)
+			prettier(w, "Failed to list the archives of server "+server, nil, http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			prettier(w, "Unknown archive: "+id, nil, http.StatusNotFound)
+			return
+		}
+		entries = append(entries, entry)
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment",
+		map[string]string{"filename": server + "-archives-" + time.Now().Format("2006-01-02-150405") + ".zip"}))
+	zipWriter := zip.NewWriter(w)
+	for _, entry := range entries {
+		if err := addArchiveToZip(zipWriter, catalog.locationOf(entry), entry, decompress); err != nil {
+			// the headers are already sent, the zip is left incomplete
+			printError(fmt.Errorf("failed to add archive %s to the zip: %w", entry.Name, err))
+			return
+		}
+	}
+	if err := zipWriter.Close(); err != nil {
+		debugPrint("Failed to send the zip of the archives of " + server + ": " + err.Error())
+	}
+}
+
+// addArchiveToZip writes the given archive to the zip, as it is stored or decompressed
+func addArchiveToZip(zipWriter *zip.Writer, location archiveLocation, entry archiveEntry, decompress bool) error {
+	header := &zip.FileHeader{Name: entry.Name, Modified: entry.modTime, Method: zip.Store}
+	open := openRawArchive
+	if decompress {
+		header.Name, header.Method, open = decompressedName(entry.Name), zip.Deflate, openArchive
+	}
+	reader, err := open(location)
+	if err != nil {
+		return err
+	}
+	defer func(reader io.Closer) {
+		_ = reader.Close()
+	}(reader)
+	writer, err := zipWriter.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(writer, reader)
+	return err
+}
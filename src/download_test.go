@@ -0,0 +1,145 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArchiveStream(t *testing.T) {
+	const content = "0123456789abcdefghij"
+	opened := 0
+	stream := &archiveStream{open: func() (io.ReadCloser, error) {
+		opened++
+		return io.NopCloser(strings.NewReader(content)), nil
+	}, size: -1}
+
+	size, err := stream.Seek(0, io.SeekEnd)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(content)), size, "The size should be counted when it is not known")
+	_, _ = stream.Seek(10, io.SeekStart)
+	read := make([]byte, 5)
+	_, err = io.ReadFull(stream, read)
+	assert.NoError(t, err)
+	assert.Equal(t, "abcde", string(read))
+	_, _ = stream.Seek(-3, io.SeekCurrent)
+	_, err = io.ReadFull(stream, read)
+	assert.NoError(t, err)
+	assert.Equal(t, "cdefg", string(read), "Seeking backward should read the content again")
+	assert.Equal(t, 3, opened)
+	_, err = stream.Seek(-1, io.SeekStart)
+	assert.Error(t, err)
+	assert.NoError(t, stream.Close())
+}
+
+func TestServeDownload(t *testing.T) {
+	const content = "[10:00:00] first\n[10:00:01] second\n"
+	download := func(headers map[string]string) *http.Response {
+		r := httptest.NewRequest(http.MethodGet, "/download/serv", nil)
+		for name, value := range headers {
+			r.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		serveDownload(w, r, "latest.log", time.Now(), strings.NewReader(content), true)
+		return w.Result()
+	}
+
+	response := download(nil)
+	body, _ := io.ReadAll(response.Body)
+	assert.Equal(t, content, string(body))
+	assert.Equal(t, "attachment; filename=latest.log", response.Header.Get("Content-Disposition"))
+
+	response = download(map[string]string{"Accept-Encoding": "br, gzip"})
+	assert.Equal(t, "gzip", response.Header.Get("Content-Encoding"))
+	gzReader, err := gzip.NewReader(response.Body)
+	if assert.NoError(t, err) {
+		body, _ = io.ReadAll(gzReader)
+		assert.Equal(t, content, string(body), "The text should be gzipped on the fly")
+	}
+
+	response = download(map[string]string{"Accept-Encoding": "gzip", "Range": "bytes=11-15"})
+	assert.Equal(t, http.StatusPartialContent, response.StatusCode)
+	assert.Empty(t, response.Header.Get("Content-Encoding"), "A range should not be gzipped")
+	body, _ = io.ReadAll(response.Body)
+	assert.Equal(t, "first", string(body))
+
+	for header, expected := range map[string]bool{
+		"gzip;q=0, br":        false,
+		"gzip; q=0.0":         false,
+		"gzip;q=0.000":        false,
+		"gzip;q=0.5":          true,
+		"GZIP":                true,
+		"br, *":               true,
+		"br, *;q=0":           false,
+		"gzip;q=0.1, *;q=0":   true,
+		"gzip;q=0, *;q=1":     false,
+		"deflate, gzip;q=bad": false,
+		"":                    false,
+	} {
+		assert.Equal(t, expected, acceptsGzip(&http.Request{Header: http.Header{"Accept-Encoding": {header}}}), header)
+	}
+}
+
+func TestDownloadArchives(t *testing.T) {
+	_, dir := newTestCatalog(t)
+	writeTestBundles(t, dir)
+	catalog := newArchiveCatalog(dir, "*", nil)
+	entries, err := catalog.list()
+	if !assert.NoError(t, err) {
+		return
+	}
+	byName := make(map[string]archiveEntry)
+	for _, entry := range entries {
+		byName[entry.Name] = entry
+	}
+
+	w := httptest.NewRecorder()
+	downloadArchive(w, httptest.NewRequest(http.MethodGet, "/download/serv/x?decompress=1", nil), catalog, byName["2026-10-16-1.log.gz"], true)
+	assert.Equal(t, "[10:00:00] start\n  continued\n[11:00:00] stop\n", w.Body.String())
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "2026-10-16-1.log")
+	assert.Empty(t, w.Header().Get("Content-Length"), "The archive should be sent as it is decompressed")
+
+	r := httptest.NewRequest(http.MethodHead, "/download/serv/x?decompress=1", nil)
+	w = httptest.NewRecorder()
+	downloadArchive(w, r, catalog, byName["2026-10-16-1.log.gz"], true)
+	assert.Equal(t, "45", w.Header().Get("Content-Length"), "The size should be counted for the headers")
+	r = httptest.NewRequest(http.MethodGet, "/download/serv/x?decompress=1", nil)
+	r.Header.Set("Range", "bytes=-5")
+	w = httptest.NewRecorder()
+	downloadArchive(w, r, catalog, byName["2026-10-16-1.log.gz"], true)
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "stop\n", w.Body.String())
+
+	w = httptest.NewRecorder()
+	downloadArchive(w, httptest.NewRequest(http.MethodGet, "/download/serv/x", nil), catalog, byName["2026-10-16-1.log.gz"], false)
+	raw, _ := uncompress(archiveLocation{path: filepath.Join(dir, "2026-10-16-1.log.gz")})
+	gzReader, err := gzip.NewReader(w.Body)
+	if assert.NoError(t, err, "The archive should be sent as it is stored") {
+		sent, _ := io.ReadAll(gzReader)
+		assert.Equal(t, raw, sent)
+	}
+
+	w = httptest.NewRecorder()
+	downloadArchivesZip(w, catalog, "serv", []string{byName["2026-10-15-1.log.gz"].Id, byName["2026-09.zip/2026-09-01-1.log"].Id}, true)
+	zipReader, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if assert.NoError(t, err) && assert.Len(t, zipReader.File, 2) {
+		assert.Equal(t, "2026-10-15-1.log", zipReader.File[0].Name)
+		assert.Equal(t, "2026-09.zip/2026-09-01-1.log", zipReader.File[1].Name)
+		member, _ := zipReader.File[1].Open()
+		content, _ := io.ReadAll(member)
+		assert.Equal(t, "[10:00:00] zipped\n", string(content))
+	}
+
+	w = httptest.NewRecorder()
+	downloadArchivesZip(w, catalog, "serv", []string{archiveId("../../etc/passwd")}, false)
+	assert.Equal(t, http.StatusNotFound, w.Code, "Only the archives of the catalog should be downloaded")
+}
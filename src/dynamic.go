@@ -3,7 +3,9 @@ package main
 import (
 	"fmt"
 	"net/http"
+	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -15,63 +17,65 @@ type DynamicServerInstance struct {
 	id          string
 	displayName string
 	logFilePath string
-	ended       bool
 }
 
 // DynamicServer represents a type of dynamic server with all its instances
 type DynamicServer struct {
-	config    DynamicServerConfig
-	tag       string // shorthand for config.ServerTag
-	instances []*DynamicServerInstance
+	config DynamicServerConfig
+	tag    string // shorthand for config.ServerTag
+	// The instances currently watched, only accessed by the watchForInstances goroutine
+	instances map[string]*DynamicServerInstance
+	// The identifiers of the instances whose logs are not watched anymore
+	endedInstances chan string
 }
 
-func (server DynamicServer) watchForInstances(hub *Hub, outputChannel chan Event, watchInterval time.Duration) {
+func (server *DynamicServer) watchForInstances(hub *Hub, outputChannel chan Event, watchInterval time.Duration) {
 	for {
 		startTime := time.Now()
 
-		stillRunning := make(map[string]struct{})
-		for i := 0; i < len(server.instances); i++ {
-			instance := server.instances[i]
-			if instance.ended {
-				server.instances = append(server.instances[:i], server.instances[i+1:]...)
-				i-- // so as not to skip the next one
-			} else {
-				stillRunning[instance.id] = struct{}{}
+		// forget the instances that have ended since the last iteration
+		for noMoreEnded := false; !noMoreEnded; {
+			select {
+			case id := <-server.endedInstances:
+				delete(server.instances, id)
+			default:
+				noMoreEnded = true
 			}
 		}
 
 		latestInstances, err := getDynamicServerInstances(server.config)
 		if err != nil {
-			printError(fmt.Errorf("failed to find instances of server %q: %v", server.tag, err))
+			err = fmt.Errorf("failed to find instances of server %q: %v", server.tag, err)
+			printError(err)
+			health.recordDiscovery(server.tag, len(server.instances), err)
 		} else {
 			for i := 0; i < len(latestInstances); i++ {
 				instance := latestInstances[i]
-				if _, exists := stillRunning[instance.id]; exists {
+				if _, exists := server.instances[instance.id]; exists {
 					continue // already watching it
 				}
 				debugPrint(fmt.Sprintf("Found new instance of server %q: %q", server.tag, instance.id))
+				metrics.add(metricInstancesDiscovered, serverLabels(server.tag), 1)
 				// preserve existing WS connections between instance's reboots
-				if _, exists := hub.clientsByDynamicServer[server.tag][instance.id]; exists {
-					hub.sendResetMessage(server.tag, instance.id)
-				} else {
-					hub.clientsByDynamicServer[server.tag][instance.id] = []*Client{}
-				}
+				hub.addServer(joinWSServer(server.tag, instance.id))
 				// instance given as parameter to not be replaced by the for loop current instance
 				go func(instance *DynamicServerInstance) {
 					logQueue := fifo.NewQueue()
-					stop := false
-					go unstackDynamic(server.tag, instance.id, logQueue, outputChannel, &stop)
+					stop := make(chan struct{})
+					go unstackDynamic(server.tag, instance.id, server.config.parser, logQueue, outputChannel, stop)
 					watchServ(logQueue, watchProperties{
 						servName:                  joinWSServer(server.tag, instance.id),
 						logFilePath:               instance.logFilePath,
 						shouldRewatchOnFileRemove: false,
 					})
 					// watches until it returns
-					stop = true
-					instance.ended = true
+					close(stop)
+					metrics.add(metricInstancesEnded, serverLabels(server.tag), 1)
+					server.endedInstances <- instance.id
 				}(&instance)
-				server.instances = append(server.instances, &instance)
+				server.instances[instance.id] = &instance
 			}
+			health.recordDiscovery(server.tag, len(server.instances), nil)
 		}
 
 		time.Sleep(watchInterval - time.Since(startTime))
@@ -79,7 +83,13 @@ func (server DynamicServer) watchForInstances(hub *Hub, outputChannel chan Event
 }
 
 func newDynamicServer(config DynamicServerConfig) *DynamicServer {
-	return &DynamicServer{config: config, tag: config.ServerTag, instances: []*DynamicServerInstance{}}
+	health.registerDiscovery(config.ServerTag)
+	return &DynamicServer{
+		config:         config,
+		tag:            config.ServerTag,
+		instances:      make(map[string]*DynamicServerInstance),
+		endedInstances: make(chan string, 16),
+	}
 }
 
 type DynamicServers map[string]*DynamicServer
@@ -113,12 +123,86 @@ func getDynamicServerInstances(servCfg DynamicServerConfig) ([]DynamicServerInst
 			// infers the instance identifier into the server display name
 			displayName: strings.ReplaceAll(servCfg.DisplayName, "%id%", id),
 			logFilePath: logFilePath,
-			ended:       false,
 		}
 	}
 	return logFiles, nil
 }
 
+// getArchivedInstanceIds returns the identifiers of the instances of a dynamic server having an archives directory, which is
+// found by using %id% as a wildcard in the archived logs root dir. They include the instances whose log file is gone.
+func getArchivedInstanceIds(servCfg DynamicServerConfig) ([]string, error) {
+	rootDir := servCfg.getArchivedLogsRootDir()
+	if !strings.Contains(rootDir, "%id%") {
+		return nil, nil
+	}
+	dirs, err := filepath.Glob(strings.ReplaceAll(rootDir, "%id%", "*"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid archived logs root dir: %v", err)
+	}
+	// the wildcard does not match the separators, the identifier is found between the texts surrounding the first %id% in its part
+	prefix, suffix, _ := strings.Cut(rootDir, "%id%")
+	suffix, _, _ = strings.Cut(suffix, string(filepath.Separator))
+	var ids []string
+	for _, dir := range dirs {
+		part, _, _ := strings.Cut(strings.TrimPrefix(dir, prefix), string(filepath.Separator))
+		id := strings.TrimSuffix(part, suffix)
+		if id == "" || strings.ReplaceAll(rootDir, "%id%", id) != dir {
+			continue
+		}
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// getDynamicArchivesLocations returns the archives directories of the instances of the given dynamic server,
+// or of one of them if the instance is not empty. The instances are the ones having a log file or an archives directory.
+func getDynamicArchivesLocations(servCfg DynamicServerConfig, instance string) ([]archivesLocation, error) {
+	ids, err := getDynamicArchivesInstanceIds(servCfg)
+	if err != nil {
+		return nil, err
+	}
+	var locations []archivesLocation
+	for _, id := range ids {
+		if instance != "" && id != instance {
+			continue
+		}
+		locations = append(locations, archivesLocation{
+			dir:      strings.ReplaceAll(servCfg.getArchivedLogsRootDir(), "%id%", id),
+			pattern:  strings.ReplaceAll(servCfg.ArchivedLogsFilePattern, "%id%", id),
+			instance: id,
+		})
+	}
+	return locations, nil
+}
+
+// getDynamicArchivesInstanceIds returns the sorted identifiers of the instances of the given dynamic server which may have archives:
+// the ones having a log file and the ones having an archives directory, even if their log file is gone
+func getDynamicArchivesInstanceIds(servCfg DynamicServerConfig) ([]string, error) {
+	instances, err := getDynamicServerInstances(servCfg)
+	if err != nil {
+		return nil, err
+	}
+	archivedIds, err := getArchivedInstanceIds(servCfg)
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[string]bool)
+	var ids []string
+	for _, instance := range instances {
+		archivedIds = append(archivedIds, instance.id)
+	}
+	for _, id := range archivedIds {
+		if id != "" && !found[id] {
+			found[id] = true
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
 func getAllDynamicInstances(dynamicServConfigs []DynamicServerConfig, onlyThisServer string) (logFiles map[string]map[string]string, status uint) {
 	logFiles = make(map[string]map[string]string)
 	for _, servCfg := range dynamicServConfigs {
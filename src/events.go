@@ -1,14 +1,24 @@
 package main
 
 import (
+	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"html/template"
+	"strconv"
+	"strings"
+	"time"
 )
 
 const (
 	eventAdd   = "ADD"
 	eventReset = "RESET"
 	eventError = "ERROR"
+	// eventLag reports to a slow client that it received lines late or that some were dropped
+	eventLag = "LAG"
+	// eventViewers notifies the web clients of the viewers of every server
+	eventViewers = "VIEWERS"
 )
 
 type fileEvent struct {
@@ -20,13 +30,41 @@ func (evt fileEvent) String() string {
 	return fmt.Sprintf("Type: %s / Content: %s\n", evt.eventType, evt.content)
 }
 
+// messageFormat represents the way an Event is encoded before being sent to a subscriber
+type messageFormat int
+
+const (
+	// formatWebSocket is the base64 encoded json used by the web interface
+	formatWebSocket messageFormat = iota
+	// formatSSE is the text/event-stream format, as expected by an EventSource
+	formatSSE
+	// formatPlain is the raw log lines, like `tail -f` would print them
+	formatPlain
+)
+
 type Event struct {
+	// The sequence number of the event, unique per server (or instance) and used for resuming streams
+	ID        uint64 `json:"id"`
 	Type      string `json:"type"`
 	Server    string `json:"server"`
 	isDynamic bool
 	instance  string
 	Content   string `json:"content"`
-	Message   string `json:"message"`
+	// The highlighted and HTML-escaped version of the content, for add events
+	Html template.HTML `json:"html,omitempty"`
+	// The normalized severity level of the content, for add events of servers with a level rule
+	Level string `json:"level,omitempty"`
+	// The time extracted from the content, for add events of servers with a timestamp rule
+	Time *time.Time `json:"time,omitempty"`
+	// The fields extracted from the content by key, for add events of servers with fields
+	Fields  map[string]string `json:"fields,omitempty"`
+	Message string            `json:"message"`
+	// The number of lines sent late to the client, for lag events
+	Delayed int `json:"delayed,omitempty"`
+	// The total number of lines dropped for the client, for lag events
+	Dropped int `json:"dropped,omitempty"`
+	// The viewers of every server, for viewers events
+	Viewers map[string]viewersSummary `json:"viewers,omitempty"`
 }
 
 func (event Event) String() string {
@@ -41,8 +79,10 @@ func (event Event) String() string {
 		fallthrough
 	case event.Type == eventAdd:
 		str += "Content: " + event.Content + "\n"
-	case event.Type == eventError:
+	case event.Type == eventError || event.Type == eventLag:
 		str += "Message: " + event.Message + "\n"
+	case event.Type == eventViewers:
+		str += fmt.Sprintf("Viewers: %v\n", event.Viewers)
 	default:
 		str += "/!\\ Unknown event type ! /!\\\n"
 	}
@@ -62,3 +102,46 @@ func (event Event) Json() []byte {
 	}
 	return jsonBytes
 }
+
+// encode returns the Event encoded with the given format, ready to be sent to a subscriber
+func (event Event) encode(format messageFormat) []byte {
+	switch format {
+	case formatSSE:
+		return event.sse()
+	case formatPlain:
+		return event.plain()
+	default:
+		eventMsg := event.Json()
+		encodeLength := base64.StdEncoding.EncodedLen(len(eventMsg))
+		encodedEventMsg := make([]byte, encodeLength, encodeLength+len(messageSeparator))
+		base64.StdEncoding.Encode(encodedEventMsg, eventMsg)
+		return append(encodedEventMsg, messageSeparator...)
+	}
+}
+
+// sse returns the Server-Sent Events version of the Event
+func (event Event) sse() []byte {
+	var buf bytes.Buffer
+	if event.ID != 0 {
+		buf.WriteString("id: " + strconv.FormatUint(event.ID, 10) + "\n")
+	}
+	buf.WriteString("event: " + strings.ToLower(event.Type) + "\n")
+	data := event.Content
+	if event.Type == eventError || event.Type == eventLag {
+		data = event.Message
+	}
+	// each line of a multi-lines data must have its own field
+	for _, line := range strings.Split(data, "\n") {
+		buf.WriteString("data: " + line + "\n")
+	}
+	buf.WriteString("\n")
+	return buf.Bytes()
+}
+
+// plain returns the Event as it would be printed by `tail -f`, or nothing if it does not hold any log line
+func (event Event) plain() []byte {
+	if event.Type != eventAdd {
+		return nil
+	}
+	return []byte(event.Content + "\n")
+}
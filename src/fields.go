@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// logField is a named value of a log line, extracted by the fields regexp of its server or read from a JSON line
+type logField struct {
+	key   string
+	value string
+}
+
+// fieldsRule extracts the fields of the log lines with the named groups of a regexp
+type fieldsRule struct {
+	regexp *regexp.Regexp
+	// The names of the groups, in their order in the regexp
+	names []string
+	// The highlighter of the server, whose formatting codes are removed before matching the lines
+	highlighter *lineHighlighter
+}
+
+// compileFieldsRule returns the rule of the given regexp, or nil if fields must not be extracted
+func compileFieldsRule(fieldsRegexp string, highlighter *lineHighlighter) (*fieldsRule, error) {
+	if fieldsRegexp == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile(fieldsRegexp)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex: %w", err)
+	}
+	rule := &fieldsRule{regexp: re, highlighter: highlighter}
+	for _, name := range re.SubexpNames() {
+		if name != "" {
+			rule.names = append(rule.names, name)
+		}
+	}
+	if len(rule.names) == 0 {
+		return nil, errors.New("the regex has no named group, like (?P<status>\\d+)")
+	}
+	return rule, nil
+}
+
+// extract returns the fields of the given line, in the order of the groups that matched, or nil if the line does not match
+func (rule *fieldsRule) extract(line string) []logField {
+	if rule == nil {
+		return nil
+	}
+	text := rule.highlighter.stripFormatting(line)
+	loc := rule.regexp.FindStringSubmatchIndex(text)
+	if loc == nil {
+		return nil
+	}
+	var fields []logField
+	for i, name := range rule.regexp.SubexpNames() {
+		if name != "" && loc[2*i] >= 0 {
+			fields = append(fields, logField{key: name, value: text[loc[2*i]:loc[2*i+1]]})
+		}
+	}
+	return fields
+}
+
+// fieldsMap returns the given fields by key, or nil if there are none
+func fieldsMap(fields []logField) map[string]string {
+	if len(fields) == 0 {
+		return nil
+	}
+	values := make(map[string]string, len(fields))
+	for _, field := range fields {
+		values[field.key] = field.value
+	}
+	return values
+}
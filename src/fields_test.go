@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const nginxFieldsRegexp = `^(?P<ip>\S+) \S+ \S+ \[[^\]]+] "(?P<method>\S+) (?P<path>\S+)[^"]*" (?P<status>\d{3}) (?P<bytes>\d+|-)(?: "[^"]*" "(?P<ua>[^"]*)")?`
+
+func TestFieldsExtraction(t *testing.T) {
+	rule, err := compileFieldsRule(nginxFieldsRegexp, &lineHighlighter{ansi: ansiRender})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ip", "method", "path", "status", "bytes", "ua"}, rule.names)
+
+	assert.Equal(t, []logField{
+		{key: "ip", value: "127.0.0.1"},
+		{key: "method", value: "GET"},
+		{key: "path", value: "/index.html"},
+		{key: "status", value: "200"},
+		{key: "bytes", value: "512"},
+		{key: "ua", value: "curl/8.0"},
+	}, rule.extract(`127.0.0.1 - - [17/Oct/2026:12:00:00 +0200] "GET /index.html HTTP/1.1" 200 512 "-" "curl/8.0"`))
+	assert.Len(t, rule.extract(`10.0.0.1 - - [17/Oct/2026:12:00:01 +0200] "POST /api HTTP/1.1" 404 -`), 5, "The groups that did not match should be omitted")
+	assert.Nil(t, rule.extract("not an access log"))
+
+	var noRule *fieldsRule
+	assert.Nil(t, noRule.extract("127.0.0.1"))
+}
+
+func TestFieldsConfig(t *testing.T) {
+	rule, err := compileFieldsRule("", nil)
+	assert.NoError(t, err)
+	assert.Nil(t, rule)
+
+	_, err = compileFieldsRule(`^(\S+) (\d+)`, nil)
+	assert.Error(t, err, "Fields need named groups")
+	_, err = compileFieldsRule(`^(?P<ip>\S+`, nil)
+	assert.Error(t, err)
+}
+
+func TestTableColumns(t *testing.T) {
+	fields, _ := compileFieldsRule(`^(?P<status>\d{3}) (?P<path>\S+)`, nil)
+	parser := &logParser{fields: fields}
+	rows := parser.renderLogs([]string{"200 /", "404 /missing", "no fields", ""}, time.Now(), nil)
+	assert.Equal(t, map[string]string{"status": "404", "path": "/missing"}, rows[1].Fields)
+	assert.Nil(t, rows[2].Fields)
+	assert.Equal(t, []string{"status", "path"}, parser.columns(rows))
+
+	format, _ := JsonFormatConfig{}.compile(nil)
+	parser = &logParser{json: format}
+	rows = parser.renderLogs([]string{`{"msg":"a","user_id":1}`, `{"msg":"b","http":{"status":500},"user_id":2}`}, time.Now(), nil)
+	assert.Equal(t, map[string]string{"msg": "b", "http.status": "500", "user_id": "2"}, rows[1].Fields)
+	assert.Equal(t, []string{"msg", "http.status", "user_id"}, parser.columns(rows), "The message should come first")
+
+	assert.Nil(t, (&logParser{}).columns(rows), "No table without fields")
+}
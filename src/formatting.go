@@ -0,0 +1,114 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// formattingCodeRegexp matches the ANSI Control Sequence Introducer sequences (SGR ones ending with 'm'), in its first group,
+// and the Minecraft formatting codes, in its fourth group.
+// The escape char of the ANSI sequences is optional because some loggers lose it when writing to a file
+var formattingCodeRegexp = regexp.MustCompile(`(\x1b?\[([0-9;:]*)([A-Za-z]))|(§(?:[xX](?:§[0-9a-fA-F]){6}|[0-9a-fA-Fk-oK-OrR]))`)
+
+// ampersandFormattingCodeRegexp is formattingCodeRegexp also matching the Minecraft formatting codes written with &,
+// like the ones of the plugins configs, which would match the & of the urls or of words like R&D otherwise
+var ampersandFormattingCodeRegexp = regexp.MustCompile(`(\x1b?\[([0-9;:]*)([A-Za-z]))|([§&](?:[xX](?:[§&][0-9a-fA-F]){6}|[0-9a-fA-Fk-oK-OrR]))`)
+
+// textStyle is the style set by the formatting codes met so far in a log line
+type textStyle struct {
+	// The CSS color of the text
+	foreground string
+	// The CSS declarations setting the color of the text, from the Minecraft palette, used when there is no foreground
+	colorStyle string
+	background string
+
+	bold, faint, italic, underline, strikethrough bool
+}
+
+// styledRun is a part of a log line, from its start to the start of the next run, with the same style
+type styledRun struct {
+	start int
+	style string
+}
+
+// setForeground sets the CSS color of the text
+func (state *textStyle) setForeground(color string) {
+	state.foreground = color
+	state.colorStyle = ""
+}
+
+// css returns the CSS declarations of the style, or an empty string if it is the default one
+func (state textStyle) css() string {
+	var declarations []string
+	if state.foreground != "" {
+		declarations = append(declarations, "color: "+state.foreground+";")
+	} else if state.colorStyle != "" {
+		declarations = append(declarations, state.colorStyle)
+	}
+	if state.background != "" {
+		declarations = append(declarations, "background-color: "+state.background+";")
+	}
+	if state.bold {
+		declarations = append(declarations, "font-weight: bold;")
+	}
+	if state.faint {
+		declarations = append(declarations, "opacity: 0.7;")
+	}
+	if state.italic {
+		declarations = append(declarations, "font-style: italic;")
+	}
+	switch {
+	case state.underline && state.strikethrough:
+		declarations = append(declarations, "text-decoration: underline line-through;")
+	case state.underline:
+		declarations = append(declarations, "text-decoration: underline;")
+	case state.strikethrough:
+		declarations = append(declarations, "text-decoration: line-through;")
+	}
+	return strings.Join(declarations, " ")
+}
+
+// parseFormatting removes the ANSI escape codes and Minecraft formatting codes of the given line, according to the modes of the highlighter.
+// When render is true, it also returns the styled runs of the resulting text for the codes that must be rendered.
+func (highlighter *lineHighlighter) parseFormatting(line string, render bool) (text string, runs []styledRun) {
+	removeAnsi := highlighter.ansi != ansiRaw
+	removeMinecraft := highlighter.minecraft != minecraftNone
+	if !removeAnsi && !removeMinecraft {
+		return line, nil
+	}
+
+	var builder strings.Builder
+	var state textStyle
+	pos := 0
+	codeRegexp := formattingCodeRegexp
+	if highlighter.minecraftAmpersand {
+		codeRegexp = ampersandFormattingCodeRegexp
+	}
+	for _, loc := range codeRegexp.FindAllStringSubmatchIndex(line, -1) {
+		isAnsi := loc[2] >= 0
+		if isAnsi && (!removeAnsi || !isAnsiSequence(line[loc[0]:loc[1]])) || !isAnsi && !removeMinecraft {
+			continue
+		}
+		builder.WriteString(line[pos:loc[0]])
+		pos = loc[1]
+		if !render {
+			continue
+		}
+		switch {
+		case isAnsi && highlighter.ansi == ansiRender && line[loc[6]:loc[7]] == "m":
+			state.applySGR(line[loc[4]:loc[5]])
+		case !isAnsi && highlighter.minecraft == minecraftRender:
+			state.applyMinecraftCode(line[loc[8]:loc[9]], &highlighter.minecraftPalette)
+		default:
+			continue // cursor moves, line erasing...: nothing to render
+		}
+		style := state.css()
+		if len(runs) > 0 && runs[len(runs)-1].start == builder.Len() {
+			runs[len(runs)-1].style = style
+		} else if len(runs) > 0 || style != "" {
+			runs = append(runs, styledRun{start: builder.Len(), style: style})
+		}
+	}
+	builder.WriteString(line[pos:])
+	return builder.String(), runs
+}
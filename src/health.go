@@ -0,0 +1,180 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const defaultHealthcheckUrl = "http://localhost:8080/readyz"
+
+// watcherState represents the state of the watcher of a log file
+type watcherState string
+
+const (
+	stateWatching watcherState = "watching"
+	// the log file has been renamed or removed, or does not exist yet, and the watcher is waiting before watching it again
+	stateWaiting watcherState = "waiting for file"
+	// the watcher has stopped because of an error
+	stateErrored watcherState = "errored"
+	// the watcher has stopped because its log file does not exist anymore, like the ones of the ended dynamic instances
+	stateStopped watcherState = "stopped"
+)
+
+// sourceHealth represents the health of the watcher of a log file
+type sourceHealth struct {
+	State watcherState `json:"state"`
+	// The time of the last event received from the log file, if any
+	LastEvent *time.Time `json:"last-event,omitempty"`
+	Error     string     `json:"error,omitempty"`
+}
+
+// discoveryHealth represents the health of the instances discovery of a dynamic server
+type discoveryHealth struct {
+	// The time of the last instances discovery, nil if none has been done yet
+	LastDiscovery *time.Time `json:"last-discovery"`
+	// The number of instances currently watched
+	Instances int    `json:"instances"`
+	Error     string `json:"error,omitempty"`
+}
+
+// healthRegistry holds the health of every log file watcher and dynamic server, and can be safely used by several goroutines
+type healthRegistry struct {
+	mutex sync.Mutex
+	// The watchers of the log files, by server name with the format used by the websocket (server or server=>instance)
+	sources map[string]*sourceHealth
+	// The instances discoveries, by dynamic server tag
+	discoveries map[string]*discoveryHealth
+}
+
+var health = newHealthRegistry()
+
+func newHealthRegistry() *healthRegistry {
+	return &healthRegistry{
+		sources:     make(map[string]*sourceHealth),
+		discoveries: make(map[string]*discoveryHealth),
+	}
+}
+
+// setSourceState updates the state of the watcher of the given server, with the error that made it stop or wait if any
+func (registry *healthRegistry) setSourceState(servName string, state watcherState, err error) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	source, found := registry.sources[servName]
+	if !found {
+		source = new(sourceHealth)
+		registry.sources[servName] = source
+	}
+	source.State = state
+	source.Error = ""
+	if err != nil {
+		source.Error = err.Error()
+	}
+}
+
+// recordSourceEvent updates the time of the last event received by the watcher of the given server
+func (registry *healthRegistry) recordSourceEvent(servName string) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	if source, found := registry.sources[servName]; found {
+		now := time.Now()
+		source.LastEvent = &now
+	}
+}
+
+// recordDiscovery updates the health of the instances discovery of the given dynamic server
+func (registry *healthRegistry) recordDiscovery(serverTag string, instances int, err error) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	now := time.Now()
+	discovery := &discoveryHealth{LastDiscovery: &now, Instances: instances}
+	if err != nil {
+		discovery.Error = err.Error()
+	}
+	registry.discoveries[serverTag] = discovery
+}
+
+// registerDiscovery makes the given dynamic server known before its first instances discovery
+func (registry *healthRegistry) registerDiscovery(serverTag string) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	registry.discoveries[serverTag] = new(discoveryHealth)
+}
+
+// healthReport is the readiness report sent by /readyz
+type healthReport struct {
+	Sources        map[string]sourceHealth    `json:"sources"`
+	DynamicServers map[string]discoveryHealth `json:"dynamic-servers"`
+}
+
+// report returns a copy of the health of every source and dynamic server,
+// and whether they are all ready or not
+func (registry *healthRegistry) report() (ready bool, report healthReport) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	ready = true
+	report = healthReport{
+		Sources:        make(map[string]sourceHealth, len(registry.sources)),
+		DynamicServers: make(map[string]discoveryHealth, len(registry.discoveries)),
+	}
+	for servName, source := range registry.sources {
+		report.Sources[servName] = *source
+		if source.State == stateErrored {
+			ready = false
+		}
+	}
+	for serverTag, discovery := range registry.discoveries {
+		report.DynamicServers[serverTag] = *discovery
+		if discovery.LastDiscovery == nil || discovery.Error != "" {
+			ready = false
+		}
+	}
+	return ready, report
+}
+
+// healthzHandler tells that the process is alive
+func healthzHandler(w http.ResponseWriter) {
+	prettier(w, "Alive", nil, http.StatusOK)
+}
+
+// readyzHandler sends the state of every watcher and dynamic server discovery,
+// with a 503 status if one of them is not ready
+func readyzHandler(w http.ResponseWriter) {
+	ready, report := health.report()
+	if ready {
+		prettier(w, "Ready", report, http.StatusOK)
+	} else {
+		prettier(w, "Not ready", report, http.StatusServiceUnavailable)
+	}
+}
+
+// runHealthcheck executes the healthcheck subcommand with the given args, and returns the exit code.
+// It requests the given url and succeeds if the response status is 2xx, which can be used as a Docker HEALTHCHECK.
+func runHealthcheck(args []string) int {
+	flags := flag.NewFlagSet("healthcheck", flag.ContinueOnError)
+	url := flags.String("url", defaultHealthcheckUrl, "the url of the health or readiness endpoint to check")
+	timeout := flags.Duration("timeout", 5*time.Second, "the maximum duration of the request")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	client := http.Client{Timeout: *timeout}
+	res, err := client.Get(*url)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Healthcheck failed:", err)
+		return 1
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 4096))
+		fmt.Fprintf(os.Stderr, "Healthcheck failed with status %q: %s\n", res.Status, body)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthReport(t *testing.T) {
+	registry := newHealthRegistry()
+	registry.setSourceState("serv", stateWatching, nil)
+	registry.registerDiscovery("dyn")
+
+	ready, report := registry.report()
+	assert.False(t, ready, "Not ready before the first instances discovery")
+	assert.Nil(t, report.DynamicServers["dyn"].LastDiscovery)
+
+	registry.recordDiscovery("dyn", 2, nil)
+	registry.setSourceState(joinWSServer("dyn", "1"), stateWatching, nil)
+	registry.recordSourceEvent(joinWSServer("dyn", "1"))
+	ready, report = registry.report()
+	assert.True(t, ready)
+	assert.Equal(t, 2, report.DynamicServers["dyn"].Instances)
+	assert.NotNil(t, report.Sources[joinWSServer("dyn", "1")].LastEvent)
+	assert.Nil(t, report.Sources["serv"].LastEvent)
+
+	registry.setSourceState("serv", stateWaiting, nil)
+	ready, _ = registry.report()
+	assert.True(t, ready, "Waiting for a file is not an error")
+
+	registry.setSourceState("serv", stateErrored, errors.New("log file not found"))
+	ready, report = registry.report()
+	assert.False(t, ready, "An errored watcher must make the readiness fail")
+	assert.Equal(t, "log file not found", report.Sources["serv"].Error)
+
+	// a dynamic instance that has normally ended is still reported
+	registry.setSourceState("serv", stateWatching, nil)
+	registry.setSourceState(joinWSServer("dyn", "1"), stateStopped, nil)
+	ready, report = registry.report()
+	assert.True(t, ready, "A stopped watcher is not an error")
+	assert.Equal(t, stateStopped, report.Sources[joinWSServer("dyn", "1")].State)
+}
+
+func TestHealthcheckSubcommand(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	assert.Equal(t, 0, runHealthcheck([]string{"--url", server.URL}))
+	status = http.StatusServiceUnavailable
+	assert.Equal(t, 1, runHealthcheck([]string{"--url", server.URL}))
+	assert.Equal(t, 1, runHealthcheck([]string{"--url", "http://127.0.0.1:1/readyz"}), "Unreachable server")
+	assert.Equal(t, 2, runHealthcheck([]string{"--unknown-flag"}))
+}
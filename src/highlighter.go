@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// highlightGroupName is the name of the regexp group selecting the part of the match to highlight,
+// which replaces the lookbehinds that RE2 does not support
+const highlightGroupName = "highlight"
+
+// highlightRule is a compiled syntax highlighting rule
+type highlightRule struct {
+	field  string
+	regexp *regexp.Regexp
+	// The index of the group to highlight, 0 for the whole match
+	group int
+	style string
+}
+
+// lineHighlighter turns log lines into HTML-escaped markup, with the syntax highlighting rules of a server
+type lineHighlighter struct {
+	rules []highlightRule
+	// What to do with the ANSI escape codes, before applying the rules
+	ansi ansiMode
+	// What to do with the Minecraft formatting codes, before applying the rules
+	minecraft minecraftMode
+	// Whether the Minecraft formatting codes can also start with &, like &c
+	minecraftAmpersand bool
+	// The CSS declarations of the Minecraft colors
+	minecraftPalette [16]string
+}
+
+// highlightRange is a part of a log line to wrap in a span
+type highlightRange struct {
+	start, end int
+	rule       *highlightRule
+}
+
+// jsLookaroundRegexp matches the beginning of the lookarounds of a JavaScript regexp, which RE2 does not support
+var jsLookaroundRegexp = regexp.MustCompile(`\(\?<?[=!]`)
+
+// compileSyntaxHighlighting compiles the syntax highlighting rules of a server with the given styles.
+// The rules that cannot be used are ignored and returned as errors.
+func compileSyntaxHighlighting(serverTag string, rules SyntaxHighlightingConfig, styles map[string]string) (*lineHighlighter, []error) {
+	var errs []error
+	highlighter := &lineHighlighter{ansi: ansiRaw, minecraftPalette: getMinecraftPalette(styles)}
+	for i, rule := range rules {
+		if rule.Field == "" {
+			errs = append(errs, fmt.Errorf("syntax highlighting rule n°%d of server %q has no field name, it will be ignored", i+1, serverTag))
+			continue
+		}
+		if rule.Regex == "" {
+			continue // matches nothing
+		}
+		re, err := regexp.Compile("(?m)" + rule.Regex)
+		if err != nil {
+			if jsLookaroundRegexp.MatchString(rule.Regex) {
+				err = fmt.Errorf("%w (lookarounds are not supported, a group named %q can be used to only highlight a part of the match)", err, highlightGroupName)
+			}
+			errs = append(errs, fmt.Errorf("invalid regexp of syntax highlighting field %q of server %q, it will be ignored: %w", rule.Field, serverTag, err))
+			continue
+		}
+		group := re.SubexpIndex(highlightGroupName)
+		if group < 0 {
+			group = 0
+		}
+		highlighter.rules = append(highlighter.rules, highlightRule{
+			field:  rule.Field,
+			regexp: re,
+			group:  group,
+			style:  styles[rule.Field],
+		})
+	}
+	return highlighter, errs
+}
+
+// highlight returns the HTML-escaped log line, where the first match of every rule is wrapped in a span with the style of its field.
+// A match that partially overlaps the match of a previous rule is not highlighted, but matches can be nested.
+// The ANSI escape codes and Minecraft formatting codes are handled beforehand, so that the rules apply to the text only.
+func (highlighter *lineHighlighter) highlight(line string) template.HTML {
+	if highlighter == nil {
+		return template.HTML(html.EscapeString(line))
+	}
+	line, runs := highlighter.parseFormatting(line, true)
+
+	var ranges []highlightRange
+	for i := range highlighter.rules {
+		rule := &highlighter.rules[i]
+		loc := rule.regexp.FindStringSubmatchIndex(line)
+		if loc == nil {
+			continue
+		}
+		start, end := loc[2*rule.group], loc[2*rule.group+1]
+		if start < 0 || start == end {
+			continue
+		}
+		overlaps := false
+		for _, other := range ranges {
+			nested := (start >= other.start && end <= other.end) || (other.start >= start && other.end <= end)
+			if start < other.end && other.start < end && !nested {
+				overlaps = true
+				break
+			}
+		}
+		if !overlaps {
+			ranges = append(ranges, highlightRange{start: start, end: end, rule: rule})
+		}
+	}
+	// outer ranges first, and the first rules outside when they match the same part
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].start != ranges[j].start {
+			return ranges[i].start < ranges[j].start
+		}
+		return ranges[i].end > ranges[j].end
+	})
+
+	var builder strings.Builder
+	var openEnds []int
+	pos := 0
+	writeText := func(end int) {
+		writeStyledText(&builder, line, pos, end, runs)
+		pos = end
+	}
+	closeUntil := func(limit int) {
+		for len(openEnds) > 0 && openEnds[len(openEnds)-1] <= limit {
+			writeText(openEnds[len(openEnds)-1])
+			builder.WriteString("</span>")
+			openEnds = openEnds[:len(openEnds)-1]
+		}
+	}
+	for _, r := range ranges {
+		closeUntil(r.start)
+		writeText(r.start)
+		builder.WriteString(`<span data-field="` + html.EscapeString(r.rule.field) + `"`)
+		if r.rule.style != "" {
+			builder.WriteString(` style="` + html.EscapeString(r.rule.style) + `"`)
+		}
+		builder.WriteString(">")
+		openEnds = append(openEnds, r.end)
+	}
+	closeUntil(len(line))
+	writeText(len(line))
+	return template.HTML(builder.String())
+}
+
+// writeStyledText writes the HTML-escaped text of the line between start and end,
+// in spans with the styles of the runs it overlaps
+func writeStyledText(builder *strings.Builder, line string, start, end int, runs []styledRun) {
+	for start < end {
+		style, runEnd := "", end
+		for _, run := range runs {
+			if run.start > start {
+				if run.start < runEnd {
+					runEnd = run.start
+				}
+				break
+			}
+			style = run.style
+		}
+		if style == "" {
+			builder.WriteString(html.EscapeString(line[start:runEnd]))
+		} else {
+			builder.WriteString(`<span style="` + html.EscapeString(style) + `">` + html.EscapeString(line[start:runEnd]) + "</span>")
+		}
+		start = runEnd
+	}
+}
+
+// text returns the log line as it should be read in plain text, without the Minecraft formatting codes.
+// The ANSI escape codes are only removed when they must be stripped, as a terminal can render them.
+func (highlighter *lineHighlighter) text(line string) string {
+	if highlighter == nil {
+		return line
+	}
+	plainHighlighter := *highlighter
+	if plainHighlighter.ansi == ansiRender {
+		plainHighlighter.ansi = ansiRaw
+	}
+	text, _ := plainHighlighter.parseFormatting(line, false)
+	return text
+}
+
+// stripFormatting returns the text of the log line, without the formatting codes that are not left as they are
+func (highlighter *lineHighlighter) stripFormatting(line string) string {
+	if highlighter == nil {
+		return line
+	}
+	text, _ := highlighter.parseFormatting(line, false)
+	return text
+}
+
+// matchingFields returns the fields whose rules match the given log line
+func (highlighter *lineHighlighter) matchingFields(line string) []string {
+	if highlighter == nil {
+		return nil
+	}
+	line = highlighter.stripFormatting(line)
+	var fields []string
+	for _, rule := range highlighter.rules {
+		if rule.regexp.MatchString(line) {
+			fields = append(fields, rule.field)
+		}
+	}
+	return fields
+}
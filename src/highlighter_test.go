@@ -0,0 +1,60 @@
+package main
+
+import (
+	"html/template"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHighlight(t *testing.T) {
+	styles := map[string]string{
+		"time":    "color: #00C5FF;",
+		"error":   "color: #ff7171;",
+		"content": "",
+	}
+	highlighter, errs := compileSyntaxHighlighting("serv", SyntaxHighlightingConfig{
+		{Field: "time", Regex: `^\[\d{2}:\d{2}:\d{2}]`},
+		{Field: "error", Regex: `^\[\d{2}:\d{2}:\d{2}] (?P<highlight>\[.{0,30}/ERROR])`},
+		{Field: "content", Regex: `^\[\d{2}:\d{2}:\d{2}] \[.{0,30}/(INFO|WARN|ERROR)]: (?P<highlight>.*)$`},
+	}, styles)
+	assert.Empty(t, errs)
+
+	assert.Equal(t, template.HTML(
+		`<span data-field="time" style="color: #00C5FF;">[12:34:56]</span> `+
+			`<span data-field="error" style="color: #ff7171;">[Server thread/ERROR]</span>: `+
+			`<span data-field="content">&lt;img src=x onerror=alert(1)&gt;</span>`),
+		highlighter.highlight("[12:34:56] [Server thread/ERROR]: <img src=x onerror=alert(1)>"))
+	assert.Equal(t, template.HTML(`a &amp; &#34;b&#34;`), highlighter.highlight(`a & "b"`), "Lines without matches must be escaped too")
+
+	var noHighlighter *lineHighlighter
+	assert.Equal(t, template.HTML(`&lt;b&gt;`), noHighlighter.highlight("<b>"))
+}
+
+func TestHighlightNesting(t *testing.T) {
+	highlighter, _ := compileSyntaxHighlighting("serv", SyntaxHighlightingConfig{
+		{Field: "line", Regex: `.+`},
+		{Field: "word", Regex: `b+`},
+		{Field: "overlapping", Regex: `bc`},
+	}, nil)
+
+	assert.Equal(t, template.HTML(`<span data-field="line">a<span data-field="word">bb</span>&lt;<span data-field="overlapping">bc</span></span>`),
+		highlighter.highlight("abb<bc"), "Nested matches must be kept")
+	assert.Equal(t, template.HTML(`<span data-field="line">a<span data-field="word">bb</span>c</span>`),
+		highlighter.highlight("abbc"), "Partially overlapping matches must be ignored")
+}
+
+func TestCompileSyntaxHighlighting(t *testing.T) {
+	highlighter, errs := compileSyntaxHighlighting("serv", SyntaxHighlightingConfig{
+		{Field: "info", Regex: `(?<=(^\[\d{2}:\d{2}:\d{2}]) )\[.{0,30}\/INFO]`},
+		{Field: "", Regex: `.*`},
+		{Field: "nothing", Regex: ``},
+		{Field: "valid", Regex: `INFO`},
+	}, nil)
+	if assert.Len(t, errs, 2) {
+		assert.Contains(t, errs[0].Error(), `"info"`)
+		assert.Contains(t, errs[0].Error(), "lookarounds are not supported", "Lookbehinds should be pointed out")
+	}
+	assert.Len(t, highlighter.rules, 1, "Only the valid rules should be kept")
+	assert.Equal(t, []string{"valid"}, highlighter.matchingFields("[INFO] ok"))
+}
@@ -1,10 +1,11 @@
 package main
 
 import (
-	"encoding/base64"
 	"fmt"
 	"net/http"
-	"sync"
+	"sort"
+	"strconv"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
@@ -20,99 +21,400 @@ var (
 	messageSeparator = []byte("\n,,,\n")
 )
 
+const (
+	// The interval at which the hub tries to send their late messages to the lagging clients,
+	// and notifies the clients of the viewers changes
+	laggingClientsFlushInterval = 100 * time.Millisecond
+	// The maximum number of messages that can be coalesced for a lagging client, the next ones are dropped
+	maxCoalescedMessages = 4096
+)
+
 // Hub maintains the set of active clients and broadcasts messages to the
 // clients.
+// Its state is owned by the goroutine executing Hub.run, and must only be modified through its channels.
 type Hub struct {
 	// Registered clients with the server they are subscribed to.
 	clients map[*Client]string
 
-	// Registered clients subscribed to every classic server.
-	clientsByServer      map[string][]*Client
-	clientsByServerMutex *sync.Mutex
+	// Subscribed clients of every classic server and every instance of every dynamic server,
+	// with the format used by the websocket as key (server or server=>instance).
+	subscribers map[string]map[*Client]struct{}
 
-	// Registered clients subscribed to every instance of every dynamic server.
-	clientsByDynamicServer      map[string]map[string][]*Client
-	clientsByDynamicServerMutex *sync.Mutex
+	// The latest events of every server and instance, used to resume streams.
+	history map[string]*eventHistory
 
 	// Register requests from the clients.
 	register chan *Client
 
 	// Unregister requests from clients.
 	unregister chan *Client
+
+	// Subscribe requests from the clients.
+	subscribe chan subscription
+
+	// Registration requests of classic servers and dynamic servers instances.
+	servers chan string
+
+	// What to do with the clients that do not read their messages fast enough
+	slowClientPolicy slowClientPolicy
+
+	// The clients that have late messages or dropped lines to be notified of
+	laggingClients map[*Client]struct{}
+
+	// Whether the viewers of a server have changed since the clients were last notified
+	viewersChanged bool
+
+	// Functions executed by the hub goroutine, to safely read its state
+	inspections chan func()
+
+	// The header set by the authenticating reverse proxy with the name of the user, if any.
+	// It must be set before the web server is started.
+	authUserHeader string
+}
+
+// viewersSummary represents the clients watching the logs of a server
+type viewersSummary struct {
+	Count int `json:"count"`
+	// The names of the viewers, only known when authentication is enabled
+	Names []string `json:"names,omitempty"`
 }
 
-func newHub() *Hub {
+// slowClientPolicy defines what the hub does when the send channel of a client is full
+type slowClientPolicy string
+
+const (
+	// policyCoalesce keeps the messages and sends them all at once when the client is ready
+	policyCoalesce slowClientPolicy = "coalesce"
+	// policyDrop drops the messages and notifies the client of how many lines it missed
+	policyDrop slowClientPolicy = "drop"
+	// policyDisconnect closes the connection with the client
+	policyDisconnect slowClientPolicy = "disconnect"
+)
+
+// subscription represents the request of a client to receive the events of a server
+type subscription struct {
+	client *Client
+	// The server to subscribe to, with the format used by the websocket (server or server=>instance)
+	server string
+	// The ID of the last event received by the client, the events that have been emitted after it are sent again.
+	// 0 means that no event has to be sent again.
+	lastEventID uint64
+	// The number of the latest add events of the server sent to the client when no event is sent again, so that it
+	// starts with them like `tail -f`. They are taken from the history, which no later event can be part of.
+	latestEvents int
+	// An optional channel notified with whether the server has been found or not
+	found chan<- bool
+	// An optional channel notified with the number of latest add events sent to the client once the server is found,
+	// or -1 if the history may have lost the events preceding them
+	latestSent chan<- int
+	// The query filtering the add events sent to the client, nil if they must all be sent
+	filter *logQuery
+	// Why the query of the client cannot be used, in which case it is not subscribed
+	filterError error
+}
+
+func newHub(policy slowClientPolicy) *Hub {
 	return &Hub{
-		clients:                     make(map[*Client]string),
-		clientsByServer:             make(map[string][]*Client),
-		clientsByServerMutex:        new(sync.Mutex),
-		clientsByDynamicServer:      make(map[string]map[string][]*Client),
-		clientsByDynamicServerMutex: new(sync.Mutex),
-		register:                    make(chan *Client),
-		unregister:                  make(chan *Client),
-	}
-}
-
-func (hub *Hub) disconnectClient(client *Client, server string) {
-	if _, ok := hub.clients[client]; ok {
-		client.connected = false
-		delete(hub.clients, client)
-		close(client.send)
-		if serverTag, serverId, isDynamic := parseWSServer(server); isDynamic { // remove client from dynamic servers
-			hub.clientsByDynamicServerMutex.Lock()
-			defer hub.clientsByDynamicServerMutex.Unlock()
-			dynamicServersClients := hub.clientsByDynamicServer[serverTag][serverId]
-			// for instance, instanceClients := range dynamicServersClients {
-			for i, c := range dynamicServersClients {
-				if c == client {
-					hub.clientsByDynamicServer[serverTag][serverId] = append(dynamicServersClients[:i], dynamicServersClients[i+1:]...)
-					return
-				}
-			}
-			// }
-		} else { // remove client from classic servers
-			hub.clientsByServerMutex.Lock()
-			defer hub.clientsByServerMutex.Unlock()
-			serverClients := hub.clientsByServer[server]
-			for i, c := range serverClients {
-				if c == client {
-					hub.clientsByServer[server] = append(serverClients[:i], serverClients[i+1:]...)
-					return
-				}
+		clients:          make(map[*Client]string),
+		subscribers:      make(map[string]map[*Client]struct{}),
+		history:          make(map[string]*eventHistory),
+		register:         make(chan *Client),
+		unregister:       make(chan *Client),
+		subscribe:        make(chan subscription),
+		servers:          make(chan string),
+		slowClientPolicy: policy,
+		laggingClients:   make(map[*Client]struct{}),
+		inspections:      make(chan func()),
+	}
+}
+
+// inspect executes the given function in the hub goroutine, so that it can safely read the hub state
+func (hub *Hub) inspect(fn func()) {
+	done := make(chan struct{})
+	hub.inspections <- func() {
+		fn()
+		close(done)
+	}
+	<-done
+}
+
+// getViewers returns the viewers of every server (or instance) watched by at least one client.
+// It must only be called from the hub goroutine.
+func (hub *Hub) getViewers() map[string]viewersSummary {
+	viewers := make(map[string]viewersSummary)
+	for server, subscribers := range hub.subscribers {
+		if len(subscribers) == 0 {
+			continue
+		}
+		summary := viewersSummary{Count: len(subscribers)}
+		names := make(map[string]struct{})
+		for client := range subscribers {
+			if client.viewer != "" {
+				names[client.viewer] = struct{}{}
 			}
 		}
+		for name := range names {
+			summary.Names = append(summary.Names, name)
+		}
+		sort.Strings(summary.Names)
+		viewers[server] = summary
+	}
+	return viewers
+}
 
+// notifyViewers sends the viewers of every server to all the web clients
+func (hub *Hub) notifyViewers() {
+	hub.viewersChanged = false
+	msg := Event{Type: eventViewers, Viewers: hub.getViewers()}.encode(formatWebSocket)
+	for client := range hub.clients {
+		if client.format == formatWebSocket {
+			hub.trySend(client, msg)
+		}
 	}
 }
 
+// addServer registers the given server, with the format used by the websocket (server or server=>instance),
+// so that clients can subscribe to it. If it is already registered, its subscribers receive a reset event,
+// which preserves their connection between the reboots of a dynamic server instance.
+func (hub *Hub) addServer(server string) {
+	hub.servers <- server
+}
+
 func (hub *Hub) run(eventChan <-chan Event) {
+	flushTicker := time.NewTicker(laggingClientsFlushInterval)
+	defer flushTicker.Stop()
 	for {
 		select {
 		case client := <-hub.register:
-			hub.clients[client] = client.server
+			hub.clients[client] = "" // not subscribed to any server yet
 		case client := <-hub.unregister:
-			hub.disconnectClient(client, hub.clients[client])
+			hub.disconnectClient(client, "")
+		case sub := <-hub.subscribe:
+			hub.handleSubscription(sub)
+		case server := <-hub.servers:
+			hub.handleServerRegistration(server)
 		case evt := <-eventChan:
-			eventMsg := evt.Json()
-			encodeLength := base64.StdEncoding.EncodedLen(len(eventMsg))
-			encodedEventMsg := make([]byte, encodeLength, encodeLength+len(messageSeparator))
-			base64.StdEncoding.Encode(encodedEventMsg, eventMsg)
-			encodedEventMsg = append(encodedEventMsg, messageSeparator...)
-			for _, client := range hub.getClientsSubscribedTo(evt) {
-				if !client.connected {
-					continue
-				}
-				select {
-				case client.send <- encodedEventMsg:
-					// Message sent successfully
-				default:
-					hub.disconnectClient(client, evt.Server)
-				}
+			hub.broadcast(evt)
+		case fn := <-hub.inspections:
+			fn()
+		case <-flushTicker.C:
+			for client := range hub.laggingClients {
+				hub.flush(client)
+			}
+			if hub.viewersChanged {
+				hub.notifyViewers()
 			}
 		}
 	}
 }
 
+// disconnectClient removes the client from the hub and closes its send channel, with the given reason if any.
+// It does nothing if the client has already been disconnected.
+func (hub *Hub) disconnectClient(client *Client, reason string) {
+	server, ok := hub.clients[client]
+	if !ok {
+		return
+	}
+	delete(hub.clients, client)
+	delete(hub.laggingClients, client)
+	if subscribers, found := hub.subscribers[server]; found {
+		delete(subscribers, client)
+		hub.viewersChanged = true
+	}
+	client.closeReason = reason
+	close(client.send)
+}
+
+// trySend sends the message to the client without blocking.
+// If the send channel of the client is full, the slow client policy of the hub is applied.
+// It returns whether the message has been sent (or will be) or not.
+func (hub *Hub) trySend(client *Client, message []byte) bool {
+	if hub.flush(client) {
+		select {
+		case client.send <- message:
+			return true
+		default:
+		}
+	}
+
+	switch hub.slowClientPolicy {
+	case policyCoalesce:
+		if client.coalescedCount < maxCoalescedMessages {
+			client.coalesced = append(client.coalesced, message...)
+			client.coalescedCount++
+			hub.laggingClients[client] = struct{}{}
+			return true
+		}
+		fallthrough // too late, the message is dropped
+	case policyDrop:
+		metrics.add(metricDroppedLines, serverLabels(hub.clients[client]), 1)
+		client.dropped++
+		hub.laggingClients[client] = struct{}{}
+		return false
+	default:
+		metrics.add(metricSlowDisconnections, serverLabels(hub.clients[client]), 1)
+		hub.disconnectClient(client, "client too slow")
+		return false
+	}
+}
+
+// flush tries to send its late messages to a lagging client, preceded by a lag report.
+// It returns whether the client has caught up or not.
+func (hub *Hub) flush(client *Client) bool {
+	if _, lagging := hub.laggingClients[client]; !lagging {
+		return true
+	}
+	if len(client.send)+2 > cap(client.send) {
+		return false // no room for the lag report and the late messages
+	}
+
+	client.totalDropped += client.dropped
+	lagReport := Event{
+		Type:    eventLag,
+		Delayed: client.coalescedCount,
+		Dropped: client.totalDropped,
+	}
+	switch {
+	case client.dropped > 0:
+		lagReport.Message = strconv.Itoa(client.dropped) + " lines dropped because the client is too slow"
+	case client.coalescedCount > 0:
+		lagReport.Message = strconv.Itoa(client.coalescedCount) + " lines delayed because the client is too slow"
+	}
+	if msg := lagReport.encode(client.format); msg != nil {
+		client.send <- msg
+	}
+	if client.coalescedCount > 0 {
+		client.send <- client.coalesced
+	}
+
+	client.coalesced = nil
+	client.coalescedCount = 0
+	client.dropped = 0
+	delete(hub.laggingClients, client)
+	return true
+}
+
+// broadcast gives an ID to the event and sends it to all the subscribers of its server
+func (hub *Hub) broadcast(evt Event) {
+	server := evt.Server
+	if evt.isDynamic {
+		server = joinWSServer(evt.Server, evt.instance)
+	}
+	history, found := hub.history[server]
+	if !found {
+		history = newEventHistory(resumableEventsCount)
+		hub.history[server] = history
+	}
+	evt.ID = history.add(evt)
+
+	encodedEvents := make(map[messageFormat][]byte)
+	var record *logRecord
+	for client := range hub.subscribers[server] {
+		if client.filter != nil && evt.Type == eventAdd {
+			if record == nil {
+				evtRecord := evt.record()
+				record = &evtRecord
+			}
+			if !client.filter.matches(*record) {
+				continue
+			}
+		}
+		encodedEvent, found := encodedEvents[client.format]
+		if !found {
+			encodedEvent = evt.encode(client.format)
+			encodedEvents[client.format] = encodedEvent
+		}
+		if encodedEvent == nil {
+			continue // nothing to send with this format
+		}
+		hub.trySend(client, encodedEvent)
+	}
+}
+
+// handleServerRegistration registers the server if it is unknown, or sends a reset event to its subscribers otherwise
+func (hub *Hub) handleServerRegistration(server string) {
+	if _, found := hub.subscribers[server]; !found {
+		hub.subscribers[server] = make(map[*Client]struct{})
+		return
+	}
+	resetEvent := Event{Type: eventReset, Server: server}
+	if serverTag, serverId, isDynamic := parseWSServer(server); isDynamic {
+		resetEvent = Event{Type: eventReset, Server: serverTag, isDynamic: true, instance: serverId}
+	}
+	hub.broadcast(resetEvent)
+}
+
+// handleSubscription adds the client to the subscribers of the requested server,
+// and sends it again the events it may have missed, or the latest ones it asked for
+func (hub *Hub) handleSubscription(sub subscription) {
+	c := sub.client
+	if _, registered := hub.clients[c]; !registered {
+		if sub.found != nil {
+			sub.found <- false
+		}
+		return // already disconnected
+	}
+
+	if sub.filterError != nil {
+		if sub.found != nil {
+			sub.found <- false
+		}
+		if msg := (Event{Type: eventError, Message: "Invalid query: " + sub.filterError.Error()}).encode(c.format); msg != nil {
+			hub.trySend(c, msg)
+		}
+		return
+	}
+
+	subscribers, found := hub.subscribers[sub.server]
+	if sub.found != nil {
+		sub.found <- found
+	}
+	if !found {
+		debugPrint("Unknown server: " + sub.server)
+		if msg := (Event{Type: eventError, Message: "Unknown server: " + sub.server}).encode(c.format); msg != nil {
+			hub.trySend(c, msg)
+		}
+		return
+	}
+
+	// a client can only be subscribed to one server at a time
+	if previousSubscribers, ok := hub.subscribers[hub.clients[c]]; ok {
+		delete(previousSubscribers, c)
+	}
+	hub.clients[c] = sub.server
+	c.filter = sub.filter
+	subscribers[c] = struct{}{}
+	hub.viewersChanged = true
+
+	history, ok := hub.history[sub.server]
+	var events []Event
+	if ok && sub.lastEventID > 0 {
+		events = history.since(sub.lastEventID)
+	} else if ok && sub.latestEvents > 0 {
+		events = history.latest(sub.latestEvents, c.filter)
+	}
+	if sub.latestSent != nil {
+		if ok && !history.holdsLatestFile() {
+			sub.latestSent <- -1
+		} else {
+			sub.latestSent <- len(events)
+		}
+	}
+	for _, evt := range events {
+		if c.filter != nil && evt.Type == eventAdd && !c.filter.matches(evt.record()) {
+			continue
+		}
+		msg := evt.encode(c.format)
+		if msg == nil {
+			continue
+		}
+		hub.trySend(c, msg)
+		if _, connected := hub.clients[c]; !connected {
+			return
+		}
+	}
+}
+
 // serveWs handles websocket requests from the peer.
 func (hub *Hub) serveWs(w http.ResponseWriter, r *http.Request) {
 	conn, err := upgrader.Upgrade(w, r, nil)
@@ -122,10 +424,10 @@ func (hub *Hub) serveWs(w http.ResponseWriter, r *http.Request) {
 	}
 
 	client := &Client{
-		connected: true,
-		hub:       hub,
-		conn:      conn,
-		send:      make(chan []byte, 256),
+		hub:    hub,
+		conn:   conn,
+		send:   make(chan []byte, 256),
+		viewer: hub.getViewerName(r),
 	}
 	client.hub.register <- client
 
@@ -135,26 +437,19 @@ func (hub *Hub) serveWs(w http.ResponseWriter, r *http.Request) {
 	go client.readPump()
 }
 
-func (hub *Hub) getClientsSubscribedTo(evt Event) []*Client {
-	if evt.isDynamic {
-		if instances, found := hub.clientsByDynamicServer[evt.Server]; found {
-			return instances[evt.instance]
-		}
-		return []*Client{} // server not found
+// getViewerName returns the name of the authenticated user who sent the request, or an empty string
+func (hub *Hub) getViewerName(r *http.Request) string {
+	if hub.authUserHeader == "" {
+		return ""
 	}
-	return hub.clientsByServer[evt.Server]
+	return r.Header.Get(hub.authUserHeader)
 }
 
-func (hub *Hub) sendResetMessage(server, instance string) {
-	resetMessage := Event{
-		Type:      eventReset,
-		Server:    server,
-		isDynamic: true,
-		instance:  instance,
-	}.Json()
-	for _, c := range hub.clientsByDynamicServer[server][instance] {
-		if c.connected {
-			c.send <- resetMessage
-		}
-	}
+// viewersHandler sends the viewers of every server as json
+func viewersHandler(w http.ResponseWriter, hub *Hub) {
+	var viewers map[string]viewersSummary
+	hub.inspect(func() {
+		viewers = hub.getViewers()
+	})
+	prettier(w, "Viewers of every server", viewers, http.StatusOK)
 }
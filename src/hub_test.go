@@ -0,0 +1,432 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// These tests are meant to be run with the race detector (go test -race -run TestHub)
+
+const hubTestTimeout = 10 * time.Second
+
+// newTestClient registers a websocket-less client to the hub
+func newTestClient(hub *Hub, bufferSize int) *Client {
+	client := &Client{
+		hub:  hub,
+		send: make(chan []byte, bufferSize),
+	}
+	hub.register <- client
+	return client
+}
+
+// drain reads the messages sent to the client until its send channel is closed, and returns how many were received
+func drain(client *Client) <-chan int {
+	count := make(chan int, 1)
+	go func() {
+		received := 0
+		for range client.send {
+			received++
+		}
+		count <- received
+	}()
+	return count
+}
+
+// subscribeTestClient subscribes the client to the given server and returns whether the server has been found
+func subscribeTestClient(client *Client, server string) bool {
+	found := make(chan bool, 1)
+	client.hub.subscribe <- subscription{client: client, server: server, found: found}
+	return <-found
+}
+
+func runWithTimeout(t *testing.T, fn func()) {
+	ctx, cancel := context.WithTimeout(context.Background(), hubTestTimeout)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		fn()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		t.Fatalf("Test duration has reached the threshold (%s), the hub is probably blocked", hubTestTimeout)
+	}
+}
+
+func TestHubSubscribeUnsubscribe(t *testing.T) {
+	rand.Seed(time.Now().Unix())
+
+	eventChan := make(chan Event, 16)
+	hub := newHub(policyDisconnect)
+	go hub.run(eventChan)
+
+	servers := []string{"serv-a", "serv-b", joinWSServer("dyn", "1")}
+	for _, server := range servers {
+		hub.addServer(server)
+	}
+
+	runWithTimeout(t, func() {
+		stopEvents := make(chan struct{})
+		eventsDone := new(sync.WaitGroup)
+		eventsDone.Add(1)
+		go func() { // keeps sending events during the whole test
+			defer eventsDone.Done()
+			for i := 0; ; i++ {
+				evt := Event{Type: eventAdd, Server: "serv-a", Content: fmt.Sprint(i)}
+				if i%3 == 1 {
+					evt.Server = "serv-b"
+				} else if i%3 == 2 {
+					evt = Event{Type: eventAdd, Server: "dyn", isDynamic: true, instance: "1", Content: fmt.Sprint(i)}
+				}
+				select {
+				case eventChan <- evt:
+				case <-stopEvents:
+					return
+				}
+				time.Sleep(10 * time.Microsecond) // so as not to overflow the clients
+			}
+		}()
+
+		wg := new(sync.WaitGroup)
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				client := newTestClient(hub, 1024)
+				received := drain(client)
+				for j := 0; j < 10; j++ {
+					server := servers[rand.Intn(len(servers))]
+					assert.True(t, subscribeTestClient(client, server), "Server %q should be known", server)
+				}
+				assert.False(t, subscribeTestClient(client, "unknown"), "Unknown server should not be found")
+				hub.unregister <- client
+				<-received               // the send channel must be closed once unregistered
+				hub.unregister <- client // unregistering twice must not panic
+			}()
+		}
+		wg.Wait()
+
+		close(stopEvents)
+		eventsDone.Wait()
+	})
+}
+
+func TestHubInstanceChurn(t *testing.T) {
+	eventChan := make(chan Event) // unbuffered, so that every sent event is handled before the clients are unregistered
+	hub := newHub(policyDisconnect)
+	go hub.run(eventChan)
+
+	const instancesCount = 10
+	const reboots = 20
+
+	runWithTimeout(t, func() {
+		for i := 0; i < instancesCount; i++ {
+			hub.addServer(joinWSServer("dyn", fmt.Sprint(i)))
+		}
+
+		// one client per instance, that counts the reset events it receives
+		clients := make([]*Client, instancesCount)
+		for i := range clients {
+			clients[i] = newTestClient(hub, 4*reboots)
+			assert.True(t, subscribeTestClient(clients[i], joinWSServer("dyn", fmt.Sprint(i))))
+		}
+
+		wg := new(sync.WaitGroup)
+		for i := 0; i < instancesCount; i++ {
+			wg.Add(2)
+			go func(instance string) { // the instance keeps rebooting
+				defer wg.Done()
+				for j := 0; j < reboots; j++ {
+					hub.addServer(joinWSServer("dyn", instance))
+				}
+			}(fmt.Sprint(i))
+			go func(instance string) { // while writing logs
+				defer wg.Done()
+				for j := 0; j < reboots; j++ {
+					eventChan <- Event{Type: eventAdd, Server: "dyn", isDynamic: true, instance: instance, Content: "log"}
+				}
+			}(fmt.Sprint(i))
+		}
+		wg.Wait()
+
+		for _, client := range clients {
+			received := drain(client)
+			hub.unregister <- client
+			assert.Equal(t, 2*reboots, <-received, "Each client should have received every reset and add event")
+		}
+	})
+}
+
+func TestHubSlowClients(t *testing.T) {
+	eventChan := make(chan Event)
+	hub := newHub(policyDisconnect)
+	go hub.run(eventChan)
+	hub.addServer("serv")
+	hub.addServer(joinWSServer("dyn", "1"))
+
+	runWithTimeout(t, func() {
+		slowClient := newTestClient(hub, 4) // never reads its messages
+		assert.True(t, subscribeTestClient(slowClient, "serv"))
+		slowDynamicClient := newTestClient(hub, 4)
+		assert.True(t, subscribeTestClient(slowDynamicClient, joinWSServer("dyn", "1")))
+		fastClient := newTestClient(hub, 16)
+		assert.True(t, subscribeTestClient(fastClient, "serv"))
+		received := make(chan int, 1)
+		go func() {
+			addEvents := 0
+			for message := range fastClient.send {
+				for _, evt := range decodeTestMessages(t, message) {
+					if evt.Type == eventAdd {
+						addEvents++
+					}
+				}
+			}
+			received <- addEvents
+		}()
+
+		for i := 0; i < 100; i++ {
+			eventChan <- Event{Type: eventAdd, Server: "serv", Content: fmt.Sprint(i)}
+			if i%10 == 0 {
+				// resets must not block the hub either
+				hub.addServer(joinWSServer("dyn", "1"))
+			}
+			time.Sleep(time.Millisecond) // lets the fast client read
+		}
+
+		// the slow clients must have been disconnected, with their send channel closed
+		assert.Equal(t, 4, <-drain(slowClient), "The slow client should have received its buffer size")
+		assert.Equal(t, 4, <-drain(slowDynamicClient), "The slow dynamic client should have received its buffer size")
+
+		hub.unregister <- fastClient
+		assert.Equal(t, 100, <-received, "The fast client should have received every event")
+	})
+}
+
+// decodeTestMessages returns the events contained in the given websocket message
+func decodeTestMessages(t *testing.T, message []byte) []Event {
+	var events []Event
+	for _, data := range bytes.Split(message, messageSeparator) {
+		if len(data) == 0 {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(string(data))
+		if err != nil {
+			t.Fatal("Failed to decode event:", err)
+		}
+		var evt Event
+		if err = json.Unmarshal(decoded, &evt); err != nil {
+			t.Fatal("Failed to unmarshal event:", err)
+		}
+		events = append(events, evt)
+	}
+	return events
+}
+
+// receiveAllTestEvents reads the events sent to the client until none is received for a while
+func receiveAllTestEvents(t *testing.T, client *Client) []Event {
+	var events []Event
+	for {
+		select {
+		case message := <-client.send:
+			events = append(events, decodeTestMessages(t, message)...)
+		case <-time.After(3 * laggingClientsFlushInterval):
+			return events
+		}
+	}
+}
+
+// receiveTestEvents works like receiveAllTestEvents, but ignores the viewers events
+func receiveTestEvents(t *testing.T, client *Client) []Event {
+	var events []Event
+	for _, evt := range receiveAllTestEvents(t, client) {
+		if evt.Type != eventViewers {
+			events = append(events, evt)
+		}
+	}
+	return events
+}
+
+func TestHubSlowClientPolicies(t *testing.T) {
+	const bufferSize = 4
+	const eventsCount = 20
+
+	testPolicy := func(policy slowClientPolicy, check func(events []Event)) {
+		eventChan := make(chan Event)
+		hub := newHub(policy)
+		go hub.run(eventChan)
+		hub.addServer("serv")
+
+		runWithTimeout(t, func() {
+			client := newTestClient(hub, bufferSize)
+			assert.True(t, subscribeTestClient(client, "serv"))
+			for i := 0; i < eventsCount; i++ {
+				eventChan <- Event{Type: eventAdd, Server: "serv", Content: fmt.Sprint(i)}
+			}
+			check(receiveTestEvents(t, client))
+
+			// once caught up, the client receives the next events normally
+			eventChan <- Event{Type: eventAdd, Server: "serv", Content: "next"}
+			events := receiveTestEvents(t, client)
+			if assert.Len(t, events, 1, "Policy %s", policy) {
+				assert.Equal(t, "next", events[0].Content, "Policy %s", policy)
+			}
+			hub.unregister <- client
+		})
+	}
+
+	testPolicy(policyCoalesce, func(events []Event) {
+		var contents []string
+		var lagReports []Event
+		for _, evt := range events {
+			if evt.Type == eventLag {
+				lagReports = append(lagReports, evt)
+			} else {
+				contents = append(contents, evt.Content)
+			}
+		}
+		if assert.Len(t, contents, eventsCount, "Every line should have been received") {
+			for i, content := range contents {
+				assert.Equal(t, fmt.Sprint(i), content, "Lines should have been received in order")
+			}
+		}
+		if assert.Len(t, lagReports, 1, "The client should have received a lag report") {
+			assert.Equal(t, eventsCount-bufferSize, lagReports[0].Delayed)
+			assert.Equal(t, 0, lagReports[0].Dropped)
+		}
+	})
+
+	testPolicy(policyDrop, func(events []Event) {
+		if assert.Len(t, events, bufferSize+1) {
+			for i := 0; i < bufferSize; i++ {
+				assert.Equal(t, fmt.Sprint(i), events[i].Content)
+			}
+			lagReport := events[bufferSize]
+			assert.Equal(t, eventLag, lagReport.Type, "The client should have received a lag report")
+			assert.Equal(t, eventsCount-bufferSize, lagReport.Dropped)
+			assert.Equal(t, 0, lagReport.Delayed)
+		}
+	})
+}
+
+func TestHubViewers(t *testing.T) {
+	eventChan := make(chan Event)
+	hub := newHub(policyDisconnect)
+	go hub.run(eventChan)
+	hub.addServer("serv")
+	hub.addServer(joinWSServer("dyn", "1"))
+
+	runWithTimeout(t, func() {
+		alice := newTestClient(hub, 16)
+		alice.viewer = "alice"
+		bob := newTestClient(hub, 16)
+		bob.viewer = "bob"
+		anonymous := newTestClient(hub, 16)
+		for _, client := range []*Client{alice, bob, anonymous} {
+			assert.True(t, subscribeTestClient(client, "serv"))
+		}
+		// the same user watching twice
+		aliceAgain := newTestClient(hub, 16)
+		aliceAgain.viewer = "alice"
+		assert.True(t, subscribeTestClient(aliceAgain, joinWSServer("dyn", "1")))
+		assert.True(t, subscribeTestClient(aliceAgain, joinWSServer("dyn", "1")))
+
+		var viewers map[string]viewersSummary
+		hub.inspect(func() {
+			viewers = hub.getViewers()
+		})
+		assert.Equal(t, map[string]viewersSummary{
+			"serv":                   {Count: 3, Names: []string{"alice", "bob"}},
+			joinWSServer("dyn", "1"): {Count: 1, Names: []string{"alice"}},
+		}, viewers)
+
+		// every client is notified of the change
+		events := receiveAllTestEvents(t, bob)
+		if assert.NotEmpty(t, events) {
+			lastEvent := events[len(events)-1]
+			assert.Equal(t, eventViewers, lastEvent.Type)
+			assert.Equal(t, viewers, lastEvent.Viewers)
+		}
+
+		hub.unregister <- bob
+		hub.unregister <- aliceAgain
+		hub.inspect(func() {
+			viewers = hub.getViewers()
+		})
+		assert.Equal(t, map[string]viewersSummary{"serv": {Count: 2, Names: []string{"alice"}}}, viewers)
+	})
+}
+
+func TestHubFilters(t *testing.T) {
+	eventChan := make(chan Event)
+	hub := newHub(policyDisconnect)
+	go hub.run(eventChan)
+	hub.addServer("serv")
+
+	runWithTimeout(t, func() {
+		filter, _ := parseQuery("level>=warn")
+		filtered := newTestClient(hub, 16)
+		filtered.format = formatPlain
+		found := make(chan bool, 1)
+		hub.subscribe <- subscription{client: filtered, server: "serv", found: found, filter: filter}
+		assert.True(t, <-found)
+		unfiltered := newTestClient(hub, 16)
+		unfiltered.format = formatPlain
+		assert.True(t, subscribeTestClient(unfiltered, "serv"))
+
+		eventChan <- Event{Type: eventAdd, Server: "serv", Content: "started", Level: levelInfo}
+		eventChan <- Event{Type: eventAdd, Server: "serv", Content: "overloaded", Level: levelWarn}
+		hub.unregister <- filtered
+		hub.unregister <- unfiltered
+		assert.Equal(t, 1, <-drain(filtered), "Only the events matching the filter should be sent")
+		assert.Equal(t, 2, <-drain(unfiltered))
+
+		invalid := newTestClient(hub, 16)
+		invalid.handleMessage("serv?q=" + url.QueryEscape("level>=loud"))
+		events := receiveTestEvents(t, invalid)
+		if assert.Len(t, events, 1) {
+			assert.Equal(t, eventError, events[0].Type)
+		}
+		var subscribed string
+		hub.inspect(func() {
+			subscribed = hub.clients[invalid]
+		})
+		assert.Empty(t, subscribed, "A client with an invalid query should not be subscribed")
+	})
+}
+
+func TestHubLatestEvents(t *testing.T) {
+	eventChan := make(chan Event)
+	hub := newHub(policyDisconnect)
+	go hub.run(eventChan)
+	hub.addServer("serv")
+
+	runWithTimeout(t, func() {
+		for _, content := range []string{"a", "b", "c"} {
+			eventChan <- Event{Type: eventAdd, Server: "serv", Content: content}
+		}
+		client := newTestClient(hub, 16)
+		client.format = formatPlain
+		found := make(chan bool, 1)
+		hub.subscribe <- subscription{client: client, server: "serv", latestEvents: 2, found: found}
+		assert.True(t, <-found)
+		eventChan <- Event{Type: eventAdd, Server: "serv", Content: "d"}
+		hub.unregister <- client
+
+		var received []string
+		for msg := range client.send {
+			received = append(received, string(msg))
+		}
+		assert.Equal(t, []string{"b\n", "c\n", "d\n"}, received, "Every line should be sent once, the latest ones first")
+	})
+}
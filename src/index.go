@@ -0,0 +1,696 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const (
+	indexManifestName = "manifest.json"
+	indexSegmentMagic = "LRIDX1\n"
+	// The longest token indexed, the longer ones being most likely hashes or encoded data
+	maxIndexedTokenLength = 64
+	// The offset of the positions of the tokens of the lines without their formatting codes,
+	// which are indexed after the tokens of the raw lines when they are different
+	strippedTokensOffset = 1 << 16
+	// The token recorded when some tokens of an archive are not indexed, which cannot be produced by indexTokens
+	droppedTokensMarker = ""
+)
+
+// indexStripHighlighter removes all the formatting codes of the lines, so that the index holds the words they split
+var indexStripHighlighter = &lineHighlighter{ansi: ansiStrip, minecraft: minecraftStrip}
+
+// indexedArchive is an archive known by the index, which is indexed again when its size or modification time change
+type indexedArchive struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod-time"`
+	// The name of the segment file holding the tokens of the archive, empty if it does not fit in the size budget
+	Segment     string `json:"segment,omitempty"`
+	SegmentSize int64  `json:"segment-size,omitempty"`
+}
+
+// indexManifest lists the archives of a directory known by the index, by path relative to the directory
+type indexManifest struct {
+	Dir      string                    `json:"dir"`
+	Archives map[string]indexedArchive `json:"archives"`
+}
+
+// archiveIndex is the optional on-disk token index of the archived log files, made of a directory per archives directory,
+// with a segment file per archive. It can be safely used by several goroutines.
+type archiveIndex struct {
+	root string
+	// The maximum total size of the segments, 0 if it is unlimited
+	maxSize int64
+
+	mutex sync.Mutex
+	// The manifests of every archives directory, by path
+	manifests map[string]*indexManifest
+	// The archives directories being indexed in the background
+	updating map[string]bool
+}
+
+// indexPosting is an occurrence of a token: its line in the archive, from 1, and its position in the line
+type indexPosting struct {
+	line, position uint32
+}
+
+// indexSegment holds the occurrences of the tokens of an archive
+type indexSegment map[string][]indexPosting
+
+// archivesIndex is the index of the archived log files, nil if it is not enabled
+var archivesIndex *archiveIndex
+
+// newArchiveIndex returns the index stored in the given directory, with the manifests it already holds
+func newArchiveIndex(root string, maxSize int64) (*archiveIndex, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create the index directory: %w", err)
+	}
+	index := &archiveIndex{
+		root:      root,
+		maxSize:   maxSize,
+		manifests: make(map[string]*indexManifest),
+		updating:  make(map[string]bool),
+	}
+	manifestPaths, err := filepath.Glob(filepath.Join(root, "*", indexManifestName))
+	if err != nil {
+		return nil, err
+	}
+	for _, manifestPath := range manifestPaths {
+		content, err := os.ReadFile(manifestPath)
+		if err != nil {
+			return nil, err
+		}
+		manifest := new(indexManifest)
+		if err = json.Unmarshal(content, manifest); err != nil || manifest.Archives == nil {
+			printError(fmt.Errorf("ignoring the invalid index manifest %s: %v", manifestPath, err))
+			continue
+		}
+		index.manifests[manifest.Dir] = manifest
+	}
+	return index, nil
+}
+
+// startArchivesIndex enables the index of the archived log files if a directory is configured for it
+func startArchivesIndex(config Config) error {
+	if config.ArchivesIndex.Dir == "" {
+		return nil
+	}
+	index, err := newArchiveIndex(config.PathPrefix+config.ArchivesIndex.Dir, config.ArchivesIndex.maxSize)
+	if err != nil {
+		return err
+	}
+	archivesIndex = index
+	return nil
+}
+
+// indexTokens returns the lowercased words of the given text, made of letters and digits
+func indexTokens(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// hashedName returns a file name derived from the given path
+func hashedName(path string) string {
+	sum := sha1.Sum([]byte(path))
+	return hex.EncodeToString(sum[:8])
+}
+
+// dirOf returns the directory holding the index of the given archives directory
+func (index *archiveIndex) dirOf(archivesDir string) string {
+	return filepath.Join(index.root, hashedName(archivesDir))
+}
+
+// getManifest returns the manifest of the given archives directory, which is created if it is not known yet.
+// The mutex of the index must be held.
+func (index *archiveIndex) getManifest(archivesDir string) *indexManifest {
+	manifest, found := index.manifests[archivesDir]
+	if !found {
+		manifest = &indexManifest{Dir: archivesDir, Archives: make(map[string]indexedArchive)}
+		index.manifests[archivesDir] = manifest
+	}
+	return manifest
+}
+
+// saveManifest writes the given manifest, replacing the previous one at once. The mutex of the index must be held.
+func (index *archiveIndex) saveManifest(manifest *indexManifest) error {
+	content, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	dir := index.dirOf(manifest.Dir)
+	tmpPath := filepath.Join(dir, indexManifestName+".tmp")
+	if err = os.WriteFile(tmpPath, content, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, filepath.Join(dir, indexManifestName))
+}
+
+// forget removes the index of the given archives directory, so that all its archives are indexed again on the next update
+func (index *archiveIndex) forget(archivesDir string) error {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+	delete(index.manifests, archivesDir)
+	return os.RemoveAll(index.dirOf(archivesDir))
+}
+
+// updateInBackground indexes the given archives of a directory in a new goroutine,
+// unless the directory is already being indexed. It does nothing if the index is not enabled.
+func (index *archiveIndex) updateInBackground(archivesDir string, entries []archiveEntry) {
+	if index == nil {
+		return
+	}
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+	if index.updating[archivesDir] {
+		return
+	}
+	index.updating[archivesDir] = true
+	go func() {
+		if _, err := index.update(archivesDir, entries); err != nil {
+			printError(fmt.Errorf("failed to index the archives of %s: %w", archivesDir, err))
+		}
+		index.mutex.Lock()
+		delete(index.updating, archivesDir)
+		index.mutex.Unlock()
+	}()
+}
+
+// update indexes the given archives of a directory whose size or modification time have changed, and forgets the ones
+// that have been removed. It returns the number of archives indexed.
+func (index *archiveIndex) update(archivesDir string, entries []archiveEntry) (int, error) {
+	current := make(map[string]archiveEntry, len(entries))
+	for _, entry := range entries {
+		current[entry.key()] = entry
+	}
+
+	index.mutex.Lock()
+	manifest := index.getManifest(archivesDir)
+	var outdated []string
+	for name, indexed := range manifest.Archives {
+		if entry, found := current[name]; !found || entry.size != indexed.Size || !entry.modTime.Equal(indexed.ModTime) {
+			outdated = append(outdated, name)
+		}
+	}
+	var added []string
+	for name, entry := range current {
+		if indexed, found := manifest.Archives[name]; !found || entry.size != indexed.Size || !entry.modTime.Equal(indexed.ModTime) {
+			added = append(added, name)
+		}
+	}
+	index.mutex.Unlock()
+	if len(outdated) == 0 && len(added) == 0 {
+		return 0, nil
+	}
+
+	dir := index.dirOf(archivesDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return 0, err
+	}
+	// the newest archives are indexed first, as they are the most likely to be searched
+	sort.Slice(added, func(i, j int) bool {
+		return current[added[i]].modTime.After(current[added[j]].modTime)
+	})
+	indexed := make(map[string]indexedArchive, len(added))
+	for _, name := range added {
+		entry := current[name]
+		segmentName := hashedName(name) + ".idx"
+		location := archiveLocation{path: filepath.Join(archivesDir, entry.relativePath), member: entry.member}
+		segmentSize, err := writeIndexSegment(location, filepath.Join(dir, segmentName))
+		if err != nil {
+			// the archive is not indexed again until it changes
+			printError(fmt.Errorf("failed to index archive %s: %w", name, err))
+			indexed[name] = indexedArchive{Size: entry.size, ModTime: entry.modTime}
+			continue
+		}
+		indexed[name] = indexedArchive{Size: entry.size, ModTime: entry.modTime, Segment: segmentName, SegmentSize: segmentSize}
+	}
+
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+	for _, name := range outdated {
+		if _, replaced := indexed[name]; !replaced && manifest.Archives[name].Segment != "" {
+			_ = os.Remove(filepath.Join(dir, manifest.Archives[name].Segment))
+		}
+		delete(manifest.Archives, name)
+	}
+	for name, archive := range indexed {
+		manifest.Archives[name] = archive
+	}
+	index.enforceSizeBudget()
+	return len(added), index.saveManifest(manifest)
+}
+
+// enforceSizeBudget removes the segments of the oldest archives until the index fits in its size budget.
+// The archives stay in the manifests, so that they are not indexed again until they change. The mutex of the index must be held.
+func (index *archiveIndex) enforceSizeBudget() {
+	if index.maxSize <= 0 {
+		return
+	}
+	type segment struct {
+		manifest *indexManifest
+		name     string
+	}
+	var segments []segment
+	var totalSize int64
+	for _, manifest := range index.manifests {
+		for name, archive := range manifest.Archives {
+			if archive.Segment != "" {
+				segments = append(segments, segment{manifest, name})
+				totalSize += archive.SegmentSize
+			}
+		}
+	}
+	if totalSize <= index.maxSize {
+		return
+	}
+	sort.Slice(segments, func(i, j int) bool {
+		return segments[i].manifest.Archives[segments[i].name].ModTime.Before(segments[j].manifest.Archives[segments[j].name].ModTime)
+	})
+	evicted := make(map[*indexManifest]bool)
+	for _, segment := range segments {
+		if totalSize <= index.maxSize {
+			break
+		}
+		archive := segment.manifest.Archives[segment.name]
+		_ = os.Remove(filepath.Join(index.dirOf(segment.manifest.Dir), archive.Segment))
+		totalSize -= archive.SegmentSize
+		archive.Segment, archive.SegmentSize = "", 0
+		segment.manifest.Archives[segment.name] = archive
+		evicted[segment.manifest] = true
+	}
+	for manifest := range evicted {
+		if err := index.saveManifest(manifest); err != nil {
+			printError(fmt.Errorf("failed to save the index manifest of %s: %w", manifest.Dir, err))
+		}
+	}
+}
+
+// segmentOf returns the segment of the given archive of the directory, or false if it is not indexed
+// or has changed since it was indexed
+func (index *archiveIndex) segmentOf(archivesDir string, entry archiveEntry) (indexSegment, bool) {
+	if index == nil {
+		return nil, false
+	}
+	index.mutex.Lock()
+	archive, found := index.manifests[archivesDir].getArchive(entry.key())
+	index.mutex.Unlock()
+	if !found || archive.Segment == "" || entry.size != archive.Size || !entry.modTime.Equal(archive.ModTime) {
+		return nil, false
+	}
+	segment, err := readIndexSegment(filepath.Join(index.dirOf(archivesDir), archive.Segment))
+	if err != nil {
+		printError(fmt.Errorf("failed to read the index of archive %s: %w", entry.Name, err))
+		return nil, false
+	}
+	return segment, true
+}
+
+// getArchive returns the given archive of the manifest, which can be nil
+func (manifest *indexManifest) getArchive(name string) (indexedArchive, bool) {
+	if manifest == nil {
+		return indexedArchive{}, false
+	}
+	archive, found := manifest.Archives[name]
+	return archive, found
+}
+
+// mayContain returns whether the given archive can contain all the given texts, case-insensitively.
+// It is false only when the archive is indexed and one of the words of a text is not part of any of its tokens.
+func (index *archiveIndex) mayContain(archivesDir string, entry archiveEntry, texts []string) bool {
+	if len(texts) == 0 {
+		return true
+	}
+	segment, indexed := index.segmentOf(archivesDir, entry)
+	if !indexed {
+		return true
+	}
+	for _, text := range texts {
+		for _, token := range indexTokens(text) {
+			if !segment.hasTokenContaining(token) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// hasTokenContaining returns whether one of the tokens of the segment contains the given text,
+// which is assumed when some tokens of the archive were too long to be indexed
+func (segment indexSegment) hasTokenContaining(text string) bool {
+	if _, found := segment[text]; found {
+		return true
+	}
+	for token := range segment {
+		if strings.Contains(token, text) {
+			return true
+		}
+	}
+	_, dropped := segment[droppedTokensMarker]
+	return dropped
+}
+
+// lookup returns the lines of the given archive containing the words of the given phrase one after the other,
+// or false if the archive is not indexed
+func (index *archiveIndex) lookup(archivesDir string, entry archiveEntry, phrase string) ([]int, bool) {
+	segment, indexed := index.segmentOf(archivesDir, entry)
+	if !indexed {
+		return nil, false
+	}
+	return segment.lookup(indexTokens(phrase)), true
+}
+
+// lookup returns the lines containing the given tokens one after the other, in ascending order
+func (segment indexSegment) lookup(tokens []string) []int {
+	if len(tokens) == 0 {
+		return nil
+	}
+	// the positions of the first token from which the next ones can follow
+	candidates := make(map[indexPosting]struct{})
+	for _, posting := range segment[tokens[0]] {
+		candidates[posting] = struct{}{}
+	}
+	for offset, token := range tokens[1:] {
+		next := make(map[indexPosting]struct{})
+		for _, posting := range segment[token] {
+			if posting.position <= uint32(offset) {
+				continue
+			}
+			start := indexPosting{line: posting.line, position: posting.position - uint32(offset+1)}
+			if _, found := candidates[start]; found {
+				next[start] = struct{}{}
+			}
+		}
+		candidates = next
+	}
+
+	lines := make(map[int]struct{})
+	for posting := range candidates {
+		lines[int(posting.line)] = struct{}{}
+	}
+	sorted := make([]int, 0, len(lines))
+	for line := range lines {
+		sorted = append(sorted, line)
+	}
+	sort.Ints(sorted)
+	return sorted
+}
+
+// buildIndexSegment reads the tokens of the given archive
+func buildIndexSegment(location archiveLocation) (indexSegment, error) {
+	content, err := uncompress(location)
+	if err != nil {
+		return nil, err
+	}
+	segment := make(indexSegment)
+	addTokens := func(line uint32, text string, offset uint32) {
+		for position, token := range indexTokens(text) {
+			if len(token) > maxIndexedTokenLength || position >= strippedTokensOffset {
+				segment[droppedTokensMarker] = nil
+				continue
+			}
+			segment[token] = append(segment[token], indexPosting{line: line, position: offset + uint32(position)})
+		}
+	}
+	for i, line := range strings.Split(string(content), "\n") {
+		addTokens(uint32(i+1), line, 0)
+		if stripped := indexStripHighlighter.stripFormatting(line); stripped != line {
+			addTokens(uint32(i+1), stripped, strippedTokensOffset)
+		}
+	}
+	return segment, nil
+}
+
+// writeIndexSegment indexes the given archive into the given segment file, and returns its size
+func writeIndexSegment(location archiveLocation, segmentPath string) (int64, error) {
+	segment, err := buildIndexSegment(location)
+	if err != nil {
+		return 0, err
+	}
+	tokens := make([]string, 0, len(segment))
+	for token := range segment {
+		tokens = append(tokens, token)
+	}
+	sort.Strings(tokens)
+
+	tmpPath := segmentPath + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = file.Close()
+		_ = os.Remove(tmpPath)
+	}()
+	gzWriter := gzip.NewWriter(file)
+	writer := bufio.NewWriter(gzWriter)
+	buf := make([]byte, binary.MaxVarintLen64)
+	writeUvarint := func(n uint64) {
+		_, _ = writer.Write(buf[:binary.PutUvarint(buf, n)])
+	}
+
+	_, _ = writer.WriteString(indexSegmentMagic)
+	writeUvarint(uint64(len(tokens)))
+	for _, token := range tokens {
+		writeUvarint(uint64(len(token)))
+		_, _ = writer.WriteString(token)
+		postings := segment[token]
+		writeUvarint(uint64(len(postings)))
+		previousLine := uint32(0)
+		for _, posting := range postings {
+			// the lines are increasing, so their differences are smaller
+			writeUvarint(uint64(posting.line - previousLine))
+			writeUvarint(uint64(posting.position))
+			previousLine = posting.line
+		}
+	}
+	if err = writer.Flush(); err != nil {
+		return 0, err
+	}
+	if err = gzWriter.Close(); err != nil {
+		return 0, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if err = file.Close(); err != nil {
+		return 0, err
+	}
+	return info.Size(), os.Rename(tmpPath, segmentPath)
+}
+
+// readIndexSegment reads the given segment file
+func readIndexSegment(segmentPath string) (indexSegment, error) {
+	file, err := os.Open(segmentPath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	gzReader, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, err
+	}
+	reader := bufio.NewReader(gzReader)
+
+	magic := make([]byte, len(indexSegmentMagic))
+	if _, err = io.ReadFull(reader, magic); err != nil || string(magic) != indexSegmentMagic {
+		return nil, errors.New("not an index segment")
+	}
+	tokensCount, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return nil, err
+	}
+	segment := make(indexSegment, tokensCount)
+	for i := uint64(0); i < tokensCount; i++ {
+		length, err := binary.ReadUvarint(reader)
+		if err != nil || length > maxIndexedTokenLength {
+			return nil, fmt.Errorf("invalid token length: %v", err)
+		}
+		token := make([]byte, length)
+		if _, err = io.ReadFull(reader, token); err != nil {
+			return nil, err
+		}
+		postingsCount, err := binary.ReadUvarint(reader)
+		if err != nil {
+			return nil, err
+		}
+		var postings []indexPosting
+		if postingsCount > 0 {
+			postings = make([]indexPosting, 0, postingsCount)
+		}
+		line := uint32(0)
+		for j := uint64(0); j < postingsCount; j++ {
+			lineDelta, err := binary.ReadUvarint(reader)
+			if err != nil {
+				return nil, err
+			}
+			position, err := binary.ReadUvarint(reader)
+			if err != nil {
+				return nil, err
+			}
+			line += uint32(lineDelta)
+			postings = append(postings, indexPosting{line: line, position: uint32(position)})
+		}
+		segment[string(token)] = postings
+	}
+	return segment, nil
+}
+
+// getArchivesLocations returns the archives directories of all the servers, or of the given one if it is not empty
+func getArchivesLocations(config Config, server string) ([]archivesLocation, error) {
+	var locations []archivesLocation
+	for _, servCfg := range config.Servers.Classic {
+		if servCfg.archivesEnabled && (server == "" || servCfg.ServerTag == server) {
+			locations = append(locations, archivesLocation{dir: servCfg.getArchivedLogsDirPath(), pattern: servCfg.ArchivedLogFilenameFormat})
+		}
+	}
+	for _, servCfg := range config.Servers.Dynamic {
+		if servCfg.archivesEnabled && (server == "" || servCfg.ServerTag == server) {
+			dynamicLocations, err := getDynamicArchivesLocations(servCfg, "")
+			if err != nil {
+				return nil, fmt.Errorf("failed to list the instances of server %s: %w", servCfg.ServerTag, err)
+			}
+			locations = append(locations, dynamicLocations...)
+		}
+	}
+	return locations, nil
+}
+
+// runReindex executes the reindex subcommand with the given args, and returns the exit code.
+// It indexes again all the archives of the configured servers, or of one of them, from scratch.
+func runReindex(args []string) int {
+	flags := flag.NewFlagSet("reindex", flag.ContinueOnError)
+	configPath := flags.String("config", "./config.yml", "the path to the configuration file")
+	server := flags.String("server", "", "the tag of the only server whose archives to index")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	config, err := loadConfigFrom(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Reindex failed:", err)
+		return 1
+	}
+	if config.ArchivesIndex.Dir == "" {
+		fmt.Fprintln(os.Stderr, "Reindex failed: archives-index.dir is not set in the configuration")
+		return 1
+	}
+	index, err := newArchiveIndex(config.PathPrefix+config.ArchivesIndex.Dir, config.ArchivesIndex.maxSize)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Reindex failed:", err)
+		return 1
+	}
+	locations, err := getArchivesLocations(config, *server)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Reindex failed:", err)
+		return 1
+	}
+	if len(locations) == 0 && *server != "" {
+		fmt.Fprintf(os.Stderr, "Reindex failed: server %s is unknown or has no archives\n", *server)
+		return 1
+	}
+
+	exitCode := 0
+	for _, location := range locations {
+		entries, err := listArchivedLogFiles(location.dir, location.pattern)
+		if err == nil {
+			err = index.forget(location.dir)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to index the archives of %s: %v\n", location.dir, err)
+			exitCode = 1
+			continue
+		}
+		count, err := index.update(location.dir, entries)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to index the archives of %s: %v\n", location.dir, err)
+			exitCode = 1
+			continue
+		}
+		fmt.Printf("Indexed %d of %d archives of %s\n", count, len(entries), location.dir)
+	}
+	return exitCode
+}
+
+// indexHit holds the lines of an archive containing the phrase looked up in the index
+type indexHit struct {
+	Archive  string `json:"archive"`
+	Instance string `json:"instance,omitempty"`
+	Lines    []int  `json:"lines"`
+	// The url of the archive in the viewer, at the first line
+	Url string `json:"url"`
+}
+
+// indexLookupResult is the response of the index lookup endpoint
+type indexLookupResult struct {
+	Hits []indexHit `json:"hits"`
+	// The archives which are not indexed yet, or do not fit in the size budget of the index, and must be searched instead
+	Unindexed []string `json:"unindexed"`
+}
+
+// apiIndexHandler looks up the lines of the archives of a server containing a term or a phrase on /api/index/{server}?q=...,
+// using the index only. The instance query param restricts the lookup to an instance of a dynamic server.
+func apiIndexHandler(w http.ResponseWriter, r *http.Request, config Config) {
+	server := strings.TrimPrefix(r.URL.Path, "/api/index/")
+	if archivesIndex == nil {
+		prettier(w, "The archives index is not enabled", nil, http.StatusNotFound)
+		return
+	}
+	search, found, err := newArchiveSearch(config, server, r.URL.Query().Get("instance"))
+	if !found {
+		prettier(w, "Unknown server: "+server, nil, http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, errArchivesDisabled) {
+		prettier(w, "No archives for server "+server, nil, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		printError(err)
+		prettier(w, "Failed to list the archives of server "+server, nil, http.StatusInternalServerError)
+		return
+	}
+	phrase := r.URL.Query().Get("q")
+	if len(indexTokens(phrase)) == 0 {
+		prettier(w, "Invalid lookup: the q param must contain a word", nil, http.StatusBadRequest)
+		return
+	}
+
+	result := indexLookupResult{Hits: []indexHit{}, Unindexed: []string{}}
+	for _, archive := range search.archives {
+		name := archive.location.name()
+		lines, indexed := archivesIndex.lookup(archive.dir, archive.entry, phrase)
+		if !indexed {
+			result.Unindexed = append(result.Unindexed, name)
+			continue
+		}
+		if len(lines) > 0 {
+			result.Hits = append(result.Hits, indexHit{
+				Archive:  name,
+				Instance: archive.instance,
+				Lines:    lines,
+				Url:      search.archiveUrl(archive, lines[0]),
+			})
+		}
+	}
+	prettier(w, "Index lookup done", result, http.StatusOK)
+}
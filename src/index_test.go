@@ -0,0 +1,151 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestIndex returns an index and a directory of archives, and lists the archives like the viewer would
+func newTestIndex(t *testing.T, maxSize int64) (*archiveIndex, string, func() []archiveEntry) {
+	index, err := newArchiveIndex(t.TempDir(), maxSize)
+	if err != nil {
+		t.Fatalf("failed to create index: %v", err)
+	}
+	dir := t.TempDir()
+	return index, dir, func() []archiveEntry {
+		entries, err := listArchivedLogFiles(dir, "*.log.gz")
+		assert.NoError(t, err)
+		return entries
+	}
+}
+
+// entryNamed returns the archive with the given name among the given ones, or an archive which is not listed
+func entryNamed(entries []archiveEntry, name string) archiveEntry {
+	for _, entry := range entries {
+		if entry.Name == name {
+			return entry
+		}
+	}
+	return archiveEntry{Name: name, relativePath: name}
+}
+
+func TestIndexTokens(t *testing.T) {
+	assert.Equal(t, []string{"12", "30", "server", "thread", "warn", "can", "t", "keep", "up"},
+		indexTokens("[12:30] [Server thread/WARN]: Can't keep up!"))
+	assert.Empty(t, indexTokens(" - "))
+}
+
+func TestIndexSegment(t *testing.T) {
+	dir := t.TempDir()
+	writeTestArchive(t, dir, "2026-10-16-1.log.gz",
+		"INFO Server started",
+		"\u001b[31mERROR\u001b[0m Connection timed out",
+		"WARN the server is slow",
+		"INFO "+strings.Repeat("a", maxIndexedTokenLength+1),
+	)
+	segmentPath := filepath.Join(dir, "segment.idx")
+	size, err := writeIndexSegment(archiveLocation{path: filepath.Join(dir, "2026-10-16-1.log.gz")}, segmentPath)
+	assert.NoError(t, err)
+	assert.Greater(t, size, int64(0))
+	segment, err := readIndexSegment(segmentPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+	built, _ := buildIndexSegment(archiveLocation{path: filepath.Join(dir, "2026-10-16-1.log.gz")})
+	assert.Equal(t, built, segment, "The segment should be read as it was written")
+
+	assert.Equal(t, []int{1, 3}, segment.lookup(indexTokens("server")))
+	assert.Equal(t, []int{2}, segment.lookup(indexTokens("ERROR connection")), "The words should be indexed without their formatting codes")
+	assert.Equal(t, []int{2}, segment.lookup(indexTokens("timed out")))
+	assert.Empty(t, segment.lookup(indexTokens("out timed")), "The words of a phrase should follow each other")
+	assert.True(t, segment.hasTokenContaining("meout"))
+
+	_, err = readIndexSegment(filepath.Join(dir, "2026-10-16-1.log.gz"))
+	assert.Error(t, err)
+}
+
+func TestIndexUpdate(t *testing.T) {
+	index, dir, list := newTestIndex(t, 0)
+	writeTestArchive(t, dir, "2026-10-16-1.log.gz", "ERROR java.lang.OutOfMemoryError", "INFO done")
+	writeTestArchive(t, dir, "2026-10-17-1.log.gz", "INFO all good")
+	entries := list()
+	oldArchive := entryNamed(entries, "2026-10-16-1.log.gz")
+	newArchive := entryNamed(entries, "2026-10-17-1.log.gz")
+
+	count, err := index.update(dir, entries)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	lines, indexed := index.lookup(dir, oldArchive, "java.lang")
+	assert.True(t, indexed)
+	assert.Equal(t, []int{1}, lines)
+	assert.True(t, index.mayContain(dir, oldArchive, []string{"outofmemory", "done"}))
+	assert.False(t, index.mayContain(dir, newArchive, []string{"OutOfMemory"}))
+	assert.True(t, index.mayContain(dir, entryNamed(nil, "unknown.log.gz"), []string{"OutOfMemory"}), "An archive which is not indexed may contain anything")
+
+	count, err = index.update(dir, list())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count, "The archives which did not change should not be indexed again")
+
+	writeTestArchive(t, dir, "2026-10-17-1.log.gz", "INFO all good", "ERROR OutOfMemoryError")
+	_ = os.Chtimes(filepath.Join(dir, newArchive.relativePath), time.Now(), time.Now().Add(time.Minute))
+	newArchive = entryNamed(list(), "2026-10-17-1.log.gz")
+	_, indexed = index.lookup(dir, newArchive, "OutOfMemoryError")
+	assert.False(t, indexed, "A modified archive should not be looked up in its outdated segment")
+	assert.NoError(t, os.Remove(filepath.Join(dir, oldArchive.relativePath)))
+	count, err = index.update(dir, list())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+	lines, _ = index.lookup(dir, newArchive, "OutOfMemoryError")
+	assert.Equal(t, []int{2}, lines)
+
+	reloaded, err := newArchiveIndex(index.root, 0)
+	assert.NoError(t, err)
+	assert.Len(t, reloaded.manifests[dir].Archives, 1, "The removed archive should be forgotten")
+	lines, indexed = reloaded.lookup(dir, newArchive, "OutOfMemoryError")
+	assert.True(t, indexed, "The index should be persisted")
+	assert.Equal(t, []int{2}, lines)
+}
+
+func TestIndexSizeBudget(t *testing.T) {
+	index, dir, list := newTestIndex(t, 1)
+	writeTestArchive(t, dir, "2026-10-16-1.log.gz", "INFO old")
+	writeTestArchive(t, dir, "2026-10-17-1.log.gz", "INFO new")
+	_ = os.Chtimes(filepath.Join(dir, "2026-10-16-1.log.gz"), time.Now(), time.Now().Add(-time.Hour))
+	entries := list()
+	_, err := index.update(dir, entries)
+	assert.NoError(t, err)
+	_, indexed := index.lookup(dir, entryNamed(entries, "2026-10-17-1.log.gz"), "new")
+	assert.False(t, indexed, "No segment fits in the budget")
+
+	index.maxSize = 1 << 20
+	assert.NoError(t, index.forget(dir))
+	_, err = index.update(dir, list())
+	assert.NoError(t, err)
+	newSize := index.manifests[dir].Archives["2026-10-17-1.log.gz"].SegmentSize
+	index.maxSize = newSize
+	index.enforceSizeBudget()
+	_, indexed = index.lookup(dir, entryNamed(entries, "2026-10-16-1.log.gz"), "old")
+	assert.False(t, indexed, "The oldest archive should be evicted first")
+	lines, indexed := index.lookup(dir, entryNamed(entries, "2026-10-17-1.log.gz"), "new")
+	assert.True(t, indexed)
+	assert.Equal(t, []int{1}, lines)
+}
+
+func TestQueryRequiredTexts(t *testing.T) {
+	for query, expected := range map[string][]string{
+		`error "timed out"`:                  {"error", "timed out"},
+		`level>=warn AND (error OR timeout)`: nil,
+		`error NOT timeout`:                  {"error"},
+	} {
+		parsed, err := parseQuery(query)
+		if assert.NoError(t, err, query) {
+			assert.Equal(t, expected, parsed.requiredTexts(), query)
+		}
+	}
+	assert.Nil(t, (*logQuery)(nil).requiredTexts())
+}
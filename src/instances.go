@@ -0,0 +1,111 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// archivedInstance is an instance of a dynamic server having archives, which may have no log file anymore
+type archivedInstance struct {
+	Id          string
+	DisplayName string
+	// Whether the instance still has a log file
+	Live bool
+	// The url of the list of its archives
+	Url string
+	archiveSummary
+}
+
+// getArchivedInstances returns the instances of the given dynamic server having archives, the most recently active first
+func getArchivedInstances(urlPrefix string, servCfg DynamicServerConfig) ([]archivedInstance, error) {
+	locations, err := getDynamicArchivesLocations(servCfg, "")
+	if err != nil {
+		return nil, err
+	}
+	liveInstances, err := getDynamicServerInstances(servCfg)
+	if err != nil {
+		return nil, err
+	}
+	live := make(map[string]bool, len(liveInstances))
+	for _, instance := range liveInstances {
+		live[instance.id] = true
+	}
+
+	var instances []archivedInstance
+	for _, location := range locations {
+		// the instances are summed up without their catalogs, which would watch the directories of all of them
+		summary, err := summaries.get(location.dir, location.pattern, servCfg.parser)
+		if err != nil {
+			// the other instances are listed anyway
+			printError(err)
+			continue
+		}
+		if summary.Archives == 0 {
+			continue
+		}
+		instances = append(instances, archivedInstance{
+			Id:             location.instance,
+			DisplayName:    strings.ReplaceAll(servCfg.DisplayName, "%id%", location.instance),
+			Live:           live[location.instance],
+			Url:            urlPrefix + "/dyn-archive/" + servCfg.ServerTag + "/" + url.PathEscape(location.instance) + "/",
+			archiveSummary: summary,
+		})
+	}
+	sort.SliceStable(instances, func(i, j int) bool {
+		if !instances[i].end.Equal(instances[j].end) {
+			return instances[i].end.After(instances[j].end)
+		}
+		return instances[i].Id < instances[j].Id
+	})
+	return instances, nil
+}
+
+// InstancesWebData contains the data of the index of the archives of a dynamic server
+type InstancesWebData struct {
+	Server            string
+	ServerDisplayName string
+	Instances         []archivedInstance
+}
+
+// dynamicArchivesIndexHandler serves the index of the archives of a dynamic server on /dyn-archive/{server}/, which lists
+// the instances having archives, including the ones which do not exist anymore, with the period of their archives
+func dynamicArchivesIndexHandler(w http.ResponseWriter, r *http.Request, urlPrefix string, templateCommonData CommonWebData, servCfg DynamicServerConfig) {
+	if !servCfg.archivesEnabled {
+		http.Redirect(w, r, urlPrefix+"/", http.StatusSeeOther)
+		return
+	}
+	instances, err := getArchivedInstances(urlPrefix, servCfg)
+	if err != nil {
+		handleTemplateError(w, http.StatusInternalServerError, err)
+		return
+	}
+	// the page has the navbar of the index, as it is not about the logs of a single instance
+	funcMap := getFuncMapFor("", true, false, false)
+	funcMap["formatSize"] = formatByteSize
+	tmpl, err := parseTemplates(funcMap, "instances", "navbar")
+	if err != nil {
+		handleTemplateError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	templateCommonData.ExecDate = time.Now().Format("15:04:05")
+	err = tmpl.Execute(w, struct {
+		CommonWebData
+		InstancesWebData
+	}{
+		CommonWebData: templateCommonData,
+		InstancesWebData: InstancesWebData{
+			Server:            servCfg.ServerTag,
+			ServerDisplayName: strings.ReplaceAll(servCfg.DisplayName, "%id%", "<D>"),
+			Instances:         instances,
+		},
+	})
+	if doDebug {
+		if err != nil {
+			printError(err)
+		}
+	}
+}
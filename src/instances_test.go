@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArchivedInstanceIds(t *testing.T) {
+	config := newTestArchivesConfig(t)
+	servCfg := config.Servers.Dynamic[0]
+	root := filepath.Dir(filepath.Dir(filepath.Dir(servCfg.ArchivedLogsRootDir)))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "servers/file"), nil, 0644))
+	ids, err := getArchivedInstanceIds(servCfg)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, ids, "The instances should be found by their archives directories")
+
+	servCfg.ArchivedLogsRootDir = filepath.Join(root, "servers/Paper-%id%-old/logs")
+	for _, dir := range []string{"servers/Paper-17-old/logs", "servers/Paper-18-new/logs"} {
+		assert.NoError(t, os.MkdirAll(filepath.Join(root, dir), 0755))
+	}
+	ids, err = getArchivedInstanceIds(servCfg)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"17"}, ids, "The identifier should be found between the texts surrounding %id%")
+}
+
+func TestArchivedInstances(t *testing.T) {
+	config := newTestArchivesConfig(t)
+	servCfg := config.Servers.Dynamic[0]
+	root := filepath.Dir(filepath.Dir(filepath.Dir(servCfg.ArchivedLogsRootDir)))
+	writeTestArchive(t, filepath.Join(root, "servers/b/logs"), "2026-10-18-1.log.gz", "[10:00:00] instance b again")
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "servers/empty/logs"), 0755))
+
+	instances, err := getArchivedInstances("/prefix", servCfg)
+	if !assert.NoError(t, err) || !assert.Len(t, instances, 2, "The instances without archives should not be listed") {
+		return
+	}
+	ended, live := instances[0], instances[1]
+	assert.Equal(t, "b", ended.Id, "The most recently active instances should come first")
+	assert.False(t, ended.Live)
+	assert.Equal(t, 2, ended.Archives)
+	assert.Equal(t, "2026-10-16", ended.From)
+	assert.Equal(t, "2026-10-18", ended.To)
+	assert.Equal(t, "Instance b", ended.DisplayName)
+	assert.Equal(t, "/prefix/dyn-archive/dyn/b/", ended.Url)
+	assert.Equal(t, "a", live.Id)
+	assert.True(t, live.Live)
+
+	catalogs.mutex.Lock()
+	_, watched := catalogs.catalogs[filepath.Join(root, "servers/b/logs")+"\x00"+servCfg.ArchivedLogsFilePattern]
+	catalogs.mutex.Unlock()
+	assert.False(t, watched, "The instances should be summed up without watching their archives")
+
+	w := httptest.NewRecorder()
+	createDynamicArchiveHandlerFor("", servCfg, CommonWebData{})(w, httptest.NewRequest(http.MethodGet, "/dyn-archive/dyn/", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `href="/dyn-archive/dyn/b/"`)
+	assert.Contains(t, w.Body.String(), `href="/dynamic/dyn/a"`)
+
+	// an instance which has ended is browsed like the others
+	catalog, found, err := getDynamicArchivesCatalog(servCfg, "b")
+	if assert.NoError(t, err) && assert.True(t, found) {
+		entries, _ := catalog.list()
+		assert.Len(t, entries, 2)
+	}
+
+	writeTestArchive(t, filepath.Join(root, "servers/b/logs"), "2026-10-19-1.log.gz", "[10:00:00] instance b once more")
+	instances, err = getArchivedInstances("/prefix", servCfg)
+	if assert.NoError(t, err) && assert.Len(t, instances, 2) {
+		assert.Equal(t, 2, instances[0].Archives, "The summaries should be reused until they are outdated")
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"html/template"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The formats of the log lines of a server
+const (
+	formatText = "text"
+	formatJSON = "json"
+)
+
+// defaultJsonLevelMapping normalizes the levels of the most common JSON loggers (zap, logrus and pino),
+// the last one using numbers
+var defaultJsonLevelMapping = map[string]string{
+	"trace":   levelDebug,
+	"warning": levelWarn,
+	"err":     levelError,
+	"fatal":   levelCritical,
+	"panic":   levelCritical,
+	"dpanic":  levelCritical,
+	"10":      levelDebug,
+	"20":      levelDebug,
+	"30":      levelInfo,
+	"40":      levelWarn,
+	"50":      levelError,
+	"60":      levelCritical,
+}
+
+// JsonFormatConfig represents the keys of the JSON log lines of a server, which are the ones of zap, logrus and pino by default
+type JsonFormatConfig struct {
+	// The key of the message, msg by default
+	MessageKey string `yaml:"message-key"`
+	// The key of the level, level by default
+	LevelKey string `yaml:"level-key"`
+	// The key of the time, time by default
+	TimeKey string `yaml:"time-key"`
+	// The Go layout of the time when it is a string, RFC 3339 by default. Numbers are read as epoch seconds or milliseconds
+	TimeLayout string `yaml:"time-layout"`
+}
+
+// jsonFormat is a compiled JsonFormatConfig
+type jsonFormat struct {
+	messageKey, levelKey, timeKey, timeLayout string
+	// The normalized levels, by lowercase level value
+	levelMapping map[string]string
+}
+
+// jsonEntry is a parsed JSON log line
+type jsonEntry struct {
+	message, level, time string
+	// The other fields, in the order of the line
+	fields []logField
+}
+
+// compile returns the format of the config, with the given level mapping of the server
+func (jsonCfg JsonFormatConfig) compile(levelMapping map[string]string) (*jsonFormat, error) {
+	format := &jsonFormat{
+		messageKey:   jsonCfg.MessageKey,
+		levelKey:     jsonCfg.LevelKey,
+		timeKey:      jsonCfg.TimeKey,
+		timeLayout:   jsonCfg.TimeLayout,
+		levelMapping: make(map[string]string, len(defaultJsonLevelMapping)+len(levelMapping)),
+	}
+	if format.messageKey == "" {
+		format.messageKey = "msg"
+	}
+	if format.levelKey == "" {
+		format.levelKey = "level"
+	}
+	if format.timeKey == "" {
+		format.timeKey = "time"
+	}
+	if format.timeLayout == "" {
+		format.timeLayout = time.RFC3339
+	}
+	for value, level := range defaultJsonLevelMapping {
+		format.levelMapping[value] = level
+	}
+	for value, level := range levelMapping {
+		if !isKnownLevel(level) {
+			return nil, fmt.Errorf("invalid level %q for %q: must be one of %s", level, value, strings.Join(knownLevels, ", "))
+		}
+		format.levelMapping[strings.ToLower(value)] = level
+	}
+	return format, nil
+}
+
+// parse returns the entry of the given line, or nil if it is not a JSON object
+func (format *jsonFormat) parse(line string) *jsonEntry {
+	if format == nil {
+		return nil
+	}
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "{") {
+		return nil
+	}
+	var fields []logField
+	if err := flattenJsonObject([]byte(line), "", &fields); err != nil {
+		return nil
+	}
+	entry := &jsonEntry{fields: fields[:0]}
+	for _, field := range fields {
+		switch field.key {
+		case format.messageKey:
+			entry.message = field.value
+		case format.levelKey:
+			entry.level = field.value
+		case format.timeKey:
+			entry.time = field.value
+		default:
+			entry.fields = append(entry.fields, field)
+		}
+	}
+	return entry
+}
+
+// flattenJsonObject appends the fields of the given JSON object to fields, in their order.
+// The keys of the nested objects are prefixed by the ones of their parents.
+func flattenJsonObject(data []byte, prefix string, fields *[]logField) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return errors.New("not a JSON object")
+	}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		key := prefix + token.(string)
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return err
+		}
+		switch value[0] {
+		case '{':
+			if err := flattenJsonObject(value, key+".", fields); err != nil {
+				return err
+			}
+		case '"':
+			var str string
+			if err := json.Unmarshal(value, &str); err != nil {
+				return err
+			}
+			*fields = append(*fields, logField{key: key, value: str})
+		default:
+			var compacted bytes.Buffer
+			if err := json.Compact(&compacted, value); err != nil {
+				return err
+			}
+			*fields = append(*fields, logField{key: key, value: compacted.String()})
+		}
+	}
+	if _, err := decoder.Token(); err != nil {
+		return err
+	}
+	if decoder.More() {
+		return errors.New("unexpected data after the JSON object")
+	}
+	return nil
+}
+
+// normalizeLevel returns the normalized level of the entry, or an empty string if it has none
+func (format *jsonFormat) normalizeLevel(entry *jsonEntry) string {
+	value := strings.ToLower(entry.level)
+	if level, found := format.levelMapping[value]; found {
+		return level
+	}
+	if isKnownLevel(value) {
+		return value
+	}
+	return ""
+}
+
+// parseTime returns the time of the entry, or a zero time if it has none
+func (format *jsonFormat) parseTime(entry *jsonEntry) time.Time {
+	if entry.time == "" {
+		return time.Time{}
+	}
+	if epoch, err := strconv.ParseFloat(entry.time, 64); err == nil {
+		// epoch seconds are far below 1e11, which is in 1973 in milliseconds
+		if math.Abs(epoch) >= 1e11 {
+			return time.UnixMilli(int64(epoch))
+		}
+		// the float precision is around the microsecond for the current epoch seconds
+		seconds, fraction := math.Modf(epoch)
+		return time.Unix(int64(seconds), int64(math.Round(fraction*1e6))*1e3)
+	}
+	parsed, err := time.ParseInLocation(format.timeLayout, entry.time, time.Local)
+	if err != nil {
+		return time.Time{}
+	}
+	return parsed
+}
+
+// render returns the HTML-escaped entry: its time, level and highlighted message,
+// followed by the other fields as collapsible chips.
+// The new lines of the values are escaped too, as they separate the lines of an event.
+func (format *jsonFormat) render(entry *jsonEntry, highlighter *lineHighlighter) template.HTML {
+	var builder strings.Builder
+	if entry.time != "" {
+		builder.WriteString(`<span class="json-time">` + escapeJsonText(entry.time) + "</span> ")
+	}
+	if entry.level != "" {
+		builder.WriteString(`<span class="json-level">` + escapeJsonText(entry.level) + "</span> ")
+	}
+	message := strings.ReplaceAll(string(highlighter.highlight(entry.message)), "\n", "<br>")
+	builder.WriteString(`<span class="json-message">` + message + "</span>")
+	if len(entry.fields) > 0 {
+		builder.WriteString(` <span class="json-fields collapsed"><span class="json-fields-toggle" data-fields="` + strconv.Itoa(len(entry.fields)) + `" title="Show or hide the fields"></span>`)
+		for _, field := range entry.fields {
+			builder.WriteString(`<span class="json-field" data-key="` + escapeJsonAttribute(field.key) + `" data-value="` + escapeJsonAttribute(field.value) + `" title="Filter the events with this field">`)
+			builder.WriteString(`<span class="json-key">` + escapeJsonText(field.key) + `</span>=<span class="json-value">` + escapeJsonText(field.value) + "</span></span>")
+		}
+		builder.WriteString("</span>")
+	}
+	return template.HTML(builder.String())
+}
+
+func escapeJsonText(value string) string {
+	return strings.ReplaceAll(html.EscapeString(value), "\n", "<br>")
+}
+
+func escapeJsonAttribute(value string) string {
+	return strings.ReplaceAll(html.EscapeString(value), "\n", "&#10;")
+}
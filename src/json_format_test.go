@@ -0,0 +1,72 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJsonParse(t *testing.T) {
+	format, err := JsonFormatConfig{}.compile(nil)
+	assert.NoError(t, err)
+
+	entry := format.parse(`{"level":"info","time":"2026-10-17T12:00:00Z","msg":"request done","user_id":42,"http":{"status":200,"path":"/"},"tags":["a", "b"],"ok":true}`)
+	assert.NotNil(t, entry)
+	assert.Equal(t, "request done", entry.message)
+	assert.Equal(t, "info", entry.level)
+	assert.Equal(t, []logField{
+		{key: "user_id", value: "42"},
+		{key: "http.status", value: "200"},
+		{key: "http.path", value: "/"},
+		{key: "tags", value: `["a","b"]`},
+		{key: "ok", value: "true"},
+	}, entry.fields, "The fields should be flattened and kept in order")
+
+	assert.Nil(t, format.parse("panic: something went wrong"))
+	assert.Nil(t, format.parse(`{"msg": "truncated`))
+	assert.Nil(t, format.parse(`{"msg": "a"} {"msg": "b"}`))
+
+	var noFormat *jsonFormat
+	assert.Nil(t, noFormat.parse(`{"msg": "a"}`))
+}
+
+func TestJsonLevelsAndTimes(t *testing.T) {
+	format, err := JsonFormatConfig{TimeKey: "ts"}.compile(map[string]string{"notice": levelInfo})
+	assert.NoError(t, err)
+
+	assert.Equal(t, levelWarn, format.normalizeLevel(format.parse(`{"level":"warning"}`)), "logrus levels should be normalized")
+	assert.Equal(t, levelError, format.normalizeLevel(format.parse(`{"level":50}`)), "pino levels should be normalized")
+	assert.Equal(t, levelInfo, format.normalizeLevel(format.parse(`{"level":"NOTICE"}`)), "The mapping of the server should be used")
+	assert.Equal(t, "", format.normalizeLevel(format.parse(`{"msg":"no level"}`)))
+
+	expected := time.Date(2026, time.October, 17, 12, 0, 0, 0, time.UTC)
+	assert.True(t, expected.Equal(format.parseTime(format.parse(`{"ts":1792238400}`))), "zap times are epoch seconds")
+	assert.True(t, expected.Add(123*time.Millisecond).Equal(format.parseTime(format.parse(`{"ts":1792238400.123}`))))
+	assert.True(t, expected.Add(500*time.Millisecond).Equal(format.parseTime(format.parse(`{"ts":1792238400500}`))), "pino times are epoch milliseconds")
+	assert.True(t, expected.Equal(format.parseTime(format.parse(`{"ts":"2026-10-17T14:00:00+02:00"}`))))
+	assert.True(t, format.parseTime(format.parse(`{"ts":"yesterday"}`)).IsZero())
+
+	_, err = JsonFormatConfig{}.compile(map[string]string{"notice": "information"})
+	assert.Error(t, err)
+}
+
+func TestJsonRender(t *testing.T) {
+	format, _ := JsonFormatConfig{}.compile(nil)
+	parser := &logParser{json: format}
+
+	html := string(parser.highlight(`{"level":"error","msg":"<b>failed</b>","stack":"line 1\nline 2","user":"o\"neil"}`))
+	assert.Contains(t, html, `<span class="json-level">error</span>`)
+	assert.Contains(t, html, `<span class="json-message">&lt;b&gt;failed&lt;/b&gt;</span>`)
+	assert.Contains(t, html, `data-key="stack" data-value="line 1&#10;line 2"`)
+	assert.Contains(t, html, `data-key="user" data-value="o&#34;neil"`)
+	assert.Contains(t, html, `data-fields="2"`)
+	assert.False(t, strings.Contains(html, "\n"), "The new lines separate the lines of an event")
+
+	assert.Equal(t, "plain &lt;line&gt;", string(parser.highlight("plain <line>")), "Other lines should be highlighted as text")
+
+	rows := parser.renderLogs([]string{`{"level":"warn","msg":"slow"}`, `{"level":"info","msg":"ok"}`, ""}, time.Now(), nil)
+	assert.Equal(t, levelWarn, rows[0].Level)
+	assert.Equal(t, 1, parser.countLevels(rows)[1].Count)
+}
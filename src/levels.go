@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// levelGroupName is the name of the regexp group extracting the level of a log line
+const levelGroupName = "level"
+
+// The normalized severity levels, from the least to the most severe
+const (
+	levelDebug    = "debug"
+	levelInfo     = "info"
+	levelWarn     = "warn"
+	levelError    = "error"
+	levelCritical = "critical"
+)
+
+var knownLevels = []string{levelDebug, levelInfo, levelWarn, levelError, levelCritical}
+
+// LevelConfig represents the rule extracting the severity level of the log lines of a server
+type LevelConfig struct {
+	// The regexp extracting the level, in a group named 'level' or in the whole match
+	Regex string `yaml:"regex"`
+	// The normalized level of each extracted value, e.g. WARNING: warn. The values that are already normalized can be omitted
+	Mapping map[string]string `yaml:"mapping"`
+}
+
+// levelRule is a compiled LevelConfig
+type levelRule struct {
+	regexp *regexp.Regexp
+	// The index of the group of the level, 0 for the whole match
+	group int
+	// The normalized levels, by lowercase extracted value
+	mapping map[string]string
+	// The highlighter of the server, whose formatting codes are removed before matching the lines
+	highlighter *lineHighlighter
+}
+
+// compile returns the rule of the config, or nil if levels must not be extracted
+func (levelCfg LevelConfig) compile(highlighter *lineHighlighter) (*levelRule, error) {
+	if levelCfg.Regex == "" {
+		if len(levelCfg.Mapping) > 0 {
+			return nil, errors.New("no regex provided for the mapping")
+		}
+		return nil, nil
+	}
+	re, err := regexp.Compile(levelCfg.Regex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex: %w", err)
+	}
+	rule := &levelRule{
+		regexp:      re,
+		group:       re.SubexpIndex(levelGroupName),
+		mapping:     make(map[string]string, len(levelCfg.Mapping)),
+		highlighter: highlighter,
+	}
+	if rule.group < 0 {
+		rule.group = 0
+	}
+	for value, level := range levelCfg.Mapping {
+		if !isKnownLevel(level) {
+			return nil, fmt.Errorf("invalid level %q for %q: must be one of %s", level, value, strings.Join(knownLevels, ", "))
+		}
+		rule.mapping[strings.ToLower(value)] = level
+	}
+	return rule, nil
+}
+
+// extract returns the normalized level of the given line, or an empty string if it has none
+func (rule *levelRule) extract(line string) string {
+	if rule == nil {
+		return ""
+	}
+	matches := rule.regexp.FindStringSubmatch(rule.highlighter.stripFormatting(line))
+	if matches == nil {
+		return ""
+	}
+	value := strings.ToLower(matches[rule.group])
+	if level, found := rule.mapping[value]; found {
+		return level
+	}
+	if isKnownLevel(value) {
+		return value
+	}
+	return ""
+}
+
+func isKnownLevel(level string) bool {
+	return levelSeverity(level) >= 0
+}
+
+// levelSeverity returns the index of the given level from the least to the most severe one, or -1 if it is unknown
+func levelSeverity(level string) int {
+	for i, knownLevel := range knownLevels {
+		if level == knownLevel {
+			return i
+		}
+	}
+	return -1
+}
+
+// levelCount is the number of log events of a level
+type levelCount struct {
+	Level string `json:"level"`
+	Count int    `json:"count"`
+}
+
+// countLevels returns the number of events of every known level, with the given levels of the events
+func countLevels(eventLevels []string) []levelCount {
+	counts := make([]levelCount, len(knownLevels))
+	for i, level := range knownLevels {
+		counts[i].Level = level
+	}
+	for _, eventLevel := range eventLevels {
+		for i := range counts {
+			if counts[i].Level == eventLevel {
+				counts[i].Count++
+			}
+		}
+	}
+	return counts
+}
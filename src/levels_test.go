@@ -0,0 +1,57 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLevelExtraction(t *testing.T) {
+	rule, err := LevelConfig{
+		Regex:   `^\[\d{2}:\d{2}:\d{2}] \[[^\]]*/(?P<level>\w+)]`,
+		Mapping: map[string]string{"WARNING": levelWarn, "severe": levelCritical},
+	}.compile(&lineHighlighter{ansi: ansiRender})
+	assert.NoError(t, err)
+
+	assert.Equal(t, levelInfo, rule.extract("[12:00:00] [Server thread/INFO]: Done"), "Known levels need no mapping")
+	assert.Equal(t, levelWarn, rule.extract("[12:00:00] [Server thread/WARNING]: Can't keep up!"))
+	assert.Equal(t, levelCritical, rule.extract("[12:00:00] [Server thread/SEVERE]: Crash"), "The mapping should be case insensitive")
+	assert.Equal(t, levelError, rule.extract("\x1b[31m[12:00:00] [Server thread/ERROR]: Oops"), "Formatting codes should be ignored")
+	assert.Equal(t, "", rule.extract("[12:00:00] [Server thread/TRACE]: Unknown level"))
+	assert.Equal(t, "", rule.extract("no level"))
+
+	var noRule *levelRule
+	assert.Equal(t, "", noRule.extract("[12:00:00] [Server thread/INFO]: Done"))
+}
+
+func TestLevelConfig(t *testing.T) {
+	rule, err := LevelConfig{}.compile(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, rule)
+
+	_, err = LevelConfig{Regex: `\w+`, Mapping: map[string]string{"WARNING": "warning"}}.compile(nil)
+	assert.Error(t, err, "Mapped levels must be normalized ones")
+	_, err = LevelConfig{Mapping: map[string]string{"WARNING": levelWarn}}.compile(nil)
+	assert.Error(t, err, "A mapping without regex is useless")
+}
+
+func TestRenderLogsLevels(t *testing.T) {
+	level, _ := LevelConfig{Regex: `^(?P<level>[A-Z]+)`}.compile(nil)
+	multiline, _ := MultilineConfig{ContinuationPattern: `^\s`}.compile(nil)
+	parser := &logParser{level: level, multiline: multiline}
+
+	rows := parser.renderLogs([]string{"INFO start", "ERROR failure", "  at somewhere", "WARN slow", "ERROR again", ""}, time.Now(), nil)
+	assert.Len(t, rows, 4)
+	assert.Equal(t, levelError, rows[1].Level)
+	assert.Len(t, rows[1].Lines, 2)
+	assert.Equal(t, []levelCount{
+		{Level: levelDebug, Count: 0},
+		{Level: levelInfo, Count: 1},
+		{Level: levelWarn, Count: 1},
+		{Level: levelError, Count: 2},
+		{Level: levelCritical, Count: 0},
+	}, parser.countLevels(rows))
+
+	assert.Nil(t, (&logParser{}).countLevels(rows), "No counts without level rule")
+}
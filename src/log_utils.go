@@ -1,16 +1,25 @@
 package main
 
 import (
-	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
 	"errors"
 	"io"
-	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 )
 
+// splitLogLines returns the lines of the given log content, without the empty line following the last new line
+func splitLogLines(content []byte) []string {
+	if len(content) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+}
+
 func getServerLogs(filePath string, limit int) []string {
 	fileContent, err := os.ReadFile(filePath)
 	if err != nil {
@@ -25,11 +34,50 @@ func getServerLogs(filePath string, limit int) []string {
 	return lines
 }
 
+// getLogsBefore returns the lines of the given log file preceding the given offset
+func getLogsBefore(filePath string, offset int64) ([]string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	content, err := io.ReadAll(io.NewSectionReader(file, 0, offset))
+	if err != nil {
+		return nil, err
+	}
+	return splitLogLines(content), nil
+}
+
 type archiveEntry struct {
-	Name     string
-	FullName string
-	modTime  time.Time
-	Date     string
+	Name string
+	// The opaque identifier of the archive in the urls of the viewer, see archiveId
+	Id string
+	// The path of the archive in its directory, or of its bundle if it is a member of one, which is never sent to the clients
+	relativePath string
+	// The name of the archive in its bundle, empty if it is not a member of a bundle
+	member  string
+	modTime time.Time
+	size    int64
+	// The identifier of the zip or tar file holding the archive, empty if it is not a member of a bundle
+	bundle string
+}
+
+// archiveId returns the identifier of the archive at the given path in its directory. The paths are hashed so that the
+// clients can only name the archives listed to them, which are then found in the catalog rather than on the disk.
+func archiveId(relativePath string) string {
+	hash := sha256.Sum256([]byte(relativePath))
+	return base64.RawURLEncoding.EncodeToString(hash[:12])
+}
+
+// key returns the key of the archive in its directory, which is its path, followed by its name in its bundle
+// if it is a member of one. It identifies the archive but is never used as a path.
+func (entry archiveEntry) key() string {
+	if entry.member != "" {
+		return entry.relativePath + bundleMemberSeparator + entry.member
+	}
+	return entry.relativePath
 }
 
 func listArchivedLogFiles(logsDirRootPath, logsFilePattern string) ([]archiveEntry, error) {
@@ -47,31 +95,71 @@ func listArchivedLogFiles(logsDirRootPath, logsFilePattern string) ([]archiveEnt
 		if info.IsDir() {
 			continue
 		}
-		modTime := info.ModTime()
-		newEntry := archiveEntry{
-			Name:     filepath.Base(entry),
-			FullName: filePathEscape(strings.TrimPrefix(entry, logsDirRootPath)),
-			modTime:  modTime,
-			Date:     modTime.Format("02-01 15:04:05"),
+		kind, err := bundleKind(entry)
+		if err != nil {
+			return []archiveEntry{}, err
 		}
-		inserted := false
-		for i, e := range entries {
-			if e.modTime.Before(modTime) {
-				entries = append(entries[:i], append([]archiveEntry{newEntry}, entries[i:]...)...)
-				inserted = true
-				break
+		if kind != "" {
+			bundleEntries, err := listBundle(logsDirRootPath, entry, kind, info)
+			if err == nil {
+				entries = append(entries, bundleEntries...)
+				continue
 			}
+			debugPrint("Failed to list the members of " + entry + ", it is listed as a single archive: " + err.Error())
 		}
-		if !inserted {
-			entries = append(entries, newEntry)
+		relativePath, err := filepath.Rel(logsDirRootPath, entry)
+		if err != nil {
+			return []archiveEntry{}, err
 		}
+		entries = append(entries, archiveEntry{
+			Name:         filepath.Base(entry),
+			Id:           archiveId(relativePath),
+			relativePath: relativePath,
+			modTime:      info.ModTime(),
+			size:         info.Size(),
+		})
 	}
+	// the newest archives come first
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].modTime.After(entries[j].modTime)
+	})
 
+	archivesIndex.updateInBackground(logsDirRootPath, entries)
 	return entries, nil
 }
 
-func getArchiveLogs(logsFilePath string, limit int) []string {
-	uncompressed, err := uncompress(logsFilePath)
+// listBundle returns the members of the given zip or tar file as archives, which are named after the bundle like 2026-10.zip/2026-10-17.log
+func listBundle(logsDirRootPath, bundlePath, kind string, info os.FileInfo) ([]archiveEntry, error) {
+	members, err := listBundleMembers(bundlePath, kind)
+	if err != nil {
+		return nil, err
+	}
+	relativePath, err := filepath.Rel(logsDirRootPath, bundlePath)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]archiveEntry, 0, len(members))
+	for _, member := range members {
+		modTime := member.modTime
+		if modTime.IsZero() {
+			modTime = info.ModTime()
+		}
+		entry := archiveEntry{
+			Name:         filepath.Base(bundlePath) + "/" + member.name,
+			relativePath: relativePath,
+			member:       member.name,
+			modTime:      modTime,
+			size:         member.size,
+			bundle:       archiveId(relativePath),
+		}
+		entry.Id = archiveId(entry.key())
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func getArchiveLogs(location archiveLocation, limit int) []string {
+	uncompressed, err := uncompress(location)
 	if err != nil {
 		err = errors.New("Error while uncompressing archived log file: " + err.Error())
 		printError(err)
@@ -85,40 +173,17 @@ func getArchiveLogs(logsFilePath string, limit int) []string {
 	return lines
 }
 
-func uncompress(filePath string) ([]byte, error) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return nil, err
-	}
-	defer func(file *os.File) {
-		_ = file.Close()
-	}(file)
+// uncompress returns the content of the given archive, which may be compressed with gzip, bzip2, zstd, xz or zlib,
+// and may be a member of a zip or tar file
+func uncompress(location archiveLocation) ([]byte, error) {
+	defer metrics.timeArchiveDecompression(time.Now())
 
-	buf := make([]byte, 512)
-	n, err := file.Read(buf)
+	reader, err := openArchive(location)
 	if err != nil {
 		return nil, err
 	}
-	_, err = file.Seek(0, 0)
-	if err != nil {
-		return nil, errors.New("failed to seek archived log file: " + err.Error())
-	}
-
-	contentType := http.DetectContentType(buf[:n])
-	if i := strings.Index(contentType, ";"); i >= 0 {
-		contentType = contentType[:i]
-	}
-
-	switch contentType {
-	case "text/plain":
-		return io.ReadAll(file)
-	case "application/x-gzip":
-		gzReader, err := gzip.NewReader(file)
-		if err != nil {
-			return nil, err
-		}
-		return io.ReadAll(gzReader)
-	default:
-		return io.ReadAll(file)
-	}
+	defer func(reader io.Closer) {
+		_ = reader.Close()
+	}(reader)
+	return io.ReadAll(reader)
 }
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"os"
 	"time"
 
 	fifo "github.com/foize/go.fifo"
@@ -17,6 +18,13 @@ var doDebug bool
 
 func main() {
 
+	if len(os.Args) > 1 && os.Args[1] == "healthcheck" {
+		os.Exit(runHealthcheck(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "reindex" {
+		os.Exit(runReindex(os.Args[2:]))
+	}
+
 	fmt.Print("\nStarting LogRenderer V"+version, " ...\n")
 
 	configPath := flag.String("config", "./config.yml", "the path to the configuration file")
@@ -35,14 +43,23 @@ func main() {
 
 	fmt.Print("Config:\n", config)
 
+	if err = startArchivesIndex(config); err != nil {
+		exitWithError(err)
+	}
+
 	outputChannel := make(chan Event, 16)
-	hub := newHub()
+	hub := newHub(config.slowClientPolicy)
+	hub.authUserHeader = config.AuthUserHeader
+	go hub.run(outputChannel)
 
 	// classic servers startup
 	for _, servCfg := range config.Servers.Classic {
 		fmt.Println("Starting to watch for logs of classic server", servCfg.ServerTag, "...")
 
-		hub.clientsByServer[servCfg.ServerTag] = []*Client{}
+		hub.addServer(servCfg.ServerTag)
+		if config.Metrics.CountSyntaxHighlightingFields {
+			metrics.setFieldMatchers(servCfg.ServerTag, servCfg.parser.highlighter)
+		}
 
 		logQueue := fifo.NewQueue()
 		go watchServ(logQueue, watchProperties{
@@ -51,7 +68,7 @@ func main() {
 			shouldRewatchOnFileRemove: true,
 			delayBeforeRewatch:        config.delayBeforeRewatch,
 		})
-		go unstack(servCfg.ServerTag, logQueue, outputChannel)
+		go unstack(servCfg.ServerTag, servCfg.parser, logQueue, outputChannel)
 	}
 	// dynamic servers startup
 	dynamicServers := make(DynamicServers)
@@ -59,14 +76,17 @@ func main() {
 	for _, servCfg := range config.Servers.Dynamic {
 		fmt.Println("Starting to watch for instances logs of dynamic server", servCfg.ServerTag, "...")
 
+		if config.Metrics.CountSyntaxHighlightingFields {
+			metrics.setFieldMatchers(servCfg.ServerTag, servCfg.parser.highlighter)
+		}
+
 		server := newDynamicServer(servCfg)
 		dynamicServers[servCfg.ServerTag] = server
-		hub.clientsByDynamicServer[servCfg.ServerTag] = make(map[string][]*Client)
 
 		go server.watchForInstances(hub, outputChannel, instancesRefreshInterval)
 	}
 
-	err = startServer(config, hub, outputChannel)
+	err = startServer(config, hub)
 	if err != nil {
 		exitWithError(err)
 	}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	fifo "github.com/foize/go.fifo"
+)
+
+const metricsPrefix = "logrenderer_"
+
+// The names of the metrics, without the prefix
+const (
+	metricLinesIngested        = "lines_ingested_total"
+	metricBytesRead            = "bytes_read_total"
+	metricQueueDepth           = "queue_depth"
+	metricClients              = "clients"
+	metricDroppedLines         = "dropped_lines_total"
+	metricSlowDisconnections   = "slow_client_disconnections_total"
+	metricRewatches            = "rewatches_total"
+	metricRotations            = "rotations_total"
+	metricArchiveDecompression = "archive_decompression_seconds"
+	metricInstancesDiscovered  = "dynamic_instances_discovered_total"
+	metricInstancesEnded       = "dynamic_instances_ended_total"
+	metricFieldLines           = "syntax_highlighting_lines_total"
+)
+
+const (
+	metricTypeCounter = "counter"
+	metricTypeGauge   = "gauge"
+	metricTypeSummary = "summary"
+)
+
+// metricsDescriptions contains the type and the help text of every metric
+var metricsDescriptions = map[string][2]string{
+	metricLinesIngested:        {metricTypeCounter, "Number of log lines read, per server and instance"},
+	metricBytesRead:            {metricTypeCounter, "Number of bytes read from the log files, per server and instance"},
+	metricQueueDepth:           {metricTypeGauge, "Number of read chunks waiting to be split into lines, per server and instance"},
+	metricClients:              {metricTypeGauge, "Number of connected clients, per type"},
+	metricDroppedLines:         {metricTypeCounter, "Number of lines dropped because a client was too slow, per server"},
+	metricSlowDisconnections:   {metricTypeCounter, "Number of clients disconnected because they were too slow, per server"},
+	metricRewatches:            {metricTypeCounter, "Number of times a log file has been watched again after being renamed or removed, per server and instance"},
+	metricRotations:            {metricTypeCounter, "Number of times a log file has been truncated, per server and instance"},
+	metricArchiveDecompression: {metricTypeSummary, "Time spent reading and decompressing archived log files"},
+	metricInstancesDiscovered:  {metricTypeCounter, "Number of dynamic server instances found, per server"},
+	metricInstancesEnded:       {metricTypeCounter, "Number of dynamic server instances whose log file is not watched anymore, per server"},
+	metricFieldLines:           {metricTypeCounter, "Number of log lines matching each syntax highlighting field, per server and instance"},
+}
+
+// metricsRegistry holds the values of all the metrics, and can be safely used by several goroutines
+type metricsRegistry struct {
+	mutex sync.Mutex
+	// The values of every metric, by formatted labels
+	values map[string]map[string]float64
+	// The number of observations of the summaries, by formatted labels
+	counts map[string]map[string]uint64
+	// The watched log queues, by formatted labels
+	queues map[string]*fifo.Queue
+	// The syntax highlighting rules of every server, if the lines matching them must be counted
+	fieldMatchers map[string]*lineHighlighter
+}
+
+var metrics = newMetricsRegistry()
+
+func newMetricsRegistry() *metricsRegistry {
+	return &metricsRegistry{
+		values:        make(map[string]map[string]float64),
+		counts:        make(map[string]map[string]uint64),
+		queues:        make(map[string]*fifo.Queue),
+		fieldMatchers: make(map[string]*lineHighlighter),
+	}
+}
+
+// add adds the given value to the metric with the given labels
+func (registry *metricsRegistry) add(name, labels string, value float64) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	if _, found := registry.values[name]; !found {
+		registry.values[name] = make(map[string]float64)
+	}
+	registry.values[name][labels] += value
+}
+
+// observe records an observation of the summary with the given labels
+func (registry *metricsRegistry) observe(name, labels string, value float64) {
+	registry.add(name, labels, value)
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	if _, found := registry.counts[name]; !found {
+		registry.counts[name] = make(map[string]uint64)
+	}
+	registry.counts[name][labels]++
+}
+
+// trackQueue makes the depth of the given queue exported until untrackQueue is called
+func (registry *metricsRegistry) trackQueue(servName string, queue *fifo.Queue) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	registry.queues[serverLabels(servName)] = queue
+}
+
+func (registry *metricsRegistry) untrackQueue(servName string) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	delete(registry.queues, serverLabels(servName))
+}
+
+// setFieldMatchers makes the lines of the server matching its syntax highlighting rules counted
+func (registry *metricsRegistry) setFieldMatchers(serverTag string, highlighter *lineHighlighter) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	registry.fieldMatchers[serverTag] = highlighter
+}
+
+// countLines records the new lines of a log event of the given server, and the syntax highlighting fields they match
+func (registry *metricsRegistry) countLines(server, instance string, lines []string) {
+	servName := server
+	if instance != "" {
+		servName = joinWSServer(server, instance)
+	}
+	labels := serverLabels(servName)
+	registry.add(metricLinesIngested, labels, float64(len(lines)))
+
+	registry.mutex.Lock()
+	highlighter := registry.fieldMatchers[server]
+	registry.mutex.Unlock()
+	for _, line := range lines {
+		for _, field := range highlighter.matchingFields(line) {
+			registry.add(metricFieldLines, labels+`,field="`+escapeLabelValue(field)+`"`, 1)
+		}
+	}
+}
+
+// timeArchiveDecompression records the time elapsed since the given start time as an archive decompression
+func (registry *metricsRegistry) timeArchiveDecompression(start time.Time) {
+	registry.observe(metricArchiveDecompression, "", time.Since(start).Seconds())
+}
+
+// writeTo writes all the metrics with the Prometheus text format, along with the given client counts
+func (registry *metricsRegistry) writeTo(w *strings.Builder, clientsByType map[string]int) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	// gauges computed at scrape time
+	queueDepths := make(map[string]float64)
+	for labels, queue := range registry.queues {
+		queueDepths[labels] = float64(queue.Len())
+	}
+	clients := make(map[string]float64)
+	for clientType, count := range clientsByType {
+		clients[`type="`+escapeLabelValue(clientType)+`"`] = float64(count)
+	}
+
+	names := make([]string, 0, len(metricsDescriptions))
+	for name := range metricsDescriptions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		values := registry.values[name]
+		switch name {
+		case metricQueueDepth:
+			values = queueDepths
+		case metricClients:
+			values = clients
+		}
+		description := metricsDescriptions[name]
+		fullName := metricsPrefix + name
+		w.WriteString("# HELP " + fullName + " " + description[1] + "\n")
+		w.WriteString("# TYPE " + fullName + " " + description[0] + "\n")
+		for _, labels := range sortedKeys(values) {
+			if description[0] == metricTypeSummary {
+				writeSample(w, fullName+"_sum", labels, values[labels])
+				writeSample(w, fullName+"_count", labels, float64(registry.counts[name][labels]))
+			} else {
+				writeSample(w, fullName, labels, values[labels])
+			}
+		}
+	}
+}
+
+func writeSample(w *strings.Builder, name, labels string, value float64) {
+	w.WriteString(name)
+	if labels != "" {
+		w.WriteString("{" + labels + "}")
+	}
+	w.WriteString(" " + strconv.FormatFloat(value, 'g', -1, 64) + "\n")
+}
+
+func sortedKeys(values map[string]float64) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// serverLabels returns the formatted labels of the given server, which has the format used by the websocket (server or server=>instance)
+func serverLabels(servName string) string {
+	if serverTag, serverId, isDynamic := parseWSServer(servName); isDynamic {
+		return `server="` + escapeLabelValue(serverTag) + `",instance="` + escapeLabelValue(serverId) + `"`
+	}
+	return `server="` + escapeLabelValue(servName) + `"`
+}
+
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// metricsHandler exposes the metrics with the Prometheus text format
+func metricsHandler(w http.ResponseWriter, hub *Hub) {
+	clientsByType := map[string]int{"websocket": 0, "sse": 0, "tail": 0}
+	hub.inspect(func() {
+		for client := range hub.clients {
+			switch client.format {
+			case formatSSE:
+				clientsByType["sse"]++
+			case formatPlain:
+				clientsByType["tail"]++
+			default:
+				clientsByType["websocket"]++
+			}
+		}
+	})
+
+	var builder strings.Builder
+	metrics.writeTo(&builder, clientsByType)
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, err := w.Write([]byte(builder.String()))
+	if err != nil {
+		printError(fmt.Errorf("failed to write metrics: %v", err))
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsExposition(t *testing.T) {
+	registry := newMetricsRegistry()
+	highlighter, errs := compileSyntaxHighlighting("serv", SyntaxHighlightingConfig{
+		{Field: "error", Regex: `ERROR`},
+		{Field: "invalid", Regex: `(?!x)`},
+	}, nil)
+	assert.Len(t, errs, 1, "The invalid regexp should be reported")
+	registry.setFieldMatchers("serv", highlighter)
+
+	registry.countLines("serv", "", []string{"[ERROR] first", "[INFO] second"})
+	registry.countLines("dyn", "1", []string{"[ERROR] not counted, no matchers for this server"})
+	registry.add(metricBytesRead, serverLabels("serv"), 42)
+	registry.observe(metricArchiveDecompression, "", 0.5)
+	registry.observe(metricArchiveDecompression, "", 1)
+
+	var builder strings.Builder
+	registry.writeTo(&builder, map[string]int{"websocket": 3})
+	output := builder.String()
+
+	for _, expected := range []string{
+		"# TYPE logrenderer_lines_ingested_total counter\n",
+		`logrenderer_lines_ingested_total{server="serv"} 2` + "\n",
+		`logrenderer_lines_ingested_total{server="dyn",instance="1"} 1` + "\n",
+		`logrenderer_syntax_highlighting_lines_total{server="serv",field="error"} 1` + "\n",
+		`logrenderer_bytes_read_total{server="serv"} 42` + "\n",
+		"# TYPE logrenderer_archive_decompression_seconds summary\n",
+		"logrenderer_archive_decompression_seconds_sum 1.5\n",
+		"logrenderer_archive_decompression_seconds_count 2\n",
+		`logrenderer_clients{type="websocket"} 3` + "\n",
+	} {
+		assert.Contains(t, output, expected)
+	}
+	assert.NotContains(t, output, `instance="1",field=`, "Lines of servers without matchers should not be matched")
+}
+
+func TestServerLabels(t *testing.T) {
+	assert.Equal(t, `server="serv"`, serverLabels("serv"))
+	assert.Equal(t, `server="paper",instance="3"`, serverLabels(joinWSServer("paper", "3")))
+	assert.Equal(t, `server="a\"b\\c"`, serverLabels(`a"b\c`))
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// minecraftMode tells what to do with the Minecraft formatting codes, like §a or &c, of the log lines
+type minecraftMode string
+
+const (
+	// minecraftNone leaves the codes as they are
+	minecraftNone minecraftMode = ""
+	// minecraftRender turns the codes into styles
+	minecraftRender minecraftMode = "render"
+	// minecraftStrip removes the codes
+	minecraftStrip minecraftMode = "strip"
+)
+
+// minecraftStylePrefix is the prefix of the names of the styles overriding the Minecraft colors, e.g. minecraft-a
+const minecraftStylePrefix = "minecraft-"
+
+// minecraftColorCodes are the Minecraft color codes, in the order of the palette
+const minecraftColorCodes = "0123456789abcdef"
+
+// defaultMinecraftPalette are the CSS declarations of the Minecraft colors, by code
+var defaultMinecraftPalette = [16]string{
+	"color: #000000;", "color: #0000AA;", "color: #00AA00;", "color: #00AAAA;",
+	"color: #AA0000;", "color: #AA00AA;", "color: #FFAA00;", "color: #AAAAAA;",
+	"color: #555555;", "color: #5555FF;", "color: #55FF55;", "color: #55FFFF;",
+	"color: #FF5555;", "color: #FF55FF;", "color: #FFFF55;", "color: #FFFFFF;",
+}
+
+// getMinecraftPalette returns the default Minecraft palette, with the colors overridden by the given styles
+func getMinecraftPalette(styles map[string]string) [16]string {
+	palette := defaultMinecraftPalette
+	for i, code := range minecraftColorCodes {
+		if style, found := styles[minecraftStylePrefix+string(code)]; found {
+			palette[i] = style
+		}
+	}
+	return palette
+}
+
+// applyMinecraftCode updates the style with the given formatting code, like §a, &l or §x§r§r§g§g§b§b.
+// Like in the game, a color resets the formatting.
+func (state *textStyle) applyMinecraftCode(code string, palette *[16]string) {
+	_, prefixSize := utf8.DecodeRuneInString(code)
+	code = strings.ToLower(code[prefixSize:])
+	switch {
+	case code[0] == 'x':
+		// hex color, each digit being prefixed
+		var hex strings.Builder
+		for _, r := range code[1:] {
+			if strings.ContainsRune(minecraftColorCodes, r) {
+				hex.WriteRune(r)
+			}
+		}
+		*state = textStyle{}
+		state.setForeground("#" + hex.String())
+	case strings.Contains(minecraftColorCodes, code):
+		*state = textStyle{colorStyle: palette[strings.Index(minecraftColorCodes, code)]}
+	case code == "l":
+		state.bold = true
+	case code == "m":
+		state.strikethrough = true
+	case code == "n":
+		state.underline = true
+	case code == "o":
+		state.italic = true
+	case code == "r":
+		*state = textStyle{}
+	}
+	// §k (obfuscated) is not rendered
+}
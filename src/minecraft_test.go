@@ -0,0 +1,54 @@
+package main
+
+import (
+	"html/template"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMinecraftCodes(t *testing.T) {
+	highlighter := &lineHighlighter{ansi: ansiRaw, minecraft: minecraftRender, minecraftAmpersand: true, minecraftPalette: getMinecraftPalette(map[string]string{
+		"minecraft-c": "color: red; text-shadow: 1px 1px #3f1515;",
+	})}
+
+	text, runs := highlighter.parseFormatting("§aGreen §lbold&r &cred §x§F§F§8§8§0§0hex §nunderlined", true)
+	assert.Equal(t, "Green bold red hex underlined", text)
+	assert.Equal(t, []styledRun{
+		{start: 0, style: "color: #55FF55;"},
+		{start: 6, style: "color: #55FF55; font-weight: bold;"},
+		{start: 10, style: ""},
+		{start: 11, style: "color: red; text-shadow: 1px 1px #3f1515;"},
+		{start: 15, style: "color: #ff8800;"},
+		{start: 19, style: "color: #ff8800; text-decoration: underline;"},
+	}, runs)
+
+	highlighter.minecraft = minecraftStrip
+	text, runs = highlighter.parseFormatting("§6[Shop] &eWelcome", true)
+	assert.Equal(t, "[Shop] Welcome", text)
+	assert.Empty(t, runs)
+}
+
+func TestParseMinecraftAmpersand(t *testing.T) {
+	highlighter := &lineHighlighter{ansi: ansiRaw, minecraft: minecraftStrip}
+	line := "§aR&D report at https://example.com/?a=1&b=2&cat=3"
+	text, _ := highlighter.parseFormatting(line, false)
+	assert.Equal(t, "R&D report at https://example.com/?a=1&b=2&cat=3", text, "The plain & should be left as they are by default")
+
+	highlighter.minecraftAmpersand = true
+	text, _ = highlighter.parseFormatting("&eWelcome §ato R&D", false)
+	assert.Equal(t, "Welcome to R", text, "The codes starting with & should be removed once enabled")
+}
+
+func TestHighlightMinecraftCodes(t *testing.T) {
+	highlighter, _ := compileSyntaxHighlighting("serv", SyntaxHighlightingConfig{
+		{Field: "prefix", Regex: `^\[\w+]`},
+	}, nil)
+	highlighter.minecraft = minecraftRender
+	highlighter.ansi = ansiRender
+
+	assert.Equal(t, template.HTML(`<span data-field="prefix"><span style="color: #FFAA00;">[Shop]</span></span>`+
+		`<span style="color: #FFAA00;"> </span><span style="color: #0dbc79;">&lt;ok&gt;</span>`),
+		highlighter.highlight("§6[Shop] \x1b[32m<ok>"))
+	assert.Equal(t, "[Shop] \x1b[32m<ok>", highlighter.text("§6[Shop] \x1b[32m<ok>"), "Plain text should keep the ANSI codes only")
+}
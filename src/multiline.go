@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+const defaultMultilineFlushTimeout = 200 * time.Millisecond
+
+// MultilineConfig represents the rule grouping the lines of a same event, like the lines of a stack trace
+type MultilineConfig struct {
+	// The regexp matching the first line of an event, the lines not matching it belong to the previous event
+	StartPattern string `yaml:"start-pattern"`
+	// The regexp matching the lines that belong to the previous event, e.g. `^\s+at `
+	ContinuationPattern string `yaml:"continuation-pattern"`
+	// How long to wait for the next line before sending an event, 200ms by default
+	FlushTimeout string `yaml:"flush-timeout"`
+}
+
+// multilineRule is a compiled MultilineConfig
+type multilineRule struct {
+	start, continuation *regexp.Regexp
+	flushTimeout        time.Duration
+	// The highlighter of the server, whose formatting codes are removed before matching the lines
+	highlighter *lineHighlighter
+}
+
+// compile returns the rule of the config, or nil if lines must not be grouped
+func (multilineCfg MultilineConfig) compile(highlighter *lineHighlighter) (*multilineRule, error) {
+	if multilineCfg.StartPattern == "" && multilineCfg.ContinuationPattern == "" {
+		return nil, nil
+	}
+	if multilineCfg.StartPattern != "" && multilineCfg.ContinuationPattern != "" {
+		return nil, errors.New("only one of start-pattern and continuation-pattern can be set")
+	}
+
+	rule := &multilineRule{flushTimeout: defaultMultilineFlushTimeout, highlighter: highlighter}
+	var err error
+	if multilineCfg.StartPattern != "" {
+		rule.start, err = regexp.Compile(multilineCfg.StartPattern)
+	} else {
+		rule.continuation, err = regexp.Compile(multilineCfg.ContinuationPattern)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+	if multilineCfg.FlushTimeout != "" {
+		rule.flushTimeout, err = time.ParseDuration(multilineCfg.FlushTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid flush-timeout: %w", err)
+		}
+		if rule.flushTimeout <= 0 {
+			return nil, errors.New("the flush-timeout must be positive")
+		}
+	}
+	return rule, nil
+}
+
+// isContinuation returns whether the given line belongs to the event of the previous line
+func (rule *multilineRule) isContinuation(line string) bool {
+	text := rule.highlighter.stripFormatting(line)
+	if rule.start != nil {
+		return !rule.start.MatchString(text)
+	}
+	return rule.continuation.MatchString(text)
+}
+
+// group splits the given lines into events
+func (rule *multilineRule) group(lines []string) [][]string {
+	var events [][]string
+	for _, line := range lines {
+		if rule != nil && len(events) > 0 && rule.isContinuation(line) {
+			events[len(events)-1] = append(events[len(events)-1], line)
+		} else {
+			events = append(events, []string{line})
+		}
+	}
+	return events
+}
+
+// lineGrouper groups the lines of a log file as they are read, with a rule that can be nil if they must not be grouped
+type lineGrouper struct {
+	rule    *multilineRule
+	pending []string
+	// The time the last line was added to the pending event
+	lastLineTime time.Time
+}
+
+// newLineGrouper returns a grouper with the multiline rule of the given parser, which can be nil
+func newLineGrouper(parser *logParser) *lineGrouper {
+	if parser == nil {
+		return new(lineGrouper)
+	}
+	return &lineGrouper{rule: parser.multiline}
+}
+
+// add adds the given line, and returns the previous event if the line does not belong to it
+func (grouper *lineGrouper) add(line string) (event []string) {
+	if grouper.rule == nil {
+		return []string{line}
+	}
+	if len(grouper.pending) == 0 || !grouper.rule.isContinuation(line) {
+		event = grouper.pending
+		grouper.pending = nil
+	}
+	grouper.pending = append(grouper.pending, line)
+	grouper.lastLineTime = time.Now()
+	return event
+}
+
+// flushIfExpired returns the pending event if no line has been added to it during the flush timeout of the rule
+func (grouper *lineGrouper) flushIfExpired() []string {
+	if len(grouper.pending) == 0 || time.Since(grouper.lastLineTime) < grouper.rule.flushTimeout {
+		return nil
+	}
+	return grouper.flush()
+}
+
+// flush returns the pending event, if any
+func (grouper *lineGrouper) flush() []string {
+	event := grouper.pending
+	grouper.pending = nil
+	return event
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	fifo "github.com/foize/go.fifo"
+	"github.com/stretchr/testify/assert"
+)
+
+var stackTraceLines = []string{
+	"[12:00:00] [Server thread/ERROR]: Could not pass event",
+	"java.lang.NullPointerException: oops",
+	"\tat com.example.Plugin.onEvent(Plugin.java:42)",
+	"\tat org.bukkit.Event.call(Event.java:7)",
+	"[12:00:01] [Server thread/INFO]: Done",
+}
+
+func TestMultilineGroup(t *testing.T) {
+	startRule, err := MultilineConfig{StartPattern: `^\[\d{2}:\d{2}:\d{2}]`}.compile(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{stackTraceLines[:4], stackTraceLines[4:]}, startRule.group(stackTraceLines))
+
+	continuationRule, err := MultilineConfig{ContinuationPattern: `^\s+at `}.compile(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{stackTraceLines[:1], stackTraceLines[1:4], stackTraceLines[4:]}, continuationRule.group(stackTraceLines))
+
+	var noRule *multilineRule
+	assert.Len(t, noRule.group(stackTraceLines), len(stackTraceLines), "Lines should not be grouped without rule")
+
+	// the formatting codes are ignored
+	highlighter := &lineHighlighter{ansi: ansiRender}
+	startRule, _ = MultilineConfig{StartPattern: `^\[`}.compile(highlighter)
+	assert.Len(t, startRule.group([]string{"\x1b[31m[12:00:00] error", "\x1b[31m[12:00:01] error"}), 2)
+}
+
+func TestMultilineConfig(t *testing.T) {
+	rule, err := MultilineConfig{}.compile(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, rule, "No rule should be compiled without pattern")
+
+	_, err = MultilineConfig{StartPattern: `^\[`, ContinuationPattern: `^\s`}.compile(nil)
+	assert.Error(t, err, "Only one pattern can be set")
+	_, err = MultilineConfig{StartPattern: `(?<=x)`}.compile(nil)
+	assert.Error(t, err)
+	_, err = MultilineConfig{StartPattern: `^\[`, FlushTimeout: "-1s"}.compile(nil)
+	assert.Error(t, err)
+
+	rule, err = MultilineConfig{ContinuationPattern: `^\s`, FlushTimeout: "1s"}.compile(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Second, rule.flushTimeout)
+}
+
+func TestUnstackMultiline(t *testing.T) {
+	rule, _ := MultilineConfig{StartPattern: `^\[`, FlushTimeout: "50ms"}.compile(nil)
+	logQueue := fifo.NewQueue()
+	outputChannel := make(chan Event, 16)
+	go unstack("test", &logParser{multiline: rule}, logQueue, outputChannel)
+
+	logQueue.Add(fileEvent{eventType: eventAdd, content: stackTraceLines[0] + "\n" + stackTraceLines[1] + "\n"})
+	logQueue.Add(fileEvent{eventType: eventAdd, content: stackTraceLines[2] + "\n" + stackTraceLines[3] + "\n" + stackTraceLines[4] + "\n"})
+
+	receive := func() Event {
+		select {
+		case event := <-outputChannel:
+			return event
+		case <-time.After(time.Second):
+			t.Fatal("No event received")
+			return Event{}
+		}
+	}
+
+	event := receive()
+	assert.Equal(t, eventAdd, event.Type)
+	assert.Equal(t, "[12:00:00] [Server thread/ERROR]: Could not pass event\njava.lang.NullPointerException: oops\n"+
+		"\tat com.example.Plugin.onEvent(Plugin.java:42)\n\tat org.bukkit.Event.call(Event.java:7)", event.Content)
+	assert.Equal(t, "[12:00:00] [Server thread/ERROR]: Could not pass event\njava.lang.NullPointerException: oops\n"+
+		"\tat com.example.Plugin.onEvent(Plugin.java:42)\n\tat org.bukkit.Event.call(Event.java:7)", string(event.Html))
+
+	start := time.Now()
+	event = receive()
+	assert.Equal(t, stackTraceLines[4], event.Content, "The last event should be sent after the flush timeout")
+	assert.GreaterOrEqual(t, time.Since(start), 30*time.Millisecond)
+}
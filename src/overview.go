@@ -0,0 +1,194 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// archiveSummary sums up the archives of one or several catalogs
+type archiveSummary struct {
+	Archives int
+	// The total size of the archives as they are stored, in bytes
+	CompressedSize int64
+	// The days of the oldest and newest events of the archives, like 2026-10-17, empty if there are no archives
+	From, To string
+
+	// The period of the events of the archives, see catalogEntry
+	start, end time.Time
+}
+
+// summaryMaxAge is the duration during which the summary of the archives of a directory is reused
+const summaryMaxAge = time.Minute
+
+// cachedSummary is the summary of the archives of a directory, and when it was made
+type cachedSummary struct {
+	summary archiveSummary
+	madeAt  time.Time
+}
+
+// archiveSummaries caches the summaries of the archives directories, by directory and pattern. Unlike the catalogs,
+// the directories are neither watched nor kept once their summaries are outdated, so that the archives of every
+// instance of the dynamic servers, including the ended ones, can be summed up. It can be safely used by several goroutines.
+type archiveSummaries struct {
+	mutex     sync.Mutex
+	summaries map[string]cachedSummary
+}
+
+// summaries is the cache of the summaries of the archives directories of the servers and instances
+var summaries = &archiveSummaries{summaries: make(map[string]cachedSummary)}
+
+// get returns the summary of the archives of the given directory, which are listed again once it is outdated
+func (summaries *archiveSummaries) get(dir, pattern string, parser *logParser) (archiveSummary, error) {
+	key := dir + "\x00" + pattern
+	summaries.mutex.Lock()
+	cached, found := summaries.summaries[key]
+	summaries.mutex.Unlock()
+	if found && time.Since(cached.madeAt) <= summaryMaxAge {
+		return cached.summary, nil
+	}
+
+	summary, err := summarizeArchives(dir, pattern, parser)
+	if err != nil {
+		return archiveSummary{}, err
+	}
+	summaries.mutex.Lock()
+	defer summaries.mutex.Unlock()
+	for other, cached := range summaries.summaries {
+		if time.Since(cached.madeAt) > summaryMaxAge {
+			delete(summaries.summaries, other)
+		}
+	}
+	summaries.summaries[key] = cachedSummary{summary: summary, madeAt: time.Now()}
+	return summary, nil
+}
+
+// summarizeArchives returns the summary of the archives of the given directory, whose periods are the ones known
+// without reading them, see archivePeriod
+func summarizeArchives(dir, pattern string, parser *logParser) (archiveSummary, error) {
+	entries, err := listArchivedLogFiles(dir, pattern)
+	if err != nil {
+		return archiveSummary{}, err
+	}
+	var summary archiveSummary
+	for _, entry := range entries {
+		start, end := archivePeriod(parser, entry)
+		summary.add(archiveSummary{Archives: 1, CompressedSize: entry.size, start: start, end: end})
+	}
+	return summary, nil
+}
+
+// add counts the archives of the other summary in this one
+func (summary *archiveSummary) add(other archiveSummary) {
+	if other.Archives == 0 {
+		return
+	}
+	if summary.Archives == 0 || other.start.Before(summary.start) {
+		summary.start = other.start
+	}
+	if summary.Archives == 0 || other.end.After(summary.end) {
+		summary.end = other.end
+	}
+	summary.Archives += other.Archives
+	summary.CompressedSize += other.CompressedSize
+	summary.From = summary.start.In(time.Local).Format(searchDateLayout)
+	summary.To = summary.end.In(time.Local).Format(searchDateLayout)
+}
+
+// archivedServer is a server whose archives are enabled, as listed by the index of the archives
+type archivedServer struct {
+	Tag, DisplayName string
+	IsDynamic        bool
+	// The number of instances having archives, for a dynamic server
+	Instances int
+	// The url of the archive browser of the server, which lists the instances of a dynamic server
+	Url string
+	archiveSummary
+}
+
+// getArchivedServers returns the servers whose archives are enabled, in the order of the config, with the summary of their archives.
+// The archives are summed up from the cached summaries of their directories, no catalog is made for them.
+func getArchivedServers(config Config) []archivedServer {
+	var servers []archivedServer
+	for _, servCfg := range config.Servers.Classic {
+		if !servCfg.archivesEnabled {
+			continue
+		}
+		server := archivedServer{
+			Tag:         servCfg.ServerTag,
+			DisplayName: servCfg.DisplayName,
+			Url:         config.UrlPrefix + "/archive/" + servCfg.ServerTag + "/",
+		}
+		summary, err := summaries.get(servCfg.getArchivedLogsDirPath(), servCfg.ArchivedLogFilenameFormat, servCfg.parser)
+		if err != nil {
+			// the server is listed anyway, its archive browser shows the error
+			printError(err)
+		}
+		server.archiveSummary = summary
+		servers = append(servers, server)
+	}
+	for _, servCfg := range config.Servers.Dynamic {
+		if !servCfg.archivesEnabled {
+			continue
+		}
+		server := archivedServer{
+			Tag:         servCfg.ServerTag,
+			DisplayName: strings.ReplaceAll(servCfg.DisplayName, "%id%", "<D>"),
+			IsDynamic:   true,
+			Url:         config.UrlPrefix + "/dyn-archive/" + servCfg.ServerTag + "/",
+		}
+		// the instances include the ended ones, which only have an archives directory
+		locations, err := getDynamicArchivesLocations(servCfg, "")
+		if err != nil {
+			printError(err)
+		}
+		for _, location := range locations {
+			summary, err := summaries.get(location.dir, location.pattern, servCfg.parser)
+			if err != nil {
+				// the other instances are summed up anyway
+				printError(err)
+				continue
+			}
+			if summary.Archives > 0 {
+				server.Instances++
+				server.add(summary)
+			}
+		}
+		servers = append(servers, server)
+	}
+	return servers
+}
+
+// ArchivesWebData contains the data of the index of the archives
+type ArchivesWebData struct {
+	// The display name of the current server, read by the navbar, which is empty as the page is about all the servers
+	ServerDisplayName string
+	ArchivedServers   []archivedServer
+}
+
+// archivesIndexHandler serves the index of the archives on /archive, which lists the servers whose archives are enabled
+// with the number, size and period of their archives, and links to their archive browsers
+func archivesIndexHandler(w http.ResponseWriter, templateCommonData CommonWebData, config Config) {
+	funcMap := getFuncMapFor("", true, false, false)
+	funcMap["formatSize"] = formatByteSize
+	tmpl, err := parseTemplates(funcMap, "archives", "navbar")
+	if err != nil {
+		handleTemplateError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	templateCommonData.ExecDate = time.Now().Format("15:04:05")
+	err = tmpl.Execute(w, struct {
+		CommonWebData
+		ArchivesWebData
+	}{
+		CommonWebData:   templateCommonData,
+		ArchivesWebData: ArchivesWebData{ArchivedServers: getArchivedServers(config)},
+	})
+	if doDebug {
+		if err != nil {
+			printError(err)
+		}
+	}
+}
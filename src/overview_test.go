@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArchivedServers(t *testing.T) {
+	config := newTestArchivesConfig(t)
+	config.UrlPrefix = "/logs"
+	root := filepath.Dir(config.Servers.Classic[0].ArchivedLogsDirPath)
+	writeTestArchive(t, filepath.Join(root, "servers/b/logs"), "2026-10-18-1.log.gz", "[10:00:00] instance b again")
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "servers/empty/logs"), 0755))
+	config.Servers.Classic = append(config.Servers.Classic, ClassicServerConfig{ServerConfig: ServerConfig{ServerTag: "private"}})
+
+	catalogs.mutex.Lock()
+	catalogsCount := len(catalogs.catalogs)
+	catalogs.mutex.Unlock()
+	servers := getArchivedServers(config)
+	if !assert.Len(t, servers, 2, "The servers whose archives are disabled should not be listed") {
+		return
+	}
+	catalogs.mutex.Lock()
+	assert.Equal(t, catalogsCount, len(catalogs.catalogs), "The archives should be summed up without watching them")
+	catalogs.mutex.Unlock()
+	summaries.mutex.Lock()
+	_, cached := summaries.summaries[filepath.Join(root, "servers/b/logs")+"\x00*.log.gz"]
+	summaries.mutex.Unlock()
+	assert.True(t, cached, "The archives of the ended instances should be summed up from the cached summaries")
+	classic, dynamic := servers[0], servers[1]
+	assert.Equal(t, "serv", classic.Tag)
+	assert.Equal(t, "/logs/archive/serv/", classic.Url)
+	assert.Equal(t, 1, classic.Archives)
+	assert.Equal(t, "2026-10-16", classic.From)
+	assert.Equal(t, "2026-10-16", classic.To)
+	info, _ := os.Stat(filepath.Join(root, "logs/2026-10-16-1.log.gz"))
+	assert.Equal(t, info.Size(), classic.CompressedSize)
+
+	assert.True(t, dynamic.IsDynamic)
+	assert.Equal(t, "/logs/dyn-archive/dyn/", dynamic.Url)
+	assert.Equal(t, 2, dynamic.Instances, "The instances without log file should be counted, but not the ones without archives")
+	assert.Equal(t, 3, dynamic.Archives)
+	assert.Equal(t, "2026-10-16", dynamic.From)
+	assert.Equal(t, "2026-10-18", dynamic.To)
+
+	w := httptest.NewRecorder()
+	archivesIndexHandler(w, CommonWebData{UrlPrefix: config.UrlPrefix}, config)
+	body := w.Body.String()
+	assert.Contains(t, body, `href="/logs/archive/serv/"`)
+	assert.Contains(t, body, `href="/logs/dyn-archive/dyn/"`)
+	assert.Contains(t, body, `href="/logs/search/serv"`)
+	assert.NotContains(t, body, "private")
+	assert.NotContains(t, body, root, "The paths of the archives should not be shown")
+}
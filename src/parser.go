@@ -0,0 +1,247 @@
+package main
+
+import (
+	"html/template"
+	"sort"
+	"strings"
+	"time"
+)
+
+// logParser turns the lines of a log file into events, with the rules of a server
+type logParser struct {
+	highlighter *lineHighlighter
+	// The rule grouping the lines into events, nil if they must not be grouped
+	multiline *multilineRule
+	// The rule extracting the level of the events, nil if they have none
+	level *levelRule
+	// The rule extracting the time of the events, nil if they have none
+	timestamp *timestampRule
+	// The format of the JSON log lines, nil if the lines are plain text
+	json *jsonFormat
+	// The rule extracting the fields of the events, nil if they have none
+	fields *fieldsRule
+	// The rule finding the day of the archives in their filenames, nil for the default one
+	archiveDates *archiveDateRule
+}
+
+// logRow is a log event shown on a page, made of one or several highlighted lines
+type logRow struct {
+	Level string
+	// The time of the event, zero if it has none
+	Time time.Time
+	// The fields of the event by key, nil if it has none
+	Fields map[string]string
+	// The number of the first line of the event in the rendered lines, starting at 1
+	Line  int
+	Lines []template.HTML
+}
+
+// newEvent returns the add event of the given lines, which make a single log event that has just been written.
+// Their highlighted versions are separated by new lines, like the lines themselves.
+func (parser *logParser) newEvent(server string, lines []string) Event {
+	if parser == nil {
+		parser = new(logParser)
+	}
+	htmls := make([]string, len(lines))
+	for i, line := range lines {
+		htmls[i] = string(parser.highlight(line))
+	}
+	event := Event{
+		Type:    eventAdd,
+		Server:  server,
+		Content: parser.text(lines),
+		Html:    template.HTML(strings.Join(htmls, "\n")),
+		Level:   parser.extractLevel(lines[0]),
+		Fields:  fieldsMap(parser.extractFields(lines[0])),
+	}
+	if eventTime := parser.extractTime(lines[0], time.Now()); !eventTime.IsZero() {
+		event.Time = &eventTime
+	}
+	return event
+}
+
+// text returns the given lines of an event as they should be read in plain text, separated by new lines
+func (parser *logParser) text(lines []string) string {
+	texts := make([]string, len(lines))
+	for i, line := range lines {
+		texts[i] = parser.highlighter.text(line)
+	}
+	return strings.Join(texts, "\n")
+}
+
+// highlight returns the HTML-escaped log line, rendered as a JSON entry if it is one
+func (parser *logParser) highlight(line string) template.HTML {
+	if entry := parser.json.parse(line); entry != nil {
+		return parser.json.render(entry, parser.highlighter)
+	}
+	return parser.highlighter.highlight(line)
+}
+
+// extractLevel returns the normalized level of the given line, or an empty string if it has none
+func (parser *logParser) extractLevel(line string) string {
+	if entry := parser.json.parse(line); entry != nil {
+		return parser.json.normalizeLevel(entry)
+	}
+	return parser.level.extract(line)
+}
+
+// extractTime returns the time of the given line, or a zero time if it has none.
+// The reference is the time the line was written at the latest, which gives the missing date of the timestamps.
+func (parser *logParser) extractTime(line string, reference time.Time) time.Time {
+	if entry := parser.json.parse(line); entry != nil {
+		return parser.json.parseTime(entry)
+	}
+	return parser.timestamp.extract(line, reference)
+}
+
+// extractFields returns the fields of the given line: the message and the other fields of a JSON line,
+// or the groups of the fields regexp
+func (parser *logParser) extractFields(line string) []logField {
+	if entry := parser.json.parse(line); entry != nil {
+		if entry.message == "" {
+			return entry.fields
+		}
+		return append([]logField{{key: parser.json.messageKey, value: entry.message}}, entry.fields...)
+	}
+	return parser.fields.extract(line)
+}
+
+// hasLevels returns whether the events of the server can have a level
+func (parser *logParser) hasLevels() bool {
+	return parser != nil && (parser.level != nil || parser.json != nil)
+}
+
+// hasTimestamps returns whether the events of the server can have a time
+func (parser *logParser) hasTimestamps() bool {
+	return parser != nil && (parser.timestamp != nil || parser.json != nil)
+}
+
+// columns returns the columns of the table view of the given rows, or nil if the events have no fields.
+// They are the groups of the fields regexp, or the message key followed by the keys found in the JSON lines.
+func (parser *logParser) columns(rows []logRow) []string {
+	if parser == nil || (parser.fields == nil && parser.json == nil) {
+		return nil
+	}
+	var columns []string
+	found := make(map[string]bool)
+	if parser.fields != nil {
+		columns = append(columns, parser.fields.names...)
+	}
+	if parser.json != nil {
+		columns = append(columns, parser.json.messageKey)
+	}
+	for _, column := range columns {
+		found[column] = true
+	}
+	var others []string
+	for _, row := range rows {
+		for key := range row.Fields {
+			if !found[key] {
+				found[key] = true
+				others = append(others, key)
+			}
+		}
+	}
+	sort.Strings(others)
+	return append(columns, others...)
+}
+
+// group splits the given lines of a log file into events
+func (parser *logParser) group(lines []string) [][]string {
+	// the file content ends with a new line, which results in an empty last line
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if parser == nil {
+		parser = new(logParser)
+	}
+	return parser.multiline.group(lines)
+}
+
+// eventTimes returns the times of the given events of a log file, zero for the events without timestamp.
+// The reference is the time the last event was written at the latest, and the events are read from the last one,
+// so that every event gives the day of the previous one when the timestamps have no date.
+func (parser *logParser) eventTimes(events [][]string, reference time.Time) []time.Time {
+	times := make([]time.Time, len(events))
+	if !parser.hasTimestamps() {
+		return times
+	}
+	for i := len(events) - 1; i >= 0; i-- {
+		times[i] = parser.extractTime(events[i][0], reference)
+		if !times[i].IsZero() {
+			reference = times[i]
+		}
+	}
+	return times
+}
+
+// renderLogs groups the given lines of a log file into events and highlights the ones matching the query, which can be nil.
+// The reference is the time the log file was last written.
+func (parser *logParser) renderLogs(lines []string, reference time.Time, query *logQuery) []logRow {
+	if parser == nil {
+		parser = new(logParser)
+	}
+	events := parser.group(lines)
+	times := parser.eventTimes(events, reference)
+	rows := make([]logRow, 0, len(events))
+	lineNumber := 1
+	for i, event := range events {
+		row := logRow{
+			Level:  parser.extractLevel(event[0]),
+			Time:   times[i],
+			Fields: fieldsMap(parser.extractFields(event[0])),
+			Line:   lineNumber,
+		}
+		lineNumber += len(event)
+		if query != nil && !query.matches(logRecord{text: parser.text(event), level: row.Level, time: row.Time, fields: row.Fields}) {
+			continue
+		}
+		row.Lines = make([]template.HTML, len(event))
+		for j, line := range event {
+			row.Lines[j] = parser.highlight(line)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// record returns the record of the given event, to evaluate queries against it
+func (parser *logParser) record(event []string, eventTime time.Time) logRecord {
+	return logRecord{
+		text:   parser.text(event),
+		level:  parser.extractLevel(event[0]),
+		time:   eventTime,
+		fields: fieldsMap(parser.extractFields(event[0])),
+	}
+}
+
+// filterLines returns the lines of the events matching the query, or all of them if the query is nil
+func (parser *logParser) filterLines(lines []string, reference time.Time, query *logQuery) []string {
+	if query == nil {
+		return lines
+	}
+	if parser == nil {
+		parser = new(logParser)
+	}
+	events := parser.group(lines)
+	times := parser.eventTimes(events, reference)
+	var filtered []string
+	for i, event := range events {
+		if query.matches(parser.record(event, times[i])) {
+			filtered = append(filtered, event...)
+		}
+	}
+	return filtered
+}
+
+// countLevels returns the number of rows of every level, or nil if the events have no level
+func (parser *logParser) countLevels(rows []logRow) []levelCount {
+	if !parser.hasLevels() {
+		return nil
+	}
+	levels := make([]string, len(rows))
+	for i, row := range rows {
+		levels[i] = row.Level
+	}
+	return countLevels(levels)
+}
@@ -0,0 +1,454 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The query language filters the log events with terms combined by AND (implicit between terms), OR, NOT and parentheses.
+// A term is either a text contained in the event, case-insensitively, or a comparison of one of its fields:
+//
+//	level>=warn AND msg~"time(d )?out" AND NOT player:"Notch"
+//	status=404 OR status=500
+//	time>=2026-10-17T12:00 time<-1h "Can't keep up"
+//
+// The comparisons are field:value (contains, case-insensitively), field=value, field!=value, field~regexp,
+// and field>value, field>=value, field<value, field<=value which compare numbers when both values are ones.
+// The level field compares severities, and the time field compares times, a negative duration being relative to the time
+// the query is evaluated at. The line field is the whole text of the event, like the msg and message fields when the event
+// has no such field. The comparisons of the other fields are texts for the events without fields, like http://example.com.
+// In quoted strings, \" and \\ are the only escape sequences, so that the regexps can be written as is: line~"^\[12:"
+
+// logRecord is what the queries are evaluated against: a log event with the values extracted from it
+type logRecord struct {
+	// The lines of the event, separated by new lines
+	text  string
+	level string
+	// The time of the event, zero if it has none
+	time   time.Time
+	fields map[string]string
+}
+
+// logQuery is a parsed query
+type logQuery struct {
+	root queryNode
+	// The query as written by the user
+	source string
+}
+
+type queryNode interface {
+	matches(record *logRecord) bool
+}
+
+type (
+	andNode struct{ left, right queryNode }
+	orNode  struct{ left, right queryNode }
+	notNode struct{ node queryNode }
+	// textTerm matches the events containing a text, case-insensitively
+	textTerm struct{ text string }
+	// fieldTerm compares a field of the events with a value
+	fieldTerm struct {
+		field, operator, value string
+		// The compiled value of the ~ operator
+		regexp *regexp.Regexp
+		// The term as a text, matched by the events which have no fields
+		text textTerm
+	}
+	// levelTerm compares the severity of the events with the one of a level
+	levelTerm struct {
+		operator string
+		severity int
+	}
+	// timeTerm compares the time of the events with a time, or with the time preceding the evaluation by a duration
+	timeTerm struct {
+		operator string
+		time     time.Time
+		// The negative duration added to the time of the evaluation, 0 if the time is absolute
+		relative time.Duration
+	}
+)
+
+// queryComparisonRegexp splits a comparison term into its field, operator and value
+var queryComparisonRegexp = regexp.MustCompile(`(?s)^([A-Za-z_@][\w.@-]*)(!=|>=|<=|=|:|~|>|<)(.*)$`)
+
+// queryTimeLayouts are the layouts of the times of the queries, the ones without offset being in the local timezone
+// and the ones without date being of the current day
+var queryTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"15:04:05",
+	"15:04",
+}
+
+// parseQuery parses the given query, and returns nil if it is empty
+func parseQuery(query string) (*logQuery, error) {
+	tokens, err := tokenizeQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+	parser := queryParser{tokens: tokens}
+	root, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if parser.pos < len(parser.tokens) {
+		return nil, fmt.Errorf("unexpected %q", parser.tokens[parser.pos])
+	}
+	return &logQuery{root: root, source: query}, nil
+}
+
+// matches returns whether the given record matches the query, which is always the case for a nil query
+func (query *logQuery) matches(record logRecord) bool {
+	if query == nil {
+		return true
+	}
+	return query.root.matches(&record)
+}
+
+// requiredTexts returns texts contained, case-insensitively, in every event matching the query,
+// so that the archives which do not contain them can be skipped
+func (query *logQuery) requiredTexts() []string {
+	if query == nil {
+		return nil
+	}
+	return requiredTexts(query.root)
+}
+
+func requiredTexts(node queryNode) []string {
+	switch node := node.(type) {
+	case andNode:
+		return append(requiredTexts(node.left), requiredTexts(node.right)...)
+	case textTerm:
+		return []string{node.text}
+	}
+	// the texts of the other nodes are not required, e.g. with OR or NOT
+	return nil
+}
+
+// tokenizeQuery splits the query into parentheses and words, the quoted strings being part of the words
+func tokenizeQuery(query string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(query); {
+		switch c := query[i]; {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, string(c))
+			i++
+		default:
+			start := i
+			for i < len(query) && !strings.ContainsRune(" \t\n()", rune(query[i])) {
+				if query[i] == '"' {
+					end := closingQuoteIndex(query, i)
+					if end < 0 {
+						return nil, errors.New("unterminated quoted string")
+					}
+					i = end
+				}
+				i++
+			}
+			tokens = append(tokens, query[start:i])
+		}
+	}
+	return tokens, nil
+}
+
+// closingQuoteIndex returns the index of the quote closing the one at the given index, or -1 if there is none
+func closingQuoteIndex(query string, start int) int {
+	for i := start + 1; i < len(query); i++ {
+		switch query[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// queryEscapeReplacer unescapes the quoted strings, whose other backslashes are kept for the regexps
+var queryEscapeReplacer = strings.NewReplacer(`\"`, `"`, `\\`, `\`)
+
+// unquote returns the value without its quotes, if it is a quoted string
+func unquote(value string) string {
+	if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return value
+	}
+	return queryEscapeReplacer.Replace(value[1 : len(value)-1])
+}
+
+type queryParser struct {
+	tokens []string
+	pos    int
+}
+
+func (parser *queryParser) peek() string {
+	if parser.pos < len(parser.tokens) {
+		return parser.tokens[parser.pos]
+	}
+	return ""
+}
+
+func (parser *queryParser) parseOr() (queryNode, error) {
+	left, err := parser.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for parser.peek() == "OR" {
+		parser.pos++
+		right, err := parser.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+func (parser *queryParser) parseAnd() (queryNode, error) {
+	left, err := parser.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for token := parser.peek(); token != "" && token != "OR" && token != ")"; token = parser.peek() {
+		if token == "AND" {
+			parser.pos++
+		}
+		right, err := parser.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+	return left, nil
+}
+
+func (parser *queryParser) parseNot() (queryNode, error) {
+	if parser.peek() == "NOT" {
+		parser.pos++
+		node, err := parser.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{node}, nil
+	}
+	return parser.parsePrimary()
+}
+
+func (parser *queryParser) parsePrimary() (queryNode, error) {
+	token := parser.peek()
+	switch token {
+	case "":
+		return nil, errors.New("unexpected end of query")
+	case "AND", "OR", ")":
+		return nil, fmt.Errorf("unexpected %q", token)
+	case "(":
+		parser.pos++
+		node, err := parser.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if parser.peek() != ")" {
+			return nil, errors.New("missing closing parenthesis")
+		}
+		parser.pos++
+		return node, nil
+	}
+	parser.pos++
+	return parseQueryTerm(token)
+}
+
+// parseQueryTerm parses a comparison, or a text if the token is not one
+func parseQueryTerm(token string) (queryNode, error) {
+	matches := queryComparisonRegexp.FindStringSubmatch(token)
+	if matches == nil {
+		return textTerm{strings.ToLower(unquote(token))}, nil
+	}
+	field, operator := matches[1], matches[2]
+	value := unquote(matches[3])
+
+	switch field {
+	case "level":
+		severity := levelSeverity(strings.ToLower(value))
+		if severity < 0 {
+			return nil, fmt.Errorf("invalid level %q: must be one of %s", value, strings.Join(knownLevels, ", "))
+		}
+		if operator == "~" {
+			return nil, errors.New("the level cannot be compared with a regexp")
+		}
+		return levelTerm{operator: operator, severity: severity}, nil
+	case "time":
+		if operator == ":" || operator == "~" || operator == "!=" {
+			return nil, errors.New("the time can only be compared with =, >, >=, < or <=")
+		}
+		if relative, isRelative := parseRelativeTime(value); isRelative {
+			return timeTerm{operator: operator, relative: relative}, nil
+		}
+		parsed, err := parseQueryTime(value, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		return timeTerm{operator: operator, time: parsed}, nil
+	}
+
+	term := fieldTerm{field: field, operator: operator, value: value, text: textTerm{strings.ToLower(unquote(token))}}
+	var err error
+	switch operator {
+	case "~":
+		term.regexp, err = regexp.Compile(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regexp for %s: %w", field, err)
+		}
+	case ":":
+		term.value = strings.ToLower(value)
+	}
+	return term, nil
+}
+
+// parseRelativeTime returns the duration of the given time of a query if it is a negative one like -15m
+func parseRelativeTime(value string) (time.Duration, bool) {
+	if !strings.HasPrefix(value, "-") {
+		return 0, false
+	}
+	duration, err := time.ParseDuration(value)
+	return duration, err == nil && duration < 0
+}
+
+// parseQueryTime parses the time of a query, which is relative to now if it is a negative duration like -15m
+func parseQueryTime(value string, now time.Time) (time.Time, error) {
+	if duration, isRelative := parseRelativeTime(value); isRelative {
+		return now.Add(duration), nil
+	}
+	for _, layout := range queryTimeLayouts {
+		parsed, err := time.ParseInLocation(layout, value, time.Local)
+		if err != nil {
+			continue
+		}
+		if parsed.Year() == 0 {
+			year, month, day := now.Date()
+			parsed = time.Date(year, month, day, parsed.Hour(), parsed.Minute(), parsed.Second(), 0, time.Local)
+		}
+		return parsed, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: must be like 2006-01-02T15:04:05, 15:04 or -1h", value)
+}
+
+// record returns the record of the add event, to evaluate queries against it
+func (event Event) record() logRecord {
+	record := logRecord{text: event.Content, level: event.Level, fields: event.Fields}
+	if event.Time != nil {
+		record.time = *event.Time
+	}
+	return record
+}
+
+// compareOrder tells whether the given order of two values (-1, 0 or 1) satisfies the operator
+func compareOrder(order int, operator string) bool {
+	switch operator {
+	case ">":
+		return order > 0
+	case ">=":
+		return order >= 0
+	case "<":
+		return order < 0
+	case "<=":
+		return order <= 0
+	case "!=":
+		return order != 0
+	default: // = or :
+		return order == 0
+	}
+}
+
+func (node andNode) matches(record *logRecord) bool {
+	return node.left.matches(record) && node.right.matches(record)
+}
+
+func (node orNode) matches(record *logRecord) bool {
+	return node.left.matches(record) || node.right.matches(record)
+}
+
+func (node notNode) matches(record *logRecord) bool {
+	return !node.node.matches(record)
+}
+
+func (term textTerm) matches(record *logRecord) bool {
+	return strings.Contains(strings.ToLower(record.text), term.text)
+}
+
+func (term levelTerm) matches(record *logRecord) bool {
+	severity := levelSeverity(record.level)
+	if severity < 0 {
+		return term.operator == "!="
+	}
+	return compareOrder(severity-term.severity, term.operator)
+}
+
+func (term timeTerm) matches(record *logRecord) bool {
+	if record.time.IsZero() {
+		return false
+	}
+	at := term.time
+	if term.relative != 0 {
+		at = time.Now().Add(term.relative)
+	}
+	switch {
+	case record.time.Before(at):
+		return compareOrder(-1, term.operator)
+	case record.time.After(at):
+		return compareOrder(1, term.operator)
+	default:
+		return compareOrder(0, term.operator)
+	}
+}
+
+func (term fieldTerm) matches(record *logRecord) bool {
+	value, found := record.fields[term.field]
+	if !found {
+		switch term.field {
+		case "line", "msg", "message":
+			value = record.text
+		default:
+			if record.fields == nil {
+				// the term is not a comparison for the events without fields, like the ones of the servers extracting none
+				return term.text.matches(record)
+			}
+			return term.operator == "!="
+		}
+	}
+
+	switch term.operator {
+	case ":":
+		return strings.Contains(strings.ToLower(value), term.value)
+	case "~":
+		return term.regexp.MatchString(value)
+	case "=":
+		return value == term.value
+	case "!=":
+		return value != term.value
+	}
+	// numbers are compared as such, and other values as strings
+	number, err1 := strconv.ParseFloat(value, 64)
+	termNumber, err2 := strconv.ParseFloat(term.value, 64)
+	if err1 == nil && err2 == nil {
+		switch {
+		case number < termNumber:
+			return compareOrder(-1, term.operator)
+		case number > termNumber:
+			return compareOrder(1, term.operator)
+		}
+		return compareOrder(0, term.operator)
+	}
+	return compareOrder(strings.Compare(value, term.value), term.operator)
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryParsing(t *testing.T) {
+	query, err := parseQuery("  ")
+	assert.NoError(t, err)
+	assert.Nil(t, query, "An empty query should not filter anything")
+	assert.True(t, query.matches(logRecord{text: "anything"}))
+
+	for _, valid := range []string{
+		`level>=warn AND msg~"timeout" AND NOT player:"Notch"`,
+		`(status=404 OR status=500) path:/api`,
+		`time>=2026-10-17T12:00 time<-1h`,
+		`"Can't keep up!"`,
+		`NOT NOT error`,
+	} {
+		_, err := parseQuery(valid)
+		assert.NoError(t, err, valid)
+	}
+
+	for _, invalid := range []string{
+		`level>=loud`,
+		`level~warn`,
+		`time:12:00`,
+		`time>tomorrow`,
+		`msg~"(unclosed"`,
+		`"unterminated`,
+		`(error`,
+		`error)`,
+		`error AND`,
+		`OR error`,
+		`NOT`,
+	} {
+		_, err := parseQuery(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestQueryMatching(t *testing.T) {
+	eventTime := time.Date(2026, 10, 17, 12, 30, 0, 0, time.Local)
+	record := logRecord{
+		text:   "[12:30:00] [Server thread/WARN]: Can't keep up! Connection timeout for Notch",
+		level:  levelWarn,
+		time:   eventTime,
+		fields: map[string]string{"player": "Notch", "status": "500", "took": "1.5"},
+	}
+	cases := map[string]bool{
+		`keep`:                               true,
+		`"can't keep up"`:                    true,
+		`KEEP missing`:                       false,
+		`keep OR missing`:                    true,
+		`NOT missing`:                        true,
+		`level>=warn AND msg~"time(d )?out"`: true,
+		`level>=warn AND NOT player:"notch"`: false,
+		`level>error`:                        false,
+		`level=warn`:                         true,
+		`level!=info`:                        true,
+		`player=Notch`:                       true,
+		`player=notch`:                       false,
+		`player!=Steve`:                      true,
+		`unknown!=value`:                     true,
+		`unknown=value`:                      false,
+		`status>=400 status<500`:             false,
+		`status>=400 (status<500 OR took>1)`: true,
+		`took<=1.5`:                          true,
+		`took>10`:                            false,
+		`line~"^\[12:30"`:                    true,
+		`time>=2026-10-17T12:00 time<2026-10-17T13:00`: true,
+		`time>2026-10-17T12:30:00`:                     false,
+		`time=2026-10-17T12:30`:                        true,
+	}
+	for query, expected := range cases {
+		parsed, err := parseQuery(query)
+		if assert.NoError(t, err, query) {
+			assert.Equal(t, expected, parsed.matches(record), query)
+		}
+	}
+
+	timeless, _ := parseQuery("time>-1h")
+	assert.False(t, timeless.matches(logRecord{text: "no time"}), "An event without time should not match a time range")
+	levelless, _ := parseQuery("level<error")
+	assert.False(t, levelless.matches(logRecord{text: "no level"}))
+}
+
+func TestQueryFieldsAsTexts(t *testing.T) {
+	record := logRecord{text: "GET https://example.com/a?b=c failed: Exception: timeout"}
+	for query, expected := range map[string]bool{
+		`https://example.com/a?b=c`: true,
+		`Exception:`:                true,
+		`failed:`:                   true,
+		`https://other.com`:         false,
+		`line:timeout`:              true,
+	} {
+		parsed, err := parseQuery(query)
+		if assert.NoError(t, err, query) {
+			assert.Equal(t, expected, parsed.matches(record), "%s should be a text for the events without fields", query)
+		}
+	}
+
+	withFields := logRecord{text: "https://example.com", fields: map[string]string{"status": "200"}}
+	parsed, _ := parseQuery("https://example.com")
+	assert.False(t, parsed.matches(withFields), "The field should be compared for the events having fields")
+}
+
+func TestQueryRelativeTime(t *testing.T) {
+	query, err := parseQuery("time>=-1h")
+	if !assert.NoError(t, err) {
+		return
+	}
+	recent := logRecord{text: "recent", time: time.Now().Add(-30 * time.Minute)}
+	assert.True(t, query.matches(recent))
+	term := query.root.(timeTerm)
+	assert.True(t, term.time.IsZero(), "A relative time should not be fixed when the query is parsed")
+	assert.Equal(t, -time.Hour, term.relative)
+	old := logRecord{text: "old", time: time.Now().Add(-2 * time.Hour)}
+	assert.False(t, query.matches(old))
+}
+
+func TestQueryTime(t *testing.T) {
+	now := time.Date(2026, 10, 17, 18, 0, 0, 0, time.Local)
+	for value, expected := range map[string]time.Time{
+		"-90m":                      now.Add(-90 * time.Minute),
+		"2026-10-16":                time.Date(2026, 10, 16, 0, 0, 0, 0, time.Local),
+		"2026-10-16 08:15":          time.Date(2026, 10, 16, 8, 15, 0, 0, time.Local),
+		"08:15:30":                  time.Date(2026, 10, 17, 8, 15, 30, 0, time.Local),
+		"2026-10-16T08:15:00+02:00": time.Date(2026, 10, 16, 8, 15, 0, 0, time.FixedZone("", 2*60*60)),
+	} {
+		parsed, err := parseQueryTime(value, now)
+		if assert.NoError(t, err, value) {
+			assert.True(t, expected.Equal(parsed), "%s: expected %s, got %s", value, expected, parsed)
+		}
+	}
+}
+
+func TestFilterLines(t *testing.T) {
+	level, _ := LevelConfig{Regex: `^(?P<level>[A-Z]+)`}.compile(nil)
+	multiline, _ := MultilineConfig{ContinuationPattern: `^\s`}.compile(nil)
+	parser := &logParser{level: level, multiline: multiline}
+	query, _ := parseQuery("level>=error")
+	assert.Equal(t, []string{"ERROR failure", "  at somewhere"},
+		parser.filterLines([]string{"INFO start", "ERROR failure", "  at somewhere", "WARN slow"}, time.Now(), query),
+		"The lines of the matching events should be kept together")
+}
@@ -0,0 +1,502 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// The number of lines shown before and after every hit by default, and at most
+	defaultSearchContextLines = 2
+	maxSearchContextLines     = 20
+	// The number of hits after which a search stops by default, and at most
+	defaultSearchHitsLimit = 500
+	maxSearchHitsLimit     = 10000
+	// The layout of the dates bounding a search
+	searchDateLayout = "2006-01-02"
+)
+
+// errArchivesDisabled is returned when searching the archives of a server which has none
+var errArchivesDisabled = errors.New("the archives of this server are not available")
+
+const (
+	searchMessageHit   = "hit"
+	searchMessageError = "error"
+	searchMessageDone  = "done"
+)
+
+// searchedArchive is an archived log file of a search
+type searchedArchive struct {
+	location archiveLocation
+	entry    archiveEntry
+	// The archives directory the file has been found in
+	dir string
+	// The identifier of the file in the urls of the archive viewer
+	id string
+	// The instance of the dynamic server the file belongs to, empty for a classic server
+	instance string
+	// The time the last line of the file was written at the latest
+	reference time.Time
+}
+
+// archiveSearch is a search of a query in the archived log files of a server, or of an instance of a dynamic server
+type archiveSearch struct {
+	server string
+	// The display name of the server, with the identifier of the instance if it is restricted to one
+	displayName string
+	isDynamic   bool
+	urlPrefix   string
+	parser      *logParser
+	archives    []searchedArchive
+
+	query *logQuery
+	// The texts every hit contains, see logQuery.requiredTexts
+	requiredTexts []string
+	// The days of the first and last archives to search, zero if they are not bounded
+	from, to time.Time
+	// The number of lines sent before and after every hit
+	contextLines int
+	// The number of hits after which the search stops
+	limit int
+}
+
+// searchHit is an event of an archive matching the query of a search, with the lines around it
+type searchHit struct {
+	Archive  string `json:"archive"`
+	Instance string `json:"instance,omitempty"`
+	// The number of the first line of the event in the archive, starting at 1
+	Line   int      `json:"line"`
+	Before []string `json:"before"`
+	Lines  []string `json:"lines"`
+	After  []string `json:"after"`
+	// The url of the archive viewer showing the event
+	Url string `json:"url"`
+}
+
+// searchMessage is a line of the response of the search endpoint, which is a stream of JSON objects separated by new lines
+type searchMessage struct {
+	Type string     `json:"type"`
+	Hit  *searchHit `json:"hit,omitempty"`
+	// The archive which could not be searched, and why, for error messages
+	Archive string `json:"archive,omitempty"`
+	Error   string `json:"error,omitempty"`
+	// The number of archives searched and of hits found, and whether the search stopped at the limit, for done messages
+	Archives  int  `json:"archives,omitempty"`
+	Hits      int  `json:"hits,omitempty"`
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// newArchiveSearch returns the search of the archives of the given server, restricted to one of its instances
+// if it is a dynamic server and the instance is not empty. It returns an error if the server has no archives.
+func newArchiveSearch(config Config, server, instance string) (search *archiveSearch, found bool, err error) {
+	for _, servCfg := range config.Servers.Classic {
+		if servCfg.ServerTag != server {
+			continue
+		}
+		if !servCfg.archivesEnabled {
+			return nil, true, errArchivesDisabled
+		}
+		search = &archiveSearch{server: server, displayName: servCfg.DisplayName, urlPrefix: config.UrlPrefix, parser: servCfg.parser}
+		return search, true, search.addArchives(servCfg.getArchivedLogsDirPath(), servCfg.ArchivedLogFilenameFormat, "")
+	}
+
+	for _, servCfg := range config.Servers.Dynamic {
+		if servCfg.ServerTag != server {
+			continue
+		}
+		if !servCfg.archivesEnabled {
+			return nil, true, errArchivesDisabled
+		}
+		displayName := strings.ReplaceAll(servCfg.DisplayName, "%id%", "<D>")
+		if instance != "" {
+			displayName = strings.ReplaceAll(servCfg.DisplayName, "%id%", instance)
+		}
+		search = &archiveSearch{server: server, displayName: displayName, isDynamic: true, urlPrefix: config.UrlPrefix, parser: servCfg.parser}
+		locations, err := getDynamicArchivesLocations(servCfg, instance)
+		if err != nil {
+			return nil, true, err
+		}
+		for _, location := range locations {
+			if err = search.addArchives(location.dir, location.pattern, location.instance); err != nil {
+				return nil, true, err
+			}
+		}
+		return search, true, nil
+	}
+
+	return nil, false, nil
+}
+
+// archivesLocation is a directory of archived log files, with the pattern of their names
+type archivesLocation struct {
+	dir, pattern string
+	// The instance of the dynamic server the archives belong to, empty for a classic server
+	instance string
+}
+
+// addArchives adds the archived log files of the given directory to the search
+func (search *archiveSearch) addArchives(logsDir, logsFilePattern, instance string) error {
+	catalog := catalogs.get(logsDir, logsFilePattern, search.parser)
+	entries, err := catalog.list()
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		location := catalog.locationOf(entry)
+		search.archives = append(search.archives, searchedArchive{
+			location:  location,
+			entry:     entry,
+			dir:       logsDir,
+			id:        entry.Id,
+			instance:  instance,
+			reference: search.parser.archiveReferenceTime(location),
+		})
+	}
+	return nil
+}
+
+// parseOptions reads the options of the search from the given query params:
+// q (the query, required), from and to (the days bounding the archives), context (lines) and limit (hits)
+func (search *archiveSearch) parseOptions(params url.Values) (err error) {
+	search.query, err = parseQuery(params.Get("q"))
+	if err != nil {
+		return fmt.Errorf("invalid query: %w", err)
+	}
+	if search.query == nil {
+		return errors.New("a query is required")
+	}
+	search.requiredTexts = search.query.requiredTexts()
+
+	for _, bound := range []struct {
+		param string
+		value *time.Time
+	}{{"from", &search.from}, {"to", &search.to}} {
+		if value := params.Get(bound.param); value != "" {
+			if *bound.value, err = time.ParseInLocation(searchDateLayout, value, time.Local); err != nil {
+				return fmt.Errorf("invalid %s date %q: must be like %s", bound.param, value, searchDateLayout)
+			}
+		}
+	}
+
+	search.contextLines, err = parseBoundedInt(params.Get("context"), defaultSearchContextLines, 0, maxSearchContextLines)
+	if err != nil {
+		return fmt.Errorf("invalid context lines count: %w", err)
+	}
+	search.limit, err = parseBoundedInt(params.Get("limit"), defaultSearchHitsLimit, 1, maxSearchHitsLimit)
+	if err != nil {
+		return fmt.Errorf("invalid hits limit: %w", err)
+	}
+	return nil
+}
+
+// parseBoundedInt parses the given number, which must be between lower and upper, and returns the default value if it is empty
+func parseBoundedInt(value string, defaultValue, lower, upper int) (int, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < lower || n > upper {
+		return 0, fmt.Errorf("%q is not a number between %d and %d", value, lower, upper)
+	}
+	return n, nil
+}
+
+// isInRange returns whether the archive has been written between the days bounding the search
+func (search *archiveSearch) isInRange(archive searchedArchive) bool {
+	if !search.from.IsZero() && archive.reference.Before(search.from) {
+		return false
+	}
+	return search.to.IsZero() || archive.reference.Before(search.to.AddDate(0, 0, 1))
+}
+
+// run searches the archives concurrently, and calls emit with the messages in the order they are found, from a single goroutine.
+// It returns the done message once all the archives have been searched, the hits limit has been reached or the context is done.
+func (search *archiveSearch) run(ctx context.Context, emit func(searchMessage)) searchMessage {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	archives := make(chan searchedArchive)
+	messages := make(chan searchMessage)
+	workers := new(sync.WaitGroup)
+	for i := 0; i < runtime.NumCPU(); i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for archive := range archives {
+				search.searchArchive(ctx, archive, messages)
+			}
+		}()
+	}
+	go func() {
+		defer close(archives)
+		for _, archive := range search.archives {
+			if !search.isInRange(archive) {
+				continue
+			}
+			select {
+			case archives <- archive:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		workers.Wait()
+		close(messages)
+	}()
+
+	done := searchMessage{Type: searchMessageDone}
+	for message := range messages {
+		switch {
+		case message.Type == searchMessageDone:
+			done.Archives++
+		case message.Type == searchMessageHit && done.Hits >= search.limit:
+			// the next hits found before the workers stop are dropped
+			if !done.Truncated {
+				done.Truncated = true
+				cancel()
+			}
+		default:
+			if message.Type == searchMessageHit {
+				done.Hits++
+			}
+			emit(message)
+		}
+	}
+	return done
+}
+
+// searchArchive sends the hits of the given archive to the messages channel, followed by a done message if it has been fully searched
+func (search *archiveSearch) searchArchive(ctx context.Context, archive searchedArchive, messages chan<- searchMessage) {
+	send := func(message searchMessage) bool {
+		select {
+		case messages <- message:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	// the index tells which archives cannot match without reading them
+	if !archivesIndex.mayContain(archive.dir, archive.entry, search.requiredTexts) {
+		send(searchMessage{Type: searchMessageDone})
+		return
+	}
+
+	content, err := uncompress(archive.location)
+	if err != nil {
+		send(searchMessage{Type: searchMessageError, Archive: archive.location.name(), Error: err.Error()})
+		return
+	}
+	lines := strings.Split(string(content), "\n")
+	// the file content ends with a new line, which results in an empty last line
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	events := search.parser.group(lines)
+	times := search.parser.eventTimes(events, archive.reference)
+	start := 0
+	for i, event := range events {
+		end := start + len(event)
+		if search.query.matches(search.parser.record(event, times[i])) {
+			before := start - search.contextLines
+			if before < 0 {
+				before = 0
+			}
+			after := end + search.contextLines
+			if after > len(lines) {
+				after = len(lines)
+			}
+			hit := &searchHit{
+				Archive:  archive.location.name(),
+				Instance: archive.instance,
+				Line:     start + 1,
+				Before:   search.textLines(lines[before:start]),
+				Lines:    search.textLines(event),
+				After:    search.textLines(lines[end:after]),
+				Url:      search.archiveUrl(archive, start+1),
+			}
+			if !send(searchMessage{Type: searchMessageHit, Hit: hit}) {
+				return
+			}
+		}
+		start = end
+	}
+	send(searchMessage{Type: searchMessageDone})
+}
+
+// textLines returns the given lines as they should be read in plain text
+func (search *archiveSearch) textLines(lines []string) []string {
+	texts := make([]string, len(lines))
+	for i, line := range lines {
+		texts[i] = search.parser.text([]string{line})
+	}
+	return texts
+}
+
+// archiveUrl returns the url of the archive viewer showing the given line of the archive
+func (search *archiveSearch) archiveUrl(archive searchedArchive, line int) string {
+	if search.isDynamic {
+		return fmt.Sprintf("%s/dyn-archive/%s/%s/%s?line=%d", search.urlPrefix, search.server, url.PathEscape(archive.instance), archive.id, line)
+	}
+	return fmt.Sprintf("%s/archive/%s/%s?line=%d", search.urlPrefix, search.server, archive.id, line)
+}
+
+// apiSearchHandler searches the archives of a server on /api/search/{server}, and streams the hits as they are found,
+// as JSON objects separated by new lines. The last one is the done message, unless the connection is closed before.
+// The instance query param restricts the search to an instance of a dynamic server, see parseOptions for the other ones.
+func apiSearchHandler(w http.ResponseWriter, r *http.Request, config Config) {
+	server := strings.TrimPrefix(r.URL.Path, "/api/search/")
+	search, found, err := newArchiveSearch(config, server, r.URL.Query().Get("instance"))
+	if !found {
+		prettier(w, "Unknown server: "+server, nil, http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, errArchivesDisabled) {
+		prettier(w, "No archives for server "+server, nil, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		printError(err)
+		prettier(w, "Failed to list the archives of server "+server, nil, http.StatusInternalServerError)
+		return
+	}
+	if err = search.parseOptions(r.URL.Query()); err != nil {
+		prettier(w, "Invalid search: "+err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // disables nginx buffering
+	w.WriteHeader(http.StatusOK)
+
+	encoder := json.NewEncoder(w)
+	write := func(message searchMessage) {
+		if err := encoder.Encode(message); err != nil {
+			debugPrint("Failed to write search message: " + err.Error())
+			return
+		}
+		flusher.Flush()
+	}
+	write(search.run(r.Context(), write))
+}
+
+// SearchWebData contains the data of the archives search page
+type SearchWebData struct {
+	Server            string
+	Instance          string
+	ServerDisplayName string
+	// The instances of the dynamic server which have archives, nil for a classic server
+	Instances []string
+	// The number of archives which can be searched
+	ArchivesCount int
+	// The options of the search, as written in the url
+	Query, From, To string
+	ContextLines    int
+}
+
+// searchFormOf returns the data of the search form of the given server, without the options of the search. The archives are
+// counted from the cached summaries of their directories, so that showing the form makes no catalog for every instance.
+func searchFormOf(config Config, server string) (data SearchWebData, found bool, err error) {
+	for _, servCfg := range config.Servers.Classic {
+		if servCfg.ServerTag != server {
+			continue
+		}
+		if !servCfg.archivesEnabled {
+			return data, true, errArchivesDisabled
+		}
+		summary, err := summaries.get(servCfg.getArchivedLogsDirPath(), servCfg.ArchivedLogFilenameFormat, servCfg.parser)
+		if err != nil {
+			return data, true, err
+		}
+		return SearchWebData{Server: server, ServerDisplayName: servCfg.DisplayName, ArchivesCount: summary.Archives}, true, nil
+	}
+
+	for _, servCfg := range config.Servers.Dynamic {
+		if servCfg.ServerTag != server {
+			continue
+		}
+		if !servCfg.archivesEnabled {
+			return data, true, errArchivesDisabled
+		}
+		data = SearchWebData{Server: server, ServerDisplayName: strings.ReplaceAll(servCfg.DisplayName, "%id%", "<D>")}
+		locations, err := getDynamicArchivesLocations(servCfg, "")
+		if err != nil {
+			return data, true, err
+		}
+		// the locations are sorted by instance
+		for _, location := range locations {
+			summary, err := summaries.get(location.dir, location.pattern, servCfg.parser)
+			if err != nil {
+				// the other instances can be searched anyway
+				printError(err)
+				continue
+			}
+			if summary.Archives > 0 {
+				data.ArchivesCount += summary.Archives
+				data.Instances = append(data.Instances, location.instance)
+			}
+		}
+		return data, true, nil
+	}
+
+	return data, false, nil
+}
+
+// searchHandler serves the page searching the archives of a server on /search/{server},
+// which runs the search of its query params with the search endpoint and shows the hits as they are found
+func searchHandler(w http.ResponseWriter, r *http.Request, templateCommonData CommonWebData, config Config) {
+	server := strings.TrimPrefix(r.URL.Path, "/search/")
+	data, found, err := searchFormOf(config, server)
+	if !found || errors.Is(err, errArchivesDisabled) {
+		http.Redirect(w, r, config.UrlPrefix+"/", http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		handleTemplateError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// the page has the navbar of the index, as it is not about the logs of a single server
+	tmpl, err := parseTemplates(getFuncMapFor("", true, false, false), "search", "navbar")
+	if err != nil {
+		handleTemplateError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	data.Instance = r.URL.Query().Get("instance")
+	data.Query, data.From, data.To = r.URL.Query().Get("q"), r.URL.Query().Get("from"), r.URL.Query().Get("to")
+	data.ContextLines = defaultSearchContextLines
+	if contextLines, err := strconv.Atoi(r.URL.Query().Get("context")); err == nil {
+		data.ContextLines = contextLines
+	}
+
+	templateCommonData.ExecDate = time.Now().Format("15:04:05")
+	err = tmpl.Execute(w, struct {
+		CommonWebData
+		SearchWebData
+	}{
+		CommonWebData: templateCommonData,
+		SearchWebData: data,
+	})
+	if doDebug {
+		if err != nil {
+			printError(err)
+		}
+	}
+}
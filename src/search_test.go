@@ -0,0 +1,130 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeTestArchive writes the given lines to a gzipped archive of the given directory
+func writeTestArchive(t *testing.T, dir, name string, lines ...string) {
+	file, err := os.Create(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatalf("failed to create archive: %v", err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	gzWriter := gzip.NewWriter(file)
+	_, _ = gzWriter.Write([]byte(strings.Join(lines, "\n") + "\n"))
+	assert.NoError(t, gzWriter.Close())
+}
+
+func newTestArchiveSearch(t *testing.T, params string) *archiveSearch {
+	dir := t.TempDir()
+	writeTestArchive(t, dir, "2026-10-16-1.log.gz",
+		"INFO starting",
+		"ERROR java.lang.OutOfMemoryError: Java heap space",
+		"  at Server.tick(Server.java:42)",
+		"INFO stopping",
+	)
+	writeTestArchive(t, dir, "2026-10-17-1.log.gz", "INFO OutOfMemoryError avoided", "INFO done")
+
+	multiline, _ := MultilineConfig{ContinuationPattern: `^\s`}.compile(nil)
+	level, _ := LevelConfig{Regex: `^(?P<level>[A-Z]+)`}.compile(nil)
+	search := &archiveSearch{server: "serv", urlPrefix: "/logs", parser: &logParser{multiline: multiline, level: level}}
+	assert.NoError(t, search.addArchives(dir, "*.log.gz", ""))
+	values, _ := url.ParseQuery(params)
+	assert.NoError(t, search.parseOptions(values))
+	return search
+}
+
+// runTestSearch returns the hits of the search by archive, and its done message
+func runTestSearch(search *archiveSearch) (map[string][]searchHit, searchMessage) {
+	hits := make(map[string][]searchHit)
+	done := search.run(context.Background(), func(message searchMessage) {
+		if message.Type == searchMessageHit {
+			hits[message.Hit.Archive] = append(hits[message.Hit.Archive], *message.Hit)
+		}
+	})
+	return hits, done
+}
+
+func TestArchiveSearch(t *testing.T) {
+	search := newTestArchiveSearch(t, "q=OutOfMemoryError&context=1")
+	hits, done := runTestSearch(search)
+	assert.Equal(t, searchMessage{Type: searchMessageDone, Archives: 2, Hits: 2}, done)
+	if assert.Len(t, hits["2026-10-16-1.log.gz"], 1) {
+		hit := hits["2026-10-16-1.log.gz"][0]
+		assert.Equal(t, 2, hit.Line)
+		assert.Equal(t, []string{"INFO starting"}, hit.Before)
+		assert.Equal(t, []string{"ERROR java.lang.OutOfMemoryError: Java heap space", "  at Server.tick(Server.java:42)"}, hit.Lines, "The whole event should be a hit")
+		assert.Equal(t, []string{"INFO stopping"}, hit.After)
+		assert.True(t, strings.HasPrefix(hit.Url, "/logs/archive/serv/"))
+		assert.True(t, strings.HasSuffix(hit.Url, "?line=2"))
+	}
+	if assert.Len(t, hits["2026-10-17-1.log.gz"], 1) {
+		assert.Empty(t, hits["2026-10-17-1.log.gz"][0].Before)
+		assert.Equal(t, []string{"INFO done"}, hits["2026-10-17-1.log.gz"][0].After)
+	}
+
+	hits, done = runTestSearch(newTestArchiveSearch(t, "q=level>=error OutOfMemoryError"))
+	assert.Equal(t, 1, done.Hits, "The search should support the query language")
+	assert.Len(t, hits["2026-10-16-1.log.gz"], 1)
+
+	hits, done = runTestSearch(newTestArchiveSearch(t, "q=OutOfMemoryError&from=2026-10-17"))
+	assert.Equal(t, 1, done.Archives, "The archives out of the dates should not be searched")
+	assert.Len(t, hits["2026-10-17-1.log.gz"], 1)
+	_, done = runTestSearch(newTestArchiveSearch(t, "q=OutOfMemoryError&to=2026-10-15"))
+	assert.Equal(t, 0, done.Archives)
+
+	_, done = runTestSearch(newTestArchiveSearch(t, "q=INFO&limit=2"))
+	assert.Equal(t, 2, done.Hits)
+	assert.True(t, done.Truncated, "The search should stop at the hits limit")
+}
+
+func TestArchiveSearchOptions(t *testing.T) {
+	for _, params := range []string{"", "q=", "q=(error", "q=error&from=yesterday", "q=error&context=100", "q=error&limit=0"} {
+		values, _ := url.ParseQuery(params)
+		assert.Error(t, (&archiveSearch{}).parseOptions(values), params)
+	}
+}
+
+func TestSearchForm(t *testing.T) {
+	config := newTestArchivesConfig(t)
+	root := filepath.Dir(filepath.Dir(filepath.Dir(config.Servers.Dynamic[0].ArchivedLogsRootDir)))
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "servers/empty/logs"), 0755))
+	catalogs.mutex.Lock()
+	catalogsCount := len(catalogs.catalogs)
+	catalogs.mutex.Unlock()
+
+	data, found, err := searchFormOf(config, "dyn")
+	if assert.True(t, found) && assert.NoError(t, err) {
+		assert.Equal(t, []string{"a", "b"}, data.Instances, "The instances without archives should not be searched")
+		assert.Equal(t, 2, data.ArchivesCount)
+		assert.Equal(t, "Instance <D>", data.ServerDisplayName)
+	}
+	catalogs.mutex.Lock()
+	assert.Equal(t, catalogsCount, len(catalogs.catalogs), "The search form should not make the catalogs of the instances")
+	catalogs.mutex.Unlock()
+
+	data, found, err = searchFormOf(config, "serv")
+	if assert.True(t, found) && assert.NoError(t, err) {
+		assert.Nil(t, data.Instances)
+		assert.Equal(t, 1, data.ArchivesCount)
+	}
+	_, found, _ = searchFormOf(config, "unknown")
+	assert.False(t, found)
+}
+
+func TestArchiveUrl(t *testing.T) {
+	search := &archiveSearch{server: "dyn", urlPrefix: "/logs", isDynamic: true}
+	assert.Equal(t, "/logs/dyn-archive/dyn/a%20b%2Fc/id?line=3", search.archiveUrl(searchedArchive{instance: "a b/c", id: "id"}, 3),
+		"The instance should be escaped in the url")
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// resumableEventsCount is the number of events kept per server, that can be sent again to a resuming stream
+const resumableEventsCount = 1024
+
+// defaultTailLinesCount is the number of lines sent by the tail endpoint when none is specified, like `tail` does
+const defaultTailLinesCount = 10
+
+// eventHistory is a ring buffer holding the latest events of a server
+type eventHistory struct {
+	events []Event
+	next   int
+	full   bool
+	lastID uint64
+}
+
+func newEventHistory(size int) *eventHistory {
+	return &eventHistory{events: make([]Event, size)}
+}
+
+// add gives the next ID to the given event, stores it and returns the ID
+func (history *eventHistory) add(evt Event) uint64 {
+	history.lastID++
+	evt.ID = history.lastID
+	history.events[history.next] = evt
+	history.next = (history.next + 1) % len(history.events)
+	if history.next == 0 {
+		history.full = true
+	}
+	return evt.ID
+}
+
+// since returns the stored events emitted after the event with the given ID, from the oldest to the newest
+func (history *eventHistory) since(lastEventID uint64) []Event {
+	var ordered []Event
+	if history.full {
+		ordered = append(ordered, history.events[history.next:]...)
+	}
+	ordered = append(ordered, history.events[:history.next]...)
+
+	for i, evt := range ordered {
+		if evt.ID > lastEventID {
+			return ordered[i:]
+		}
+	}
+	return nil
+}
+
+// holdsLatestFile returns whether the history still holds every event since the latest reset, or since the first event
+// if there is none, in which case the lines preceding its latest add events are only in the log file
+func (history *eventHistory) holdsLatestFile() bool {
+	if !history.full {
+		return true
+	}
+	for _, evt := range history.events {
+		if evt.Type == eventReset {
+			return true
+		}
+	}
+	return false
+}
+
+// watchOffsets holds the offset in the log file of every server from which its lines are sent to the hub: the size of the
+// file when it started to be watched, or 0 once it has been truncated. The lines preceding it are only in the log file.
+// It can be safely used by several goroutines.
+type watchOffsets struct {
+	mutex   sync.Mutex
+	offsets map[string]int64
+}
+
+// historyOffsets holds the offsets of the log files of the servers, by server name with the format used by the websocket
+var historyOffsets = &watchOffsets{offsets: make(map[string]int64)}
+
+func (offsets *watchOffsets) set(servName string, offset int64) {
+	offsets.mutex.Lock()
+	defer offsets.mutex.Unlock()
+	offsets.offsets[servName] = offset
+}
+
+func (offsets *watchOffsets) get(servName string) (int64, bool) {
+	offsets.mutex.Lock()
+	defer offsets.mutex.Unlock()
+	offset, found := offsets.offsets[servName]
+	return offset, found
+}
+
+// latest returns at most count of the latest add events matching the given filter, from the oldest to the newest.
+// The events preceding the latest reset are not returned, as they are the lines of the previous log file.
+func (history *eventHistory) latest(count int, filter *logQuery) []Event {
+	events := history.since(0)
+	start := len(events)
+	for i := len(events) - 1; i >= 0 && count > 0; i-- {
+		if events[i].Type == eventReset {
+			break
+		}
+		if events[i].Type != eventAdd || (filter != nil && !filter.matches(events[i].record())) {
+			continue
+		}
+		start = i
+		count--
+	}
+	var latest []Event
+	for _, evt := range events[start:] {
+		if evt.Type == eventAdd && (filter == nil || filter.matches(evt.record())) {
+			latest = append(latest, evt)
+		}
+	}
+	return latest
+}
+
+// serveStream subscribes the request to the given server and writes the events with the given format
+// until the connection is closed. It returns false if the server is unknown, before writing anything.
+// The latest add events of the server, at most latestEvents of them, are written first when no event is resumed.
+// If the history has fewer of them, the lines returned by earlier for the missing ones are written before them, when it is not nil.
+// Only the log lines matching the filter are written, all of them if it is nil.
+func (hub *Hub) serveStream(w http.ResponseWriter, r *http.Request, server string, format messageFormat, lastEventID uint64, latestEvents int,
+	filter *logQuery, earlier func(missing int) []string) bool {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return true
+	}
+
+	client := &Client{
+		hub:    hub,
+		send:   make(chan []byte, 256),
+		format: format,
+		viewer: hub.getViewerName(r),
+	}
+	hub.register <- client
+	defer func() {
+		hub.unregister <- client
+	}()
+
+	found := make(chan bool, 1)
+	sub := subscription{client: client, server: server, lastEventID: lastEventID, latestEvents: latestEvents, found: found, filter: filter}
+	var latestSent chan int
+	if earlier != nil && lastEventID == 0 && latestEvents > 0 {
+		latestSent = make(chan int, 1)
+		sub.latestSent = latestSent
+	}
+	hub.subscribe <- sub
+	if !<-found {
+		return false
+	}
+
+	if format == formatSSE {
+		w.Header().Set("Content-Type", "text/event-stream")
+	} else {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // disables nginx buffering
+	w.WriteHeader(http.StatusOK)
+	if latestSent != nil {
+		// the events sent by the hub are only written below, after the lines preceding them
+		if sent := <-latestSent; sent >= 0 && sent < latestEvents {
+			for _, line := range earlier(latestEvents - sent) {
+				if _, err := w.Write([]byte(line + "\n")); err != nil {
+					return true
+				}
+			}
+		}
+	}
+	flusher.Flush()
+
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return true
+		case message, ok := <-client.send:
+			if !ok {
+				// The hub closed the channel.
+				if format == formatSSE && client.closeReason != "" {
+					_, _ = w.Write(Event{Type: eventError, Message: client.closeReason}.encode(formatSSE))
+				}
+				return true
+			}
+			if _, err := w.Write(message); err != nil {
+				return true
+			}
+			flusher.Flush()
+		case <-ticker.C:
+			if format == formatSSE {
+				// comment line, keeps the connection alive through proxies
+				if _, err := w.Write([]byte(": ping\n\n")); err != nil {
+					return true
+				}
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+// streamHandler serves the events of a server as Server-Sent Events, on /stream/{server}
+func streamHandler(w http.ResponseWriter, r *http.Request, hub *Hub) {
+	server := strings.TrimPrefix(r.URL.Path, "/stream/")
+
+	lastEventIDStr := r.Header.Get("Last-Event-ID")
+	if lastEventIDStr == "" {
+		lastEventIDStr = r.URL.Query().Get("lastEventId")
+	}
+	lastEventID, _ := strconv.ParseUint(lastEventIDStr, 10, 64)
+
+	query, err := extractQuery(r)
+	if err != nil {
+		http.Error(w, "Invalid query: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !hub.serveStream(w, r, server, formatSSE, lastEventID, 0, query, nil) {
+		http.Error(w, "Unknown server: "+server, http.StatusNotFound)
+	}
+}
+
+// tailHandler serves the latest lines of a server as plain text on /tail/{server},
+// and then the next ones as they are written if the follow query param is set
+func tailHandler(w http.ResponseWriter, r *http.Request, hub *Hub, config Config) {
+	server := strings.TrimPrefix(r.URL.Path, "/tail/")
+	logFilePath, parser, found := getLogFilePathOf(config, server)
+	if !found {
+		http.Error(w, "Unknown server: "+server, http.StatusNotFound)
+		return
+	}
+
+	query, err := extractQuery(r)
+	if err != nil {
+		http.Error(w, "Invalid query: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	linesCount := defaultTailLinesCount
+	if n, err := strconv.Atoi(r.URL.Query().Get("n")); err == nil && n >= 0 {
+		linesCount = n
+	}
+
+	if follow := r.URL.Query().Get("follow"); follow != "" && follow != "0" && follow != "false" {
+		// the latest lines are taken from the events of the hub rather than from the file, so that a line written
+		// while the client subscribes is neither missed nor sent twice. The lines the hub has not received, like the
+		// ones written before the viewer started, are taken from the file before the offset it is watched from.
+		earlier := func(missing int) []string {
+			offset, found := historyOffsets.get(server)
+			if !found {
+				return nil
+			}
+			lines, err := getLogsBefore(logFilePath, offset)
+			if err != nil {
+				printError(err)
+				return nil
+			}
+			lines = parser.filterLines(lines, getLogFileReferenceTime(logFilePath), query)
+			if len(lines) > missing {
+				lines = lines[len(lines)-missing:]
+			}
+			for i, line := range lines {
+				lines[i] = parser.highlighter.text(line)
+			}
+			return lines
+		}
+		if !hub.serveStream(w, r, server, formatPlain, 0, linesCount, query, earlier) {
+			http.Error(w, "Unknown server: "+server, http.StatusNotFound)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if linesCount == 0 {
+		return
+	}
+	lines := getServerLogs(logFilePath, linesCount)
+	// the file content ends with a new line, which results in an empty last line
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) > linesCount {
+		lines = lines[len(lines)-linesCount:]
+	}
+	lines = parser.filterLines(lines, getLogFileReferenceTime(logFilePath), query)
+	for _, line := range lines {
+		_, _ = w.Write([]byte(parser.highlighter.text(line) + "\n"))
+	}
+}
+
+// getLogFilePathOf returns the path of the log file of the given server and its parser,
+// the server having the format used by the websocket (server or server=>instance)
+func getLogFilePathOf(config Config, server string) (logFilePath string, parser *logParser, found bool) {
+	if serverTag, serverId, isDynamic := parseWSServer(server); isDynamic {
+		var servCfg DynamicServerConfig
+		servCfg, logFilePath, found = getDynamicServerConfigAndLogsPath(config.Servers.Dynamic, serverTag, serverId)
+		return logFilePath, servCfg.parser, found
+	}
+	for _, servCfg := range config.Servers.Classic {
+		if servCfg.ServerTag == server {
+			return servCfg.getLogFilePath(), servCfg.parser, true
+		}
+	}
+	return "", nil, false
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventHistory(t *testing.T) {
+	history := newEventHistory(4)
+	assert.Nil(t, history.since(0), "Empty history should not return any event")
+
+	for i := 1; i <= 6; i++ {
+		id := history.add(Event{Type: eventAdd, Content: string(rune('a' + i - 1))})
+		assert.Equal(t, uint64(i), id, "Bad event ID")
+	}
+
+	// only the 4 latest events are kept
+	events := history.since(0)
+	if assert.Len(t, events, 4) {
+		assert.Equal(t, uint64(3), events[0].ID)
+		assert.Equal(t, "c", events[0].Content)
+		assert.Equal(t, uint64(6), events[3].ID)
+		assert.Equal(t, "f", events[3].Content)
+	}
+
+	events = history.since(4)
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, uint64(5), events[0].ID)
+		assert.Equal(t, uint64(6), events[1].ID)
+	}
+
+	assert.Nil(t, history.since(6), "No event should have been emitted after the latest one")
+}
+
+func TestEventEncoding(t *testing.T) {
+	evt := Event{ID: 42, Type: eventAdd, Server: "test", Content: "first\nsecond"}
+	assert.Equal(t, "id: 42\nevent: add\ndata: first\ndata: second\n\n", string(evt.encode(formatSSE)))
+	assert.Equal(t, "first\nsecond\n", string(evt.encode(formatPlain)))
+
+	reset := Event{ID: 43, Type: eventReset, Server: "test"}
+	assert.Equal(t, "id: 43\nevent: reset\ndata: \n\n", string(reset.encode(formatSSE)))
+	assert.Nil(t, reset.encode(formatPlain), "Reset events should not be printed in plain format")
+}
+
+func TestEventHistoryLatest(t *testing.T) {
+	history := newEventHistory(8)
+	assert.Nil(t, history.latest(3, nil), "Empty history should not return any event")
+
+	history.add(Event{Type: eventAdd, Content: "previous file"})
+	history.add(Event{Type: eventReset})
+	for _, content := range []string{"a", "b", "c", "d"} {
+		history.add(Event{Type: eventAdd, Content: content, Level: levelInfo})
+	}
+	history.add(Event{Type: eventLag, Delayed: 1})
+	history.add(Event{Type: eventAdd, Content: "e", Level: levelWarn})
+
+	contents := func(events []Event) (contents []string) {
+		for _, evt := range events {
+			contents = append(contents, evt.Content)
+		}
+		return contents
+	}
+	assert.Equal(t, []string{"c", "d", "e"}, contents(history.latest(3, nil)))
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, contents(history.latest(10, nil)),
+		"The events preceding the reset should not be returned")
+	assert.Nil(t, history.latest(0, nil))
+
+	filter, _ := parseQuery("level>=warn")
+	assert.Equal(t, []string{"e"}, contents(history.latest(3, filter)), "Only the events matching the filter should be counted")
+}
+
+func TestEventHistoryHoldsLatestFile(t *testing.T) {
+	history := newEventHistory(3)
+	history.add(Event{Type: eventAdd, Content: "a"})
+	assert.True(t, history.holdsLatestFile())
+	history.add(Event{Type: eventReset})
+	history.add(Event{Type: eventAdd, Content: "b"})
+	history.add(Event{Type: eventAdd, Content: "c"})
+	assert.True(t, history.holdsLatestFile(), "The events since the reset should all be held")
+	history.add(Event{Type: eventAdd, Content: "d"})
+	assert.False(t, history.holdsLatestFile(), "The oldest events of the file should have been lost")
+}
+
+func TestTailFollow(t *testing.T) {
+	logFilePath := filepath.Join(t.TempDir(), "latest.log")
+	assert.NoError(t, os.WriteFile(logFilePath, []byte("a\nb\nc\nd\n"), 0644))
+	// the viewer started to watch the file after c, and its hub has only received d since
+	historyOffsets.set("tail-serv", int64(len("a\nb\nc\n")))
+	var config Config
+	config.Servers.Classic = []ClassicServerConfig{{ServerConfig: ServerConfig{ServerTag: "tail-serv", parser: &logParser{}}, LogFilePath: logFilePath}}
+
+	eventChan := make(chan Event)
+	hub := newHub(policyDisconnect)
+	go hub.run(eventChan)
+	hub.addServer("tail-serv")
+	eventChan <- Event{Type: eventAdd, Server: "tail-serv", Content: "d"}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tailHandler(w, r, hub, config)
+	}))
+	defer server.Close()
+
+	runWithTimeout(t, func() {
+		res, err := http.Get(server.URL + "/tail/tail-serv?follow=1&n=3")
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer func() {
+			_ = res.Body.Close()
+		}()
+		reader := bufio.NewReader(res.Body)
+		readLines := func(count int) (lines []string) {
+			for i := 0; i < count; i++ {
+				line, err := reader.ReadString('\n')
+				if !assert.NoError(t, err) {
+					return lines
+				}
+				lines = append(lines, line)
+			}
+			return lines
+		}
+		assert.Equal(t, []string{"b\n", "c\n", "d\n"}, readLines(3),
+			"The lines missing from the history should be taken from the file, without the ones of the history")
+		eventChan <- Event{Type: eventAdd, Server: "tail-serv", Content: "e"}
+		assert.Equal(t, []string{"e\n"}, readLines(1))
+	})
+}
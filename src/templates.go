@@ -18,6 +18,21 @@ var serverHtml string
 //go:embed resources/archive.tmpl
 var archiveHtml string
 
+//go:embed resources/search.tmpl
+var searchHtml string
+
+//go:embed resources/calendar.tmpl
+var calendarHtml string
+
+//go:embed resources/timeline.tmpl
+var timelineHtml string
+
+//go:embed resources/instances.tmpl
+var instancesHtml string
+
+//go:embed resources/archives.tmpl
+var archivesHtml string
+
 //go:embed resources/navbar.tmpl
 var navbarHtml string
 
@@ -51,6 +66,16 @@ func parseTemplates(funcMap template.FuncMap, templateNames ...string) (finalTmp
 			templatePtr = &archiveLoaderHtml
 		case "archive":
 			templatePtr = &archiveHtml
+		case "search":
+			templatePtr = &searchHtml
+		case "calendar":
+			templatePtr = &calendarHtml
+		case "timeline":
+			templatePtr = &timelineHtml
+		case "instances":
+			templatePtr = &instancesHtml
+		case "archives":
+			templatePtr = &archivesHtml
 		case "common-scripts":
 			templatePtr = &commonScriptsJs
 		default:
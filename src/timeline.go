@@ -0,0 +1,435 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTimelineLimit = 200
+	maxTimelineLimit     = 5000
+)
+
+var errNoTimestamps = errors.New("the logs of this server have no timestamps")
+
+// timelineSource is a file of the timeline: an archive, or the live log file which comes last
+type timelineSource struct {
+	Name string `json:"name"`
+	// The url of the file in the viewer
+	Url  string `json:"url"`
+	Live bool   `json:"live,omitempty"`
+	// The offset of the first line of the file in the timeline, from 0
+	FirstLine int `json:"first-line"`
+	Lines     int `json:"lines"`
+	// Whether the archive is being read in the background, in which case its lines are not part of the timeline yet
+	Pending bool `json:"pending,omitempty"`
+
+	location  archiveLocation
+	reference time.Time
+	// The time of the last event of an archive, zero if it is not known
+	lastTime time.Time
+}
+
+// serverTimeline holds the archives of a server ordered by date and its live log file, read as one continuous stream of lines
+type serverTimeline struct {
+	displayName string
+	parser      *logParser
+	sources     []timelineSource
+	// The number of lines of all the sources
+	totalLines int
+	// The number of archives whose lines are not part of the timeline yet
+	pending int
+}
+
+// timelineLine is a line of the timeline
+type timelineLine struct {
+	// The offset of the line in the timeline, from 0
+	Line    int           `json:"line"`
+	Content string        `json:"content"`
+	Html    template.HTML `json:"html"`
+	// The index of the source of the line in the sources of the page
+	Source int `json:"source"`
+}
+
+// timelinePage is a page of consecutive lines of the timeline
+type timelinePage struct {
+	Lines []timelineLine `json:"lines"`
+	// The sources of the lines, whose first lines mark where the files change
+	Sources    []timelineSource `json:"sources"`
+	TotalLines int              `json:"total-lines"`
+	// The number of archives being read in the background, whose lines are not part of the timeline yet
+	Pending int `json:"pending,omitempty"`
+	// The offsets to request the previous page (backward) and the next one (forward) with,
+	// nil at the start and at the end of the timeline
+	Previous *int `json:"previous"`
+	Next     *int `json:"next"`
+}
+
+// newServerTimeline returns the timeline of the given server, or of one of its instances if it is a dynamic server.
+// The archives which have not been read yet are left out of it until their lines are counted in the background.
+func newServerTimeline(config Config, server, instance string) (timeline *serverTimeline, found bool, err error) {
+	logFile, found, err := getServerLogFile(config, server, instance)
+	if !found || err != nil {
+		return nil, found, err
+	}
+	timeline = &serverTimeline{displayName: logFile.displayName, parser: logFile.parser}
+
+	serverArchives, hasArchives, err := getServerArchives(config, server, instance)
+	switch {
+	case errors.Is(err, errArchivesDisabled) || (err == nil && !hasArchives):
+		// the timeline is only made of the live log file
+	case err != nil:
+		return nil, true, err
+	default:
+		if err = timeline.addArchives(serverArchives.catalog, serverArchives.url); err != nil {
+			return nil, true, err
+		}
+	}
+
+	if err = timeline.addLiveFile(logFile.path, logFile.url); err != nil {
+		return nil, true, err
+	}
+	return timeline, true, nil
+}
+
+// addArchives adds the archives of the given catalog to the timeline, ordered by the day of their names
+// (or their modification time if they have none) and then by part
+func (timeline *serverTimeline) addArchives(catalog *archiveCatalog, archivesUrl string) error {
+	entries, err := catalog.list()
+	if err != nil {
+		return err
+	}
+	type datedEntry struct {
+		archiveEntry
+		date time.Time
+	}
+	dated := make([]datedEntry, 0, len(entries))
+	for _, entry := range entries {
+		date, found := timeline.parser.archiveDay(entry.Name)
+		if !found {
+			date = entry.modTime
+		}
+		dated = append(dated, datedEntry{entry, date})
+	}
+	sort.SliceStable(dated, func(i, j int) bool {
+		if !dated[i].date.Equal(dated[j].date) {
+			return dated[i].date.Before(dated[j].date)
+		}
+		// the parts are numbered in the names, like 2026-10-17-2.log.gz before 2026-10-17-10.log.gz
+		if len(dated[i].Name) != len(dated[j].Name) {
+			return len(dated[i].Name) < len(dated[j].Name)
+		}
+		return dated[i].Name < dated[j].Name
+	})
+
+	for _, entry := range dated {
+		location := catalog.locationOf(entry.archiveEntry)
+		source := timelineSource{
+			Name:      entry.Name,
+			Url:       archivesUrl + entry.Id,
+			FirstLine: timeline.totalLines,
+			location:  location,
+			reference: timeline.parser.archiveReferenceTime(location),
+		}
+		// the lines of the archives are counted by the catalog, which reads them in the background once they are listed
+		if details, known := catalog.knownDetailsOf(entry.archiveEntry); known {
+			source.Lines, source.lastTime = details.lines, details.lastTime
+		} else {
+			source.Pending = true
+			timeline.pending++
+		}
+		timeline.sources = append(timeline.sources, source)
+		timeline.totalLines += source.Lines
+	}
+	return nil
+}
+
+// addLiveFile adds the live log file at the given path to the end of the timeline
+func (timeline *serverTimeline) addLiveFile(path, url string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	source := timelineSource{
+		Name:      filepath.Base(path),
+		Url:       url,
+		Live:      true,
+		FirstLine: timeline.totalLines,
+		Lines:     len(splitLogLines(content)),
+		location:  archiveLocation{path: path},
+		reference: getLogFileReferenceTime(path),
+	}
+	timeline.sources = append(timeline.sources, source)
+	timeline.totalLines += source.Lines
+	return nil
+}
+
+// readLines returns the lines of the given source
+func (timeline *serverTimeline) readLines(source timelineSource) ([]string, error) {
+	var content []byte
+	var err error
+	if source.Live {
+		content, err = os.ReadFile(source.location.path)
+	} else {
+		content, err = uncompress(source.location)
+	}
+	if err != nil {
+		return nil, err
+	}
+	lines := splitLogLines(content)
+	// the live file may have been written since it was counted, and the lines added after are not part of the timeline
+	if len(lines) > source.Lines {
+		lines = lines[:source.Lines]
+	}
+	return lines, nil
+}
+
+// seek returns the offset of the first line of the timeline written at the given time or after it,
+// or the end of the timeline if there is none
+func (timeline *serverTimeline) seek(at time.Time) (int, error) {
+	if !timeline.parser.hasTimestamps() {
+		return 0, errNoTimestamps
+	}
+	for _, source := range timeline.sources {
+		if source.Pending || (!source.lastTime.IsZero() && source.lastTime.Before(at)) {
+			continue
+		}
+		lines, err := timeline.readLines(source)
+		if err != nil {
+			return 0, err
+		}
+		for i, line := range lines {
+			if lineTime := timeline.parser.extractTime(line, source.reference); !lineTime.IsZero() && !lineTime.Before(at) {
+				return source.FirstLine + i, nil
+			}
+		}
+	}
+	return timeline.totalLines, nil
+}
+
+// page returns the lines following the given offset (forward) or preceding it (backward), at most limit of them
+func (timeline *serverTimeline) page(offset, limit int, forward bool) (timelinePage, error) {
+	start, end := offset, offset+limit
+	if !forward {
+		start, end = offset-limit, offset
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end > timeline.totalLines {
+		end = timeline.totalLines
+	}
+
+	page := timelinePage{Lines: []timelineLine{}, Sources: []timelineSource{}, TotalLines: timeline.totalLines, Pending: timeline.pending}
+	for _, source := range timeline.sources {
+		if source.Lines == 0 || source.FirstLine+source.Lines <= start || source.FirstLine >= end {
+			continue
+		}
+		lines, err := timeline.readLines(source)
+		if err != nil {
+			return page, fmt.Errorf("failed to read %s: %w", source.Name, err)
+		}
+		page.Sources = append(page.Sources, source)
+		first := start - source.FirstLine
+		if first < 0 {
+			first = 0
+		}
+		for i := first; i < end-source.FirstLine && i < len(lines); i++ {
+			page.Lines = append(page.Lines, timelineLine{
+				Line:    source.FirstLine + i,
+				Content: lines[i],
+				Html:    timeline.parser.highlight(lines[i]),
+				Source:  len(page.Sources) - 1,
+			})
+		}
+	}
+
+	if start > 0 {
+		page.Previous = &start
+	}
+	if end < timeline.totalLines {
+		page.Next = &end
+	}
+	return page, nil
+}
+
+// timelineQuery selects a page of a timeline
+type timelineQuery struct {
+	// The time of the first line, zero if the page starts at the line offset
+	from time.Time
+	// The offset of the line the page starts at, or ends before if it goes backward, -1 for the end of the timeline
+	line    int
+	forward bool
+	limit   int
+}
+
+// parseTimelineQuery reads the query from the given params: from (a time like 2026-10-17T22:00 or -2h) or line (an offset),
+// direction (forward or backward) and limit. Without from nor line, the query is about the last lines of the timeline.
+func parseTimelineQuery(params url.Values, now time.Time) (query timelineQuery, err error) {
+	query.line = -1
+	if from := params.Get("from"); from != "" {
+		if query.from, err = parseQueryTime(from, now); err != nil {
+			return query, fmt.Errorf("from %w", err)
+		}
+	} else if line := params.Get("line"); line != "" {
+		if query.line, err = parseBoundedInt(line, 0, 0, math.MaxInt32); err != nil {
+			return query, fmt.Errorf("line %w", err)
+		}
+	}
+	switch direction := params.Get("direction"); direction {
+	case "":
+		query.forward = query.line != -1 || !query.from.IsZero()
+	case "forward", "backward":
+		query.forward = direction == "forward"
+	default:
+		return query, fmt.Errorf("direction must be forward or backward, not %q", direction)
+	}
+	if query.limit, err = parseBoundedInt(params.Get("limit"), defaultTimelineLimit, 1, maxTimelineLimit); err != nil {
+		return query, fmt.Errorf("limit %w", err)
+	}
+	return query, nil
+}
+
+// pageOf returns the page of the timeline selected by the given query
+func (timeline *serverTimeline) pageOf(query timelineQuery) (timelinePage, error) {
+	offset := query.line
+	if !query.from.IsZero() {
+		var err error
+		if offset, err = timeline.seek(query.from); err != nil {
+			return timelinePage{}, err
+		}
+	} else if offset == -1 {
+		offset = timeline.totalLines
+	}
+	return timeline.page(offset, query.limit, query.forward)
+}
+
+// apiTimelineHandler serves a page of the timeline of a server on /api/timeline/{server}, which are its archives ordered by date
+// followed by its live log file, see parseTimelineQuery for the params. The instance query param is required for a dynamic server.
+func apiTimelineHandler(w http.ResponseWriter, r *http.Request, config Config) {
+	server := strings.TrimPrefix(r.URL.Path, "/api/timeline/")
+	query, err := parseTimelineQuery(r.URL.Query(), time.Now())
+	if err != nil {
+		prettier(w, "Invalid request: "+err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	timeline, found, err := newServerTimeline(config, server, r.URL.Query().Get("instance"))
+	if !found {
+		prettier(w, "Unknown server: "+server, nil, http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, errInstanceRequired) {
+		prettier(w, "Invalid request: "+err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		printError(err)
+		prettier(w, "Failed to read the logs of server "+server, nil, http.StatusInternalServerError)
+		return
+	}
+
+	page, err := timeline.pageOf(query)
+	if errors.Is(err, errNoTimestamps) {
+		prettier(w, "Invalid request: "+err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		printError(err)
+		prettier(w, "Failed to read the logs of server "+server, nil, http.StatusInternalServerError)
+		return
+	}
+	prettier(w, "Timeline of server "+server, page, http.StatusOK)
+}
+
+// timelineRow is a row of the timeline page: a line, preceded by the source it comes from where the files change
+type timelineRow struct {
+	Source *timelineSource
+	Line   timelineLine
+}
+
+// TimelineWebData contains the data of the timeline page
+type TimelineWebData struct {
+	Server            string
+	Instance          string
+	ServerDisplayName string
+	From              string
+	Rows              []timelineRow
+	TotalLines        int
+	// The number of archives being read in the background, which are not part of the timeline yet
+	Pending int
+	// The offsets of the previous and next pages, nil at the start and at the end of the timeline
+	Previous, Next *int
+	// The reason why the lines could not be shown, empty if they are
+	Error string
+}
+
+// timelineHandler serves the page of the timeline of a server on /timeline/{server}, which reads its archives and its live
+// log file as one stream, with markers where the files change. It takes the params of apiTimelineHandler.
+func timelineHandler(w http.ResponseWriter, r *http.Request, templateCommonData CommonWebData, config Config) {
+	server := strings.TrimPrefix(r.URL.Path, "/timeline/")
+	instance := r.URL.Query().Get("instance")
+	timeline, found, err := newServerTimeline(config, server, instance)
+	if !found || errors.Is(err, errInstanceRequired) {
+		http.Redirect(w, r, config.UrlPrefix+"/", http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		handleTemplateError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	data := TimelineWebData{
+		Server:            server,
+		Instance:          instance,
+		ServerDisplayName: timeline.displayName,
+		From:              r.URL.Query().Get("from"),
+		TotalLines:        timeline.totalLines,
+		Pending:           timeline.pending,
+	}
+	query, err := parseTimelineQuery(r.URL.Query(), time.Now())
+	var page timelinePage
+	if err == nil {
+		page, err = timeline.pageOf(query)
+	}
+	if err != nil {
+		data.Error = err.Error()
+	}
+	data.Previous, data.Next = page.Previous, page.Next
+	for i, line := range page.Lines {
+		row := timelineRow{Line: line}
+		if i == 0 || line.Source != page.Lines[i-1].Source {
+			row.Source = &page.Sources[line.Source]
+		}
+		data.Rows = append(data.Rows, row)
+	}
+
+	// the page has the navbar of the index, as it is not about the logs of a single server
+	tmpl, err := parseTemplates(getFuncMapFor("", true, false, false), "timeline", "navbar")
+	if err != nil {
+		handleTemplateError(w, http.StatusInternalServerError, err)
+		return
+	}
+	templateCommonData.ExecDate = time.Now().Format("15:04:05")
+	err = tmpl.Execute(w, struct {
+		CommonWebData
+		TimelineWebData
+	}{
+		CommonWebData:   templateCommonData,
+		TimelineWebData: data,
+	})
+	if doDebug {
+		if err != nil {
+			printError(err)
+		}
+	}
+}
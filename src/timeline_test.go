@@ -0,0 +1,132 @@
+package main
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestTimeline returns the timeline of the archives of newTestCatalog followed by a live file of two lines
+func newTestTimeline(t *testing.T) *serverTimeline {
+	catalog, dir := newTestCatalog(t)
+	livePath := filepath.Join(dir, "latest.log")
+	assert.NoError(t, os.WriteFile(livePath, []byte("[12:00:00] live\n[12:00:01] still live\n"), 0644))
+	waitForDetails(t, catalog)
+	timeline := &serverTimeline{parser: catalog.parser}
+	assert.NoError(t, timeline.addArchives(catalog, "/archive/serv/"))
+	assert.NoError(t, timeline.addLiveFile(livePath, "/server/serv"))
+	return timeline
+}
+
+func timelineContents(page timelinePage) []string {
+	contents := make([]string, 0, len(page.Lines))
+	for _, line := range page.Lines {
+		contents = append(contents, line.Content)
+	}
+	return contents
+}
+
+func TestTimelineSources(t *testing.T) {
+	timeline := newTestTimeline(t)
+	assert.Equal(t, 8, timeline.totalLines)
+	var names []string
+	var firstLines []int
+	for _, source := range timeline.sources {
+		names = append(names, source.Name)
+		firstLines = append(firstLines, source.FirstLine)
+	}
+	assert.Equal(t, []string{"2026-10-15-1.log.gz", "2026-10-16-1.log.gz", "2026-10-17-1.log.gz", "latest.log"}, names,
+		"The archives should be ordered by date and followed by the live file")
+	assert.Equal(t, []int{0, 2, 5, 6}, firstLines)
+	assert.True(t, timeline.sources[3].Live)
+}
+
+func TestTimelinePendingArchives(t *testing.T) {
+	catalog, _ := newTestCatalog(t)
+	// the details are never read in the background, like while a large history is being read
+	catalog.reading = true
+	timeline := &serverTimeline{parser: catalog.parser}
+	assert.NoError(t, timeline.addArchives(catalog, "/archive/serv/"))
+	assert.Equal(t, 3, timeline.pending)
+	assert.Equal(t, 0, timeline.totalLines, "The archives not read yet should not be counted")
+	for _, source := range timeline.sources {
+		assert.True(t, source.Pending, source.Name)
+	}
+
+	page, err := timeline.page(0, 10, false)
+	if assert.NoError(t, err) {
+		assert.Empty(t, page.Lines)
+		assert.Equal(t, 3, page.Pending)
+	}
+	offset, err := timeline.seek(time.Date(2026, 10, 16, 10, 30, 0, 0, time.Local))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, offset, "The pending archives should be skipped when seeking")
+}
+
+func TestTimelinePages(t *testing.T) {
+	timeline := newTestTimeline(t)
+	page, err := timeline.page(4, 3, true)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"[11:00:00] stop", "no time", "[12:00:00] live"}, timelineContents(page), "A page should go across the files")
+		assert.Len(t, page.Sources, 3)
+		assert.Equal(t, []int{0, 1, 2}, []int{page.Lines[0].Source, page.Lines[1].Source, page.Lines[2].Source})
+		assert.Equal(t, 4, *page.Previous)
+		assert.Equal(t, 7, *page.Next)
+	}
+
+	page, err = timeline.page(2, 5, false)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"[08:00:00] start", "[09:30:00] stop"}, timelineContents(page))
+		assert.Nil(t, page.Previous, "The first page has no previous one")
+		assert.Equal(t, 2, *page.Next)
+	}
+
+	page, err = timeline.pageOf(timelineQuery{line: -1, limit: 1})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"[12:00:01] still live"}, timelineContents(page), "The last lines should be returned by default")
+		assert.Nil(t, page.Next)
+	}
+}
+
+func TestTimelineSeek(t *testing.T) {
+	timeline := newTestTimeline(t)
+	for at, expected := range map[time.Time]int{
+		time.Date(2026, 10, 14, 0, 0, 0, 0, time.Local):   0,
+		time.Date(2026, 10, 16, 10, 30, 0, 0, time.Local): 4,
+		time.Date(2026, 10, 17, 0, 0, 0, 0, time.Local):   6,
+		time.Now().AddDate(1, 0, 0):                       8,
+	} {
+		offset, err := timeline.seek(at)
+		assert.NoError(t, err, at)
+		assert.Equal(t, expected, offset, at)
+	}
+
+	timeline.parser = &logParser{}
+	_, err := timeline.seek(time.Now())
+	assert.ErrorIs(t, err, errNoTimestamps)
+}
+
+func TestParseTimelineQuery(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.Local)
+	for params, expected := range map[string]timelineQuery{
+		"":                                   {line: -1, limit: defaultTimelineLimit},
+		"line=10":                            {line: 10, forward: true, limit: defaultTimelineLimit},
+		"line=10&direction=backward&limit=5": {line: 10, limit: 5},
+		"from=-1h":                           {from: now.Add(-time.Hour), line: -1, forward: true, limit: defaultTimelineLimit},
+	} {
+		values, _ := url.ParseQuery(params)
+		query, err := parseTimelineQuery(values, now)
+		if assert.NoError(t, err, params) {
+			assert.Equal(t, expected, query, params)
+		}
+	}
+	for _, params := range []string{"line=-1", "from=yesterday", "direction=up", "limit=0"} {
+		values, _ := url.ParseQuery(params)
+		_, err := parseTimelineQuery(values, now)
+		assert.Error(t, err, params)
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// timestampGroupName is the name of the regexp group extracting the timestamp of a log line
+const timestampGroupName = "timestamp"
+
+// timestampTolerance is how far after the reference time a timestamp can be before being considered of the previous day (or year),
+// which absorbs the clock differences between the logging process and LogRenderer
+const timestampTolerance = time.Hour
+
+// defaultArchiveDateFormat is the layout of the date found in most archived log filenames, like 2026-10-17-1.log.gz
+const defaultArchiveDateFormat = "2006-01-02"
+
+// layoutElements are the elements of the Go time layouts, with the regexps matching their values,
+// the longest ones first so that they are found before the shorter ones they start with
+var layoutElements = []struct{ element, pattern string }{
+	{"January", `[A-Za-z]+`},
+	{"Monday", `[A-Za-z]+`},
+	{"-07:00", `[+-]\d{2}:\d{2}`},
+	{"-0700", `[+-]\d{4}`},
+	{"2006", `\d{4}`},
+	{"Jan", `[A-Za-z]{3}`},
+	{"Mon", `[A-Za-z]{3}`},
+	{"MST", `[A-Z]{3,4}`},
+	{"002", `\d{3}`},
+	{"_2", `[ \d]\d`},
+	{"01", `\d{2}`},
+	{"02", `\d{2}`},
+	{"03", `\d{2}`},
+	{"04", `\d{2}`},
+	{"05", `\d{2}`},
+	{"06", `\d{2}`},
+	{"15", `\d{2}`},
+	{"PM", `[AP]M`},
+	{"pm", `[ap]m`},
+	{"1", `\d{1,2}`},
+	{"2", `\d{1,2}`},
+	{"3", `\d{1,2}`},
+	{"4", `\d{1,2}`},
+	{"5", `\d{1,2}`},
+}
+
+// archiveDateRule finds the day of an archive in its filename
+type archiveDateRule struct {
+	layout string
+	// The regexp matching the values of the layout
+	regexp *regexp.Regexp
+}
+
+// defaultArchiveDateRule finds the dates like 2026-10-17 in the filenames of the archives
+var defaultArchiveDateRule, _ = compileArchiveDateFormat(defaultArchiveDateFormat)
+
+// compileArchiveDateFormat returns the rule finding the dates of the given Go layout in the filenames of the archives,
+// like 20060102 for access.log-20261017.gz. The layout must have a year, a month and a day.
+func compileArchiveDateFormat(layout string) (*archiveDateRule, error) {
+	day := time.Date(2026, time.October, 17, 0, 0, 0, 0, time.Local)
+	if parsed, err := time.ParseInLocation(layout, day.Format(layout), time.Local); err != nil || parsed.YearDay() != day.YearDay() || parsed.Year() != day.Year() {
+		return nil, errors.New("the layout must have a year, a month and a day, like 2006-01-02")
+	}
+
+	var pattern strings.Builder
+	for rest := layout; rest != ""; {
+		found := false
+		for _, element := range layoutElements {
+			if strings.HasPrefix(rest, element.element) {
+				pattern.WriteString(element.pattern)
+				rest = rest[len(element.element):]
+				found = true
+				break
+			}
+		}
+		if !found {
+			r, size := utf8.DecodeRuneInString(rest)
+			pattern.WriteString(regexp.QuoteMeta(string(r)))
+			rest = rest[size:]
+		}
+	}
+	re, err := regexp.Compile(pattern.String())
+	if err != nil {
+		return nil, err
+	}
+	return &archiveDateRule{layout: layout, regexp: re}, nil
+}
+
+// dayOf returns the start of the day found in the given filename, or false if it has none
+func (rule *archiveDateRule) dayOf(filename string) (time.Time, bool) {
+	for _, value := range rule.regexp.FindAllString(filename, -1) {
+		if date, err := time.ParseInLocation(rule.layout, value, time.Local); err == nil {
+			return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.Local), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// TimestampConfig represents the rule extracting the time of the log lines of a server
+type TimestampConfig struct {
+	// The regexp extracting the timestamp, in a group named 'timestamp' or in the whole match
+	Regex string `yaml:"regex"`
+	// The Go layout of the timestamp, e.g. 15:04:05 or 02/Jan/2006:15:04:05 -0700.
+	// When it has no date (or no year), it is taken from the archive filename or from the log file modification time
+	Layout string `yaml:"layout"`
+	// The IANA timezone of the timestamps without offset, e.g. Europe/Paris, the local one by default
+	Timezone string `yaml:"timezone"`
+}
+
+// timestampRule is a compiled TimestampConfig
+type timestampRule struct {
+	regexp *regexp.Regexp
+	// The index of the group of the timestamp, 0 for the whole match
+	group    int
+	layout   string
+	location *time.Location
+	// Whether the layout has a year and a day, or only a time
+	hasYear, hasDate bool
+	// The highlighter of the server, whose formatting codes are removed before matching the lines
+	highlighter *lineHighlighter
+}
+
+// compile returns the rule of the config, or nil if timestamps must not be extracted
+func (timestampCfg TimestampConfig) compile(highlighter *lineHighlighter) (*timestampRule, error) {
+	if timestampCfg.Regex == "" && timestampCfg.Layout == "" {
+		return nil, nil
+	}
+	if timestampCfg.Regex == "" || timestampCfg.Layout == "" {
+		return nil, errors.New("both regex and layout must be set")
+	}
+	re, err := regexp.Compile(timestampCfg.Regex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex: %w", err)
+	}
+	rule := &timestampRule{
+		regexp:      re,
+		group:       re.SubexpIndex(timestampGroupName),
+		layout:      timestampCfg.Layout,
+		location:    time.Local,
+		highlighter: highlighter,
+	}
+	if rule.group < 0 {
+		rule.group = 0
+	}
+	if timestampCfg.Timezone != "" {
+		rule.location, err = time.LoadLocation(timestampCfg.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone: %w", err)
+		}
+	}
+
+	// the parts of the layout are found by parsing a formatted time back
+	sample := time.Date(1999, time.November, 28, 13, 14, 15, 0, time.UTC)
+	formatted := sample.Format(rule.layout)
+	if formatted == rule.layout {
+		return nil, fmt.Errorf("invalid layout %q: it must be written with the Go reference time, e.g. 2006-01-02 15:04:05", rule.layout)
+	}
+	parsed, err := time.Parse(rule.layout, formatted)
+	if err != nil {
+		return nil, fmt.Errorf("invalid layout %q: %w", rule.layout, err)
+	}
+	rule.hasYear = parsed.Year() == sample.Year()
+	rule.hasDate = parsed.Month() == sample.Month() && parsed.Day() == sample.Day()
+	return rule, nil
+}
+
+// extract returns the time of the given line, or a zero time if it has none.
+// The reference is the time the line was written at the latest, which gives the missing day or year of the timestamp.
+func (rule *timestampRule) extract(line string, reference time.Time) time.Time {
+	if rule == nil {
+		return time.Time{}
+	}
+	matches := rule.regexp.FindStringSubmatch(rule.highlighter.stripFormatting(line))
+	if matches == nil {
+		return time.Time{}
+	}
+	timestamp, err := time.ParseInLocation(rule.layout, strings.TrimSpace(matches[rule.group]), rule.location)
+	if err != nil {
+		return time.Time{}
+	}
+	if rule.hasYear {
+		return timestamp
+	}
+
+	reference = reference.In(rule.location)
+	latest := reference.Add(timestampTolerance)
+	hour, min, sec := timestamp.Clock()
+	if !rule.hasDate {
+		timestamp = time.Date(reference.Year(), reference.Month(), reference.Day(), hour, min, sec, timestamp.Nanosecond(), rule.location)
+		if timestamp.After(latest) {
+			timestamp = timestamp.AddDate(0, 0, -1)
+		}
+		return timestamp
+	}
+	timestamp = time.Date(reference.Year(), timestamp.Month(), timestamp.Day(), hour, min, sec, timestamp.Nanosecond(), rule.location)
+	if timestamp.After(latest) {
+		timestamp = timestamp.AddDate(-1, 0, 0)
+	}
+	return timestamp
+}
+
+// getLogFileReferenceTime returns the time the given log file was last written, or now if it cannot be known
+func getLogFileReferenceTime(filePath string) time.Time {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return time.Now()
+	}
+	return info.ModTime()
+}
+
+// archiveDay returns the day of the given archive found in its filename, with the date format of the server
+// or the default one, or false if it has none
+func (parser *logParser) archiveDay(filePath string) (time.Time, bool) {
+	rule := defaultArchiveDateRule
+	if parser != nil && parser.archiveDates != nil {
+		rule = parser.archiveDates
+	}
+	return rule.dayOf(filepath.Base(filePath))
+}
+
+// archiveReferenceTime returns the end of the day found in the filename of the given archive,
+// or its modification time if it has none, which is the one of its bundle if it is a member of one
+func (parser *logParser) archiveReferenceTime(location archiveLocation) time.Time {
+	if day, found := parser.archiveDay(location.name()); found {
+		return day.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	return getLogFileReferenceTime(location.path)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimestampExtraction(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skip("no timezone database:", err)
+	}
+	reference := time.Date(2026, time.October, 17, 12, 30, 0, 0, paris)
+
+	timeOnly, err := TimestampConfig{Regex: `^\[(?P<timestamp>\d{2}:\d{2}:\d{2})]`, Layout: "15:04:05", Timezone: "Europe/Paris"}.compile(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2026, time.October, 17, 12, 0, 0, 0, paris), timeOnly.extract("[12:00:00] [Server thread/INFO]: Done", reference))
+	assert.Equal(t, time.Date(2026, time.October, 17, 13, 0, 0, 0, paris), timeOnly.extract("[13:00:00] late clock", reference), "A slightly late clock should not change the day")
+	assert.Equal(t, time.Date(2026, time.October, 16, 23, 59, 0, 0, paris), timeOnly.extract("[23:59:00] before midnight", reference), "Times after the reference are from the day before")
+	assert.True(t, timeOnly.extract("no time", reference).IsZero())
+
+	syslog, err := TimestampConfig{Regex: `^\w{3} [ \d]\d \d{2}:\d{2}:\d{2}`, Layout: "Jan _2 15:04:05", Timezone: "Europe/Paris"}.compile(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2026, time.October, 17, 8, 0, 0, 0, paris), syslog.extract("Oct 17 08:00:00 host sshd[42]: Accepted", reference))
+	assert.Equal(t, time.Date(2025, time.December, 31, 23, 0, 0, 0, paris), syslog.extract("Dec 31 23:00:00 host cron: job", reference), "Dates after the reference are from the year before")
+
+	nginx, err := TimestampConfig{Regex: `\[(?P<timestamp>[^\]]+)]`, Layout: "02/Jan/2006:15:04:05 -0700"}.compile(nil)
+	assert.NoError(t, err)
+	assert.True(t, time.Date(2026, time.October, 17, 10, 0, 0, 0, time.UTC).Equal(
+		nginx.extract(`127.0.0.1 - - [17/Oct/2026:12:00:00 +0200] "GET / HTTP/1.1" 200`, reference)), "The offset of the timestamp should be used")
+
+	var noRule *timestampRule
+	assert.True(t, noRule.extract("[12:00:00] Done", reference).IsZero())
+}
+
+func TestTimestampConfig(t *testing.T) {
+	rule, err := TimestampConfig{}.compile(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, rule)
+
+	_, err = TimestampConfig{Regex: `\d+`}.compile(nil)
+	assert.Error(t, err, "A regex without layout is useless")
+	_, err = TimestampConfig{Regex: `\d+`, Layout: "HH:mm:ss"}.compile(nil)
+	assert.Error(t, err, "The layout must use the Go reference time")
+	_, err = TimestampConfig{Regex: `\d+`, Layout: "15:04:05", Timezone: "Nowhere/Somewhere"}.compile(nil)
+	assert.Error(t, err)
+}
+
+func TestEventTimesAcrossMidnight(t *testing.T) {
+	rule, _ := TimestampConfig{Regex: `^\[(?P<timestamp>[\d:]+)]`, Layout: "15:04:05", Timezone: "UTC"}.compile(nil)
+	parser := &logParser{timestamp: rule}
+	reference := time.Date(2026, time.October, 18, 0, 10, 0, 0, time.UTC)
+
+	rows := parser.renderLogs([]string{"[23:58:00] first", "no time", "[23:59:30] second", "[00:01:00] third", ""}, reference, nil)
+	assert.Len(t, rows, 4)
+	assert.Equal(t, time.Date(2026, time.October, 17, 23, 58, 0, 0, time.UTC), rows[0].Time)
+	assert.True(t, rows[1].Time.IsZero())
+	assert.Equal(t, time.Date(2026, time.October, 17, 23, 59, 30, 0, time.UTC), rows[2].Time)
+	assert.Equal(t, time.Date(2026, time.October, 18, 0, 1, 0, 0, time.UTC), rows[3].Time)
+}
+
+func TestArchiveReferenceTime(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "2026-10-17-1.log.gz")
+	assert.NoError(t, os.WriteFile(archive, nil, 0644))
+	reference := (*logParser)(nil).archiveReferenceTime(archiveLocation{path: archive})
+	assert.Equal(t, "2026-10-17 23:59:59", reference.Format("2006-01-02 15:04:05"), "The date of the filename should be used")
+
+	undated := filepath.Join(dir, "old.log")
+	assert.NoError(t, os.WriteFile(undated, nil, 0644))
+	modTime := time.Date(2026, time.October, 1, 8, 0, 0, 0, time.Local)
+	assert.NoError(t, os.Chtimes(undated, modTime, modTime))
+	assert.True(t, modTime.Equal((*logParser)(nil).archiveReferenceTime(archiveLocation{path: undated})), "The modification time should be used without date in the filename")
+}
+
+func TestArchiveDateFormat(t *testing.T) {
+	day := time.Date(2026, time.October, 17, 0, 0, 0, 0, time.Local)
+	for layout, filenames := range map[string][]string{
+		"2006-01-02": {"2026-10-17-3.log.gz", "latest-2026-13-01-2026-10-17.log"},
+		"20060102":   {"access.log-20261017.gz"},
+		"02Jan2006":  {"app.17Oct2026.log"},
+		"2006/1/_2":  {"2026/10/17"},
+	} {
+		rule, err := compileArchiveDateFormat(layout)
+		if !assert.NoError(t, err, layout) {
+			continue
+		}
+		for _, filename := range filenames {
+			found, ok := rule.dayOf(filename)
+			assert.True(t, ok, filename)
+			assert.True(t, day.Equal(found), "%s: expected %s, got %s", filename, day, found)
+		}
+	}
+
+	rule, _ := compileArchiveDateFormat("20060102")
+	_, ok := rule.dayOf("syslog.2.gz")
+	assert.False(t, ok, "A filename without date should have no day")
+
+	for _, invalid := range []string{"", "15:04", "2006-01", "01-02"} {
+		_, err := compileArchiveDateFormat(invalid)
+		assert.Error(t, err, invalid)
+	}
+
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "access.log-20261017.gz")
+	assert.NoError(t, os.WriteFile(archive, nil, 0644))
+	parser := &logParser{archiveDates: rule}
+	assert.Equal(t, "2026-10-17 23:59:59", parser.archiveReferenceTime(archiveLocation{path: archive}).Format("2006-01-02 15:04:05"))
+}
@@ -9,7 +9,15 @@ import (
 
 const sendInterval = 5 * time.Millisecond
 
-func unstack(server string, logQueue *fifo.Queue, output chan Event) {
+// unstack sends the lines of the log queue of the server to the output channel, as events made by the given parser
+func unstack(server string, parser *logParser, logQueue *fifo.Queue, output chan Event) {
+	grouper := newLineGrouper(parser)
+	send := func(lines []string) {
+		startTime := time.Now()
+		metrics.countLines(server, "", lines)
+		output <- parser.newEvent(server, lines)
+		time.Sleep(sendInterval - time.Since(startTime))
+	}
 	for {
 		startTime := time.Now()
 		if logQueue.Len() > 0 {
@@ -19,61 +27,86 @@ func unstack(server string, logQueue *fifo.Queue, output chan Event) {
 				newLogs := strings.Trim(event.content, "\n")
 				if len(newLogs) > 0 {
 					for _, log := range strings.Split(newLogs, "\n") {
-						startTime = time.Now()
-						output <- Event{
-							Type:    eventAdd,
-							Server:  server,
-							Content: log,
+						if lines := grouper.add(log); lines != nil {
+							send(lines)
 						}
-						// fmt.Println("Event:", event)
-						sleepDuration := sendInterval - time.Since(startTime)
-						time.Sleep(sleepDuration)
 					}
 				}
 				continue
 			case eventReset:
+				if lines := grouper.flush(); lines != nil {
+					send(lines)
+				}
 				output <- Event{
 					Type:   eventReset,
 					Server: server,
 				}
 			}
 		}
+		if lines := grouper.flushIfExpired(); lines != nil {
+			send(lines)
+		}
 		time.Sleep(sendInterval - time.Since(startTime)) // yeeessssss
 	}
 }
 
-func unstackDynamic(server, instance string, logQueue *fifo.Queue, output chan Event, stop *bool) {
-	for !*stop {
+// unstackDynamic works like unstack for an instance of a dynamic server, until the stop channel is closed
+func unstackDynamic(server, instance string, parser *logParser, logQueue *fifo.Queue, output chan Event, stop <-chan struct{}) {
+	grouper := newLineGrouper(parser)
+	send := func(lines []string) {
 		startTime := time.Now()
-		if logQueue.Len() > 0 {
-			event := logQueue.Next().(fileEvent)
-			switch event.eventType {
-			case eventAdd:
-				newLogs := strings.Trim(event.content, "\n")
-				if len(newLogs) > 0 {
-					for _, log := range strings.Split(newLogs, "\n") {
-						startTime = time.Now()
-						output <- Event{
-							Type:      eventAdd,
-							Server:    server,
-							isDynamic: true,
-							instance:  instance,
-							Content:   log,
-						}
-						// fmt.Println("Event:", event)
-						sleepDuration := sendInterval - time.Since(startTime)
-						time.Sleep(sleepDuration)
+		metrics.countLines(server, instance, lines)
+		event := parser.newEvent(server, lines)
+		event.isDynamic = true
+		event.instance = instance
+		output <- event
+		time.Sleep(sendInterval - time.Since(startTime))
+	}
+	// handle sends the lines of the given event, and returns whether it added lines
+	handle := func(event fileEvent) bool {
+		switch event.eventType {
+		case eventAdd:
+			newLogs := strings.Trim(event.content, "\n")
+			if len(newLogs) > 0 {
+				for _, log := range strings.Split(newLogs, "\n") {
+					if lines := grouper.add(log); lines != nil {
+						send(lines)
 					}
 				}
-				continue
-			case eventReset:
-				output <- Event{
-					Type:      eventReset,
-					Server:    server,
-					isDynamic: true,
-					instance:  instance,
-				}
 			}
+			return true
+		case eventReset:
+			if lines := grouper.flush(); lines != nil {
+				send(lines)
+			}
+			output <- Event{
+				Type:      eventReset,
+				Server:    server,
+				isDynamic: true,
+				instance:  instance,
+			}
+		}
+		return false
+	}
+	for {
+		select {
+		case <-stop:
+			// the last lines read by the watcher are sent, including the event still waiting for its next lines
+			for logQueue.Len() > 0 {
+				handle(logQueue.Next().(fileEvent))
+			}
+			if lines := grouper.flush(); lines != nil {
+				send(lines)
+			}
+			return
+		default:
+		}
+		startTime := time.Now()
+		if logQueue.Len() > 0 && handle(logQueue.Next().(fileEvent)) {
+			continue
+		}
+		if lines := grouper.flushIfExpired(); lines != nil {
+			send(lines)
 		}
 		time.Sleep(sendInterval - time.Since(startTime))
 	}
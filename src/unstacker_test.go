@@ -18,10 +18,11 @@ func TestUnstackers(t *testing.T) {
 	logQueue := fifo.NewQueue()
 	dynamicLogQueue := fifo.NewQueue()
 	outputChannel := make(chan Event, 16)
-	stop := false
+	stop := make(chan struct{})
+	defer close(stop)
 
-	go unstack("test", logQueue, outputChannel)
-	go unstackDynamic("test", "t", dynamicLogQueue, outputChannel, &stop)
+	go unstack("test", nil, logQueue, outputChannel)
+	go unstackDynamic("test", "t", nil, dynamicLogQueue, outputChannel, stop)
 
 	doneChannel := make(chan struct{})
 
@@ -85,3 +86,31 @@ func TestUnstackers(t *testing.T) {
 		t.Fatalf("Test duration has reached the threshold (%s)", durationThreshold)
 	}
 }
+
+func TestUnstackDynamicStop(t *testing.T) {
+	multiline, err := MultilineConfig{ContinuationPattern: `^\s`, FlushTimeout: "1h"}.compile(nil)
+	assert.NoError(t, err)
+	logQueue := fifo.NewQueue()
+	outputChannel := make(chan Event, 16)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		unstackDynamic("test", "t", &logParser{multiline: multiline}, logQueue, outputChannel, stop)
+		close(done)
+	}()
+
+	logQueue.Add(fileEvent{eventType: eventAdd, content: "Exception\n  at Main.main\n"})
+	time.Sleep(50 * time.Millisecond) // the event waits for its next lines
+	assert.Empty(t, outputChannel, "The event should wait for its next lines")
+	close(stop)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The unstacker should return once stopped")
+	}
+	if assert.Len(t, outputChannel, 1, "The pending event should be sent when the unstacker stops") {
+		evt := <-outputChannel
+		assert.Equal(t, "Exception\n  at Main.main", evt.Content)
+		assert.Equal(t, "t", evt.instance)
+	}
+}
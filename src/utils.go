@@ -1,16 +1,15 @@
 package main
 
 import (
-	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"net/http"
-	"net/url"
 	"os"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -112,6 +111,47 @@ func extractMaxLinesCount(r *http.Request) int {
 	return maxLines
 }
 
+// extractQuery returns the query of the q param of the request, or nil if there is none
+func extractQuery(r *http.Request) (*logQuery, error) {
+	return parseQuery(r.URL.Query().Get("q"))
+}
+
+// byteSizeUnits are the units of the sizes of the configuration, by suffix
+var byteSizeUnits = []struct {
+	suffix     string
+	multiplier int64
+}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}}
+
+// parseByteSize parses a size like 500MB or 2GB, the units being powers of 1024, and returns 0 if it is empty
+func parseByteSize(size string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(size))
+	if value == "" {
+		return 0, nil
+	}
+	multiplier := int64(1)
+	for _, unit := range byteSizeUnits {
+		if strings.HasSuffix(value, unit.suffix) {
+			value, multiplier = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix)), unit.multiplier
+			break
+		}
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q: must be like 500MB or 2GB", size)
+	}
+	return n * multiplier, nil
+}
+
+// formatByteSize formats a size in bytes with the largest unit of byteSizeUnits it holds, like 1.5 MB
+func formatByteSize(size int64) string {
+	for _, unit := range byteSizeUnits {
+		if size >= unit.multiplier && unit.multiplier > 1 {
+			return strconv.FormatFloat(float64(size)/float64(unit.multiplier), 'f', 1, 64) + " " + unit.suffix
+		}
+	}
+	return strconv.FormatInt(size, 10) + " B"
+}
+
 func findAllGroups(re *regexp.Regexp, str string) map[string]string {
 	results := make(map[string]string)
 	matches := re.FindStringSubmatch(str)
@@ -125,22 +165,3 @@ func findAllGroups(re *regexp.Regexp, str string) map[string]string {
 	}
 	return results
 }
-
-// filePathEscape encodes the given file path into base64, and then makes it url-friendly
-func filePathEscape(filePath string) string {
-	return url.QueryEscape(base64.StdEncoding.EncodeToString([]byte(filePath)))
-}
-
-func filePathUnescape(filePath string) string {
-	unescaped, err := url.QueryUnescape(filePath)
-	if err != nil {
-		log.Println("Failed to unescape string:", err)
-		return ""
-	}
-	decoded, err := base64.StdEncoding.DecodeString(unescaped)
-	if err != nil {
-		log.Println("Failed to decode base64:", err)
-		return ""
-	}
-	return string(decoded)
-}
@@ -106,20 +106,21 @@ func expectFindAllGroups(t *testing.T, re *regexp.Regexp, str string, expected m
 	assert.Equal(t, expected, result)
 }
 
-func TestFilePathEscape(t *testing.T) {
-	assert.Equal(t, "", filePathEscape(""))
-	assert.Equal(t, "Lw%3D%3D", filePathEscape("/"))
-	assert.Equal(t,
-		"L3BhdGgvdG8vRHluYW1pY1NlcnZlcnMvUGFwZXJfKi9sb2dzL2xhdGVzdC5sb2c%3D",
-		filePathEscape("/path/to/DynamicServers/Paper_*/logs/latest.log"),
-	)
+func TestParseByteSize(t *testing.T) {
+	for value, expected := range map[string]int64{"": 0, "512": 512, "10B": 10, "2kb": 2048, "500MB": 500 << 20, " 2 GB ": 2 << 30} {
+		size, err := parseByteSize(value)
+		if assert.NoError(t, err, value) {
+			assert.Equal(t, expected, size, value)
+		}
+	}
+	for _, value := range []string{"MB", "-1KB", "1.5GB", "10TB"} {
+		_, err := parseByteSize(value)
+		assert.Error(t, err, value)
+	}
 }
 
-func TestFilePathUnescape(t *testing.T) {
-	assert.Equal(t, "", filePathUnescape(""))
-	assert.Equal(t, "/", filePathUnescape("Lw%3D%3D"))
-	assert.Equal(t,
-		"/path/to/DynamicServers/Paper_*/logs/latest.log",
-		filePathUnescape("L3BhdGgvdG8vRHluYW1pY1NlcnZlcnMvUGFwZXJfKi9sb2dzL2xhdGVzdC5sb2c%3D"),
-	)
+func TestFormatByteSize(t *testing.T) {
+	for size, expected := range map[int64]string{0: "0 B", 1023: "1023 B", 1024: "1.0 KB", 1536 << 10: "1.5 MB", 3 << 30: "3.0 GB", 2048 << 30: "2048.0 GB"} {
+		assert.Equal(t, expected, formatByteSize(size), size)
+	}
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"io"
 	"log"
 	"os"
@@ -17,16 +18,55 @@ func watchServ(logQueue *fifo.Queue, properties watchProperties) {
 
 	shouldRewatch := true
 
+	// the error that made the watcher stop, if any
+	var watchErr error
+	// fail records the error that makes the watcher stop, which is then reported by the health of the server
+	fail := func(operation string, err error) {
+		watchErr = fmt.Errorf("%s: %w", operation, err)
+		log.Println(prefix(properties.servName, true) + watchErr.Error())
+		shouldRewatch = false
+	}
+	defer func() {
+		if watchErr != nil {
+			health.setSourceState(properties.servName, stateErrored, watchErr)
+		} else {
+			health.setSourceState(properties.servName, stateStopped, nil)
+		}
+	}()
+
+	// the log files of the classic servers may be created later, like by a server which has not started yet
+	if properties.shouldRewatchOnFileRemove {
+		if err := waitForFile(properties); err != nil {
+			fail("check file", err)
+			return
+		}
+	}
 	stat, err := os.Stat(properties.logFilePath)
 	if err != nil {
-		log.Fatal(prefix(properties.servName, true), "stat: ", err)
+		fail("stat", err)
+		return
 	}
 	filePos := stat.Size()
-
-	for shouldRewatch {
+	// the lines written before are only read from the file, by the tails
+	historyOffsets.set(properties.servName, filePos)
+
+	metrics.trackQueue(properties.servName, logQueue)
+	defer metrics.untrackQueue(properties.servName)
+	labels := serverLabels(properties.servName)
+
+	for firstWatch := true; shouldRewatch; firstWatch = false {
+		if !firstWatch {
+			metrics.add(metricRewatches, labels, 1)
+			if err := waitForFile(properties); err != nil {
+				fail("check file", err)
+				return
+			}
+		}
+		health.setSourceState(properties.servName, stateWatching, nil)
 		watcher, err := fsnotify.NewWatcher()
 		if err != nil {
-			log.Fatal(prefix(properties.servName, true), err)
+			fail("new watcher", err)
+			return
 		}
 
 		wg := new(sync.WaitGroup)
@@ -34,16 +74,28 @@ func watchServ(logQueue *fifo.Queue, properties watchProperties) {
 
 		go func() {
 			for event := range watcher.Events {
+				health.recordSourceEvent(properties.servName)
 				if event.Op&fsnotify.Write == fsnotify.Write {
 					file, err := os.Open(properties.logFilePath)
 					if err != nil {
-						log.Fatal(prefix(properties.servName, true), "open: ", err)
+						if properties.shouldRewatchOnFileRemove && os.IsNotExist(err) {
+							// the file has been removed since it was written, which is followed by a remove event
+							continue
+						}
+						fail("open", err)
+						wg.Done()
+						return
 					}
 					stat, err := file.Stat()
 					if err != nil {
-						log.Fatal(prefix(properties.servName, true), "stat: ", err)
+						_ = file.Close()
+						fail("stat", err)
+						wg.Done()
+						return
 					}
 					if stat.Size() < filePos {
+						metrics.add(metricRotations, labels, 1)
+						historyOffsets.set(properties.servName, 0)
 						logQueue.Add(fileEvent{eventType: eventReset})
 						filePos = 0
 						_ = file.Close()
@@ -51,17 +103,24 @@ func watchServ(logQueue *fifo.Queue, properties watchProperties) {
 					}
 					filePos, err = file.Seek(filePos, 0)
 					if err != nil {
-						log.Fatal(prefix(properties.servName, true), "seek: ", err)
+						_ = file.Close()
+						fail("seek", err)
+						wg.Done()
+						return
 					}
 					buffer := make([]byte, bufferSize)
 					readLength, err := file.Read(buffer)
 					filePos += int64(readLength)
+					metrics.add(metricBytesRead, labels, float64(readLength))
 					if err != nil {
 						if err == io.EOF {
 							_ = file.Close()
 							continue
 						}
-						log.Fatal(prefix(properties.servName, true), "read: ", err)
+						_ = file.Close()
+						fail("read", err)
+						wg.Done()
+						return
 					}
 
 					if readLength > 0 {
@@ -82,16 +141,8 @@ func watchServ(logQueue *fifo.Queue, properties watchProperties) {
 					return
 				} else if event.Op&fsnotify.Remove == fsnotify.Remove {
 					log.Println(prefix(properties.servName), "Remove")
-					if properties.shouldRewatchOnFileRemove {
-						if err = checkFile(properties.logFilePath); err != nil {
-							printError(err)
-							shouldRewatch = false
-						} else {
-							shouldRewatch = true
-						}
-					} else {
-						shouldRewatch = false
-					}
+					// the file is waited for before watching it again
+					shouldRewatch = properties.shouldRewatchOnFileRemove
 					wg.Done()
 					return
 				} else {
@@ -102,21 +153,55 @@ func watchServ(logQueue *fifo.Queue, properties watchProperties) {
 
 		err = watcher.Add(properties.logFilePath)
 		if err != nil {
-			log.Fatal(prefix(properties.servName, true), "add watcher: ", err)
+			// closing the watcher ends the goroutine reading its events
+			_ = watcher.Close()
+			if properties.shouldRewatchOnFileRemove && os.IsNotExist(err) {
+				// the file has been removed again since it was waited for
+				continue
+			}
+			fail("add watcher", err)
+			return
 		}
 
 		wg.Wait()
 
 		err = watcher.Close()
 		if err != nil {
-			log.Fatal(prefix(properties.servName, true), "close watcher: ", err)
+			fail("close watcher", err)
+			return
 		}
 
 		if shouldRewatch {
+			health.setSourceState(properties.servName, stateWaiting, nil)
 			time.Sleep(properties.delayBeforeRewatch)
 		}
 	}
+}
 
+const (
+	// The delays between two checks of a missing log file, which double every time
+	minDelayBeforeFileCheck = 100 * time.Millisecond
+	maxDelayBeforeFileCheck = time.Minute
+)
+
+// waitForFile waits until the log file of the given watcher exists, while its state is waiting.
+// It returns an error if the file can not be watched, like if it is a directory.
+func waitForFile(properties watchProperties) error {
+	delay := properties.delayBeforeRewatch
+	if delay < minDelayBeforeFileCheck {
+		delay = minDelayBeforeFileCheck
+	}
+	for {
+		_, err := os.Stat(properties.logFilePath)
+		if !os.IsNotExist(err) {
+			return checkFile(properties.logFilePath)
+		}
+		health.setSourceState(properties.servName, stateWaiting, checkFile(properties.logFilePath))
+		time.Sleep(delay)
+		if delay *= 2; delay > maxDelayBeforeFileCheck {
+			delay = maxDelayBeforeFileCheck
+		}
+	}
 }
 
 type watchProperties struct {
@@ -2,9 +2,12 @@ package main
 
 import (
 	"math/rand"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	fifo "github.com/foize/go.fifo"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -45,3 +48,66 @@ func TestWatcherWrite(t *testing.T) {
 		}
 	}
 }
+
+func TestWatcherStop(t *testing.T) {
+	logFilePath := filepath.Join(t.TempDir(), "latest.log")
+	properties := watchProperties{servName: "test-stopped-server", logFilePath: logFilePath}
+
+	// the watcher returns instead of exiting the viewer
+	watchServ(fifo.NewQueue(), properties)
+	_, report := health.report()
+	assert.Equal(t, stateErrored, report.Sources[properties.servName].State, "A missing log file should be reported")
+	assert.NotEmpty(t, report.Sources[properties.servName].Error)
+
+	assert.NoError(t, os.WriteFile(logFilePath, []byte("started\n"), 0644))
+	stopped := make(chan struct{})
+	go func() {
+		watchServ(fifo.NewQueue(), properties)
+		close(stopped)
+	}()
+	assert.Eventually(t, func() bool {
+		_, report := health.report()
+		return report.Sources[properties.servName].State == stateWatching
+	}, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(10 * time.Millisecond) // time for the watcher to set up
+	assert.NoError(t, os.Remove(logFilePath))
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The watcher should stop once its log file is removed")
+	}
+	_, report = health.report()
+	assert.Equal(t, stateStopped, report.Sources[properties.servName].State, "A stopped watcher should still be reported")
+	assert.Empty(t, report.Sources[properties.servName].Error)
+}
+
+func TestWatcherWaitsForFile(t *testing.T) {
+	logFilePath := filepath.Join(t.TempDir(), "latest.log")
+	properties := watchProperties{servName: "test-waiting-server", logFilePath: logFilePath, shouldRewatchOnFileRemove: true}
+	sourceState := func() watcherState {
+		_, report := health.report()
+		return report.Sources[properties.servName].State
+	}
+
+	logQueue := fifo.NewQueue()
+	go watchServ(logQueue, properties)
+	assert.Eventually(t, func() bool { return sourceState() == stateWaiting }, 5*time.Second, 10*time.Millisecond,
+		"A classic server should wait for its missing log file")
+
+	assert.NoError(t, os.WriteFile(logFilePath, nil, 0644))
+	assert.Eventually(t, func() bool { return sourceState() == stateWatching }, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(10 * time.Millisecond) // time for the watcher to set up
+	file, err := os.OpenFile(logFilePath, os.O_WRONLY|os.O_APPEND, 0644)
+	if assert.NoError(t, err) {
+		_, err = file.WriteString("created\n")
+		assert.NoError(t, err)
+		assert.NoError(t, file.Close())
+	}
+	assert.Eventually(t, func() bool { return logQueue.Len() > 0 }, 5*time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, os.Remove(logFilePath))
+	assert.Eventually(t, func() bool { return sourceState() == stateWaiting }, 5*time.Second, 10*time.Millisecond,
+		"A classic server should wait for its removed log file instead of stopping")
+	assert.NoError(t, os.WriteFile(logFilePath, nil, 0644))
+	assert.Eventually(t, func() bool { return sourceState() == stateWatching }, 5*time.Second, 10*time.Millisecond)
+}
@@ -1,10 +1,11 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"html/template"
 	"net/http"
-	"path/filepath"
+	"net/url"
 	"regexp"
 	"strings"
 	"time"
@@ -35,17 +36,27 @@ type CommonWebData struct {
 	/* Archived logs related */
 	AreArchivedLogsAvailable bool
 	NoLogsLoadedYet          bool
-	AvailableLogsArchives    []archiveEntry
 }
 
 // ServerWebData contains data common to every server page
 type ServerWebData struct {
-	Server                    string
-	Instance                  string // always included because the field is sometime used in the server template
-	ServerDisplayName         string
-	SyntaxHighlightingRegexps SyntaxHighlightingConfig
-	LogsStyles                map[string]string
-	ServerLogs                []string
+	Server            string
+	Instance          string // always included because the field is sometime used in the server template
+	ServerDisplayName string
+	// The highlighted log events, which are already HTML-escaped
+	ServerLogs []logRow
+	// The number of events of every level, nil if the server has no level rule
+	LevelCounts []levelCount
+	// Whether the events have times, which enables the time tools
+	HasTimestamps bool
+	// The columns of the table view, nil if the events have no fields
+	Columns []string
+	// The query filtering the events, as written by the user
+	Query string
+	// Why the query cannot be used, if it is invalid
+	QueryError error
+	// The url to download the logs shown from
+	DownloadUrl string
 }
 
 type handlerFunc func(w http.ResponseWriter, r *http.Request)
@@ -60,6 +71,10 @@ func getFuncMapFor(serv string, isIndex, isDynamic, isArchive bool) template.Fun
 		"isIndex":          func() bool { return isIndex },
 		"isDynamic":        func() bool { return isDynamic },
 		"isArchive":        func() bool { return isArchive },
+		"toJson": func(value interface{}) (string, error) {
+			data, err := json.Marshal(value)
+			return string(data), err
+		},
 	}
 }
 
@@ -79,7 +94,7 @@ func createArchiveHandlerFor(urlPrefix string, servCfg ClassicServerConfig, temp
 			listArchivesHandler(w, templateCommonData, servCfg)
 		case 1:
 			// display archived logs
-			archiveHandler(w, r, parts[0], templateCommonData, servCfg)
+			archiveHandler(w, r, parts[0], urlPrefix, templateCommonData, servCfg)
 		default:
 			http.Redirect(w, r, urlPrefix+"/", http.StatusSeeOther)
 		}
@@ -91,21 +106,22 @@ func createDynamicArchiveHandlerFor(urlPrefix string, servCfg DynamicServerConfi
 		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
 		parts = parts[2:] // get rid of the "archive" and server parts
 		switch len(parts) {
+		case 0:
+			// list the instances having archives
+			dynamicArchivesIndexHandler(w, r, urlPrefix, templateCommonData, servCfg)
 		case 1:
 			// list available logs
-			listDynamicArchivesHandler(w, templateCommonData, servCfg, parts[0])
+			listDynamicArchivesHandler(w, r, urlPrefix, templateCommonData, servCfg, parts[0])
 		case 2:
 			// display archived logs
-			dynamicArchiveHandler(w, r, parts[1], templateCommonData, servCfg, parts[0])
+			dynamicArchiveHandler(w, r, parts[1], urlPrefix, templateCommonData, servCfg, parts[0])
 		default:
 			http.Redirect(w, r, urlPrefix+"/", http.StatusSeeOther)
 		}
 	}
 }
 
-func startServer(config Config, hub *Hub, outputChannel chan Event) error {
-	go hub.run(outputChannel)
-
+func startServer(config Config, hub *Hub) error {
 	serverNames := make([]ServerSummary, len(config.Servers.Classic)+len(config.Servers.Dynamic))
 	templateCommonData := CommonWebData{
 		Version:           "V" + version,
@@ -154,8 +170,11 @@ func startServer(config Config, hub *Hub, outputChannel chan Event) error {
 	})
 
 	http.HandleFunc("/archive", func(w http.ResponseWriter, r *http.Request) {
-		// TODO: show the list of servers available for archive browsing
-		http.Redirect(w, r, config.UrlPrefix+"/", http.StatusSeeOther)
+		archivesIndexHandler(w, templateCommonData, config)
+	})
+	// the archive browsers of the servers have their own paths, the other ones lead to the index
+	http.HandleFunc("/archive/", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, config.UrlPrefix+"/archive", http.StatusSeeOther)
 	})
 
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
@@ -168,6 +187,61 @@ func startServer(config Config, hub *Hub, outputChannel chan Event) error {
 
 	http.HandleFunc("/ws", hub.serveWs)
 
+	http.HandleFunc("/stream/", func(w http.ResponseWriter, r *http.Request) {
+		streamHandler(w, r, hub)
+	})
+	http.HandleFunc("/tail/", func(w http.ResponseWriter, r *http.Request) {
+		tailHandler(w, r, hub, config)
+	})
+
+	http.HandleFunc("/api/logs/", func(w http.ResponseWriter, r *http.Request) {
+		apiLogsHandler(w, r, config)
+	})
+
+	http.HandleFunc("/search/", func(w http.ResponseWriter, r *http.Request) {
+		searchHandler(w, r, templateCommonData, config)
+	})
+	http.HandleFunc("/calendar/", func(w http.ResponseWriter, r *http.Request) {
+		calendarHandler(w, r, templateCommonData, config)
+	})
+	http.HandleFunc("/timeline/", func(w http.ResponseWriter, r *http.Request) {
+		timelineHandler(w, r, templateCommonData, config)
+	})
+	http.HandleFunc("/api/search/", func(w http.ResponseWriter, r *http.Request) {
+		apiSearchHandler(w, r, config)
+	})
+	http.HandleFunc("/api/archives/", func(w http.ResponseWriter, r *http.Request) {
+		apiArchivesHandler(w, r, config)
+	})
+	http.HandleFunc("/api/index/", func(w http.ResponseWriter, r *http.Request) {
+		apiIndexHandler(w, r, config)
+	})
+	http.HandleFunc("/api/timeline/", func(w http.ResponseWriter, r *http.Request) {
+		apiTimelineHandler(w, r, config)
+	})
+	http.HandleFunc("/download/", func(w http.ResponseWriter, r *http.Request) {
+		downloadHandler(w, r, config)
+	})
+
+	http.HandleFunc("/admin/viewers", func(w http.ResponseWriter, r *http.Request) {
+		if !config.isAdmin(r) {
+			prettier(w, "Forbidden", nil, http.StatusForbidden)
+			return
+		}
+		viewersHandler(w, hub)
+	})
+
+	http.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		healthzHandler(w)
+	})
+	http.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		readyzHandler(w)
+	})
+
+	http.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		metricsHandler(w, hub)
+	})
+
 	http.HandleFunc("/res/", serveResource)
 
 	fmt.Println("Starting web server on", config.getWebServerAddress(), "...")
@@ -202,32 +276,29 @@ func serverHandler(w http.ResponseWriter, r *http.Request, templateCommonData Co
 		return
 	}
 
-	var availableLogFiles []archiveEntry
-	if servCfg.archivesEnabled {
-		availableLogFiles, err = listArchivedLogFiles(servCfg.getArchivedLogsDirPath(), servCfg.ArchivedLogFilenameFormat)
-		if err != nil {
-			handleTemplateError(w, http.StatusInternalServerError, err)
-			return
-		}
-	}
-
 	maxLines := extractMaxLinesCount(r)
+	query, queryErr := extractQuery(r)
+	logFilePath := servCfg.getLogFilePath()
+	rows := servCfg.parser.renderLogs(getServerLogs(logFilePath, maxLines), getLogFileReferenceTime(logFilePath), query)
 
 	templateCommonData.ExecDate = time.Now().Format("15:04:05")
 	templateCommonData.AreArchivedLogsAvailable = servCfg.archivesEnabled
 	templateCommonData.NoLogsLoadedYet = false
-	templateCommonData.AvailableLogsArchives = availableLogFiles
 	err = tmpl.Execute(w, struct {
 		CommonWebData
 		ServerWebData
 	}{
 		CommonWebData: templateCommonData,
 		ServerWebData: ServerWebData{
-			Server:                    servCfg.ServerTag,
-			ServerDisplayName:         servCfg.DisplayName,
-			SyntaxHighlightingRegexps: servCfg.SyntaxHighlightingRegexps,
-			LogsStyles:                *servCfg.styles,
-			ServerLogs:                getServerLogs(servCfg.getLogFilePath(), maxLines),
+			Server:            servCfg.ServerTag,
+			ServerDisplayName: servCfg.DisplayName,
+			ServerLogs:        rows,
+			LevelCounts:       servCfg.parser.countLevels(rows),
+			HasTimestamps:     servCfg.parser.hasTimestamps(),
+			Columns:           servCfg.parser.columns(rows),
+			Query:             r.URL.Query().Get("q"),
+			QueryError:        queryErr,
+			DownloadUrl:       templateCommonData.UrlPrefix + "/download/" + servCfg.ServerTag,
 		},
 	})
 	if doDebug {
@@ -283,35 +354,29 @@ func dynamicServerHandler(w http.ResponseWriter, r *http.Request, templateCommon
 		return
 	}
 
-	var availableLogFiles []archiveEntry
-	if servCfg.archivesEnabled {
-		logsDir := strings.ReplaceAll(servCfg.getArchivedLogsRootDir(), "%id%", serverId)
-		logsFilePattern := strings.ReplaceAll(servCfg.ArchivedLogsFilePattern, "%id%", serverId)
-		availableLogFiles, err = listArchivedLogFiles(logsDir, logsFilePattern)
-		if err != nil {
-			handleTemplateError(w, http.StatusInternalServerError, err)
-			return
-		}
-	}
-
 	maxLines := extractMaxLinesCount(r)
+	query, queryErr := extractQuery(r)
+	rows := servCfg.parser.renderLogs(getServerLogs(logFilePath, maxLines), getLogFileReferenceTime(logFilePath), query)
 
 	templateCommonData.ExecDate = time.Now().Format("15:04:05")
 	templateCommonData.AreArchivedLogsAvailable = servCfg.archivesEnabled
 	templateCommonData.NoLogsLoadedYet = false
-	templateCommonData.AvailableLogsArchives = availableLogFiles
 	err = tmpl.Execute(w, struct {
 		CommonWebData
 		ServerWebData
 	}{
 		CommonWebData: templateCommonData,
 		ServerWebData: ServerWebData{
-			Server:                    servCfg.ServerTag,
-			Instance:                  serverId,
-			ServerDisplayName:         strings.ReplaceAll(servCfg.DisplayName, "%id%", serverId),
-			SyntaxHighlightingRegexps: servCfg.SyntaxHighlightingRegexps,
-			LogsStyles:                *servCfg.styles,
-			ServerLogs:                getServerLogs(logFilePath, maxLines),
+			Server:            servCfg.ServerTag,
+			Instance:          serverId,
+			ServerDisplayName: strings.ReplaceAll(servCfg.DisplayName, "%id%", serverId),
+			ServerLogs:        rows,
+			LevelCounts:       servCfg.parser.countLevels(rows),
+			HasTimestamps:     servCfg.parser.hasTimestamps(),
+			Columns:           servCfg.parser.columns(rows),
+			Query:             r.URL.Query().Get("q"),
+			QueryError:        queryErr,
+			DownloadUrl:       templateCommonData.UrlPrefix + "/download/" + servCfg.ServerTag + "?instance=" + url.QueryEscape(serverId),
 		},
 	})
 	if doDebug {
@@ -321,36 +386,54 @@ func dynamicServerHandler(w http.ResponseWriter, r *http.Request, templateCommon
 	}
 }
 
-func archiveHandler(w http.ResponseWriter, r *http.Request, logFile string, templateCommonData CommonWebData, servCfg ClassicServerConfig) {
+// archiveHandler shows the archive of a classic server with the given identifier, which must be listed by its catalog
+func archiveHandler(w http.ResponseWriter, r *http.Request, id, urlPrefix string, templateCommonData CommonWebData, servCfg ClassicServerConfig) {
+	if !servCfg.archivesEnabled {
+		http.Redirect(w, r, urlPrefix+"/", http.StatusSeeOther)
+		return
+	}
 	tmpl, err := parseTemplates(getFuncMapFor(servCfg.ServerTag, false, false, true), "archive", "navbar", "archive-loader", "common-scripts")
 	if err != nil {
 		handleTemplateError(w, http.StatusInternalServerError, err)
 		return
 	}
-
-	availableLogs, err := listArchivedLogFiles(servCfg.getArchivedLogsDirPath(), servCfg.ArchivedLogFilenameFormat)
+	catalog := catalogs.get(servCfg.getArchivedLogsDirPath(), servCfg.ArchivedLogFilenameFormat, servCfg.parser)
+	entry, found, err := catalog.find(id)
 	if err != nil {
 		handleTemplateError(w, http.StatusInternalServerError, err)
 		return
 	}
+	if !found {
+		http.Redirect(w, r, urlPrefix+"/archive/"+servCfg.ServerTag+"/", http.StatusSeeOther)
+		return
+	}
 
 	maxLines := extractMaxLinesCount(r)
+	if r.URL.Query().Has("line") {
+		maxLines = 0 // the whole archive is needed to show the linked line
+	}
+	query, queryErr := extractQuery(r)
+	location := catalog.locationOf(entry)
+	rows := servCfg.parser.renderLogs(getArchiveLogs(location, maxLines), servCfg.parser.archiveReferenceTime(location), query)
 
 	templateCommonData.ExecDate = time.Now().Format("15:04:05")
 	templateCommonData.AreArchivedLogsAvailable = true
 	templateCommonData.NoLogsLoadedYet = false
-	templateCommonData.AvailableLogsArchives = availableLogs
 	err = tmpl.Execute(w, struct {
 		CommonWebData
 		ServerWebData
 	}{
 		CommonWebData: templateCommonData,
 		ServerWebData: ServerWebData{
-			Server:                    servCfg.ServerTag,
-			ServerDisplayName:         servCfg.DisplayName,
-			SyntaxHighlightingRegexps: servCfg.SyntaxHighlightingRegexps,
-			LogsStyles:                *servCfg.styles,
-			ServerLogs:                getArchiveLogs(filepath.Join(servCfg.getArchivedLogsDirPath(), filePathUnescape(logFile)), maxLines),
+			Server:            servCfg.ServerTag,
+			ServerDisplayName: servCfg.DisplayName,
+			ServerLogs:        rows,
+			LevelCounts:       servCfg.parser.countLevels(rows),
+			HasTimestamps:     servCfg.parser.hasTimestamps(),
+			Columns:           servCfg.parser.columns(rows),
+			Query:             r.URL.Query().Get("q"),
+			QueryError:        queryErr,
+			DownloadUrl:       templateCommonData.UrlPrefix + "/download/" + servCfg.ServerTag + "/" + entry.Id,
 		},
 	})
 	if doDebug {
@@ -360,39 +443,60 @@ func archiveHandler(w http.ResponseWriter, r *http.Request, logFile string, temp
 	}
 }
 
-func dynamicArchiveHandler(w http.ResponseWriter, r *http.Request, logFile string, templateCommonData CommonWebData, servCfg DynamicServerConfig, serverId string) {
+// dynamicArchiveHandler shows the archive of an instance of a dynamic server with the given identifier,
+// which must be listed by the catalog of the instance
+func dynamicArchiveHandler(w http.ResponseWriter, r *http.Request, id, urlPrefix string, templateCommonData CommonWebData, servCfg DynamicServerConfig, serverId string) {
+	catalog, found, err := getDynamicArchivesCatalog(servCfg, serverId)
+	if !servCfg.archivesEnabled || !found {
+		http.Redirect(w, r, urlPrefix+"/", http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		handleTemplateError(w, http.StatusInternalServerError, err)
+		return
+	}
 	tmpl, err := parseTemplates(getFuncMapFor(servCfg.ServerTag, false, true, true), "archive", "navbar", "archive-loader", "common-scripts")
 	if err != nil {
 		handleTemplateError(w, http.StatusInternalServerError, err)
 		return
 	}
-
-	logsDir := strings.ReplaceAll(servCfg.getArchivedLogsRootDir(), "%id%", serverId)
-	logsFilePattern := strings.ReplaceAll(servCfg.ArchivedLogsFilePattern, "%id%", serverId)
-	availableLogs, err := listArchivedLogFiles(logsDir, logsFilePattern)
+	entry, found, err := catalog.find(id)
 	if err != nil {
 		handleTemplateError(w, http.StatusInternalServerError, err)
 		return
 	}
+	if !found {
+		http.Redirect(w, r, urlPrefix+"/dyn-archive/"+servCfg.ServerTag+"/"+url.PathEscape(serverId)+"/", http.StatusSeeOther)
+		return
+	}
 
 	maxLines := extractMaxLinesCount(r)
+	if r.URL.Query().Has("line") {
+		maxLines = 0 // the whole archive is needed to show the linked line
+	}
+	query, queryErr := extractQuery(r)
+	location := catalog.locationOf(entry)
+	rows := servCfg.parser.renderLogs(getArchiveLogs(location, maxLines), servCfg.parser.archiveReferenceTime(location), query)
 
 	templateCommonData.ExecDate = time.Now().Format("15:04:05")
 	templateCommonData.AreArchivedLogsAvailable = true
 	templateCommonData.NoLogsLoadedYet = false
-	templateCommonData.AvailableLogsArchives = availableLogs
 	err = tmpl.Execute(w, struct {
 		CommonWebData
 		ServerWebData
 	}{
 		CommonWebData: templateCommonData,
 		ServerWebData: ServerWebData{
-			Server:                    servCfg.ServerTag,
-			Instance:                  serverId,
-			ServerDisplayName:         servCfg.DisplayName,
-			SyntaxHighlightingRegexps: servCfg.SyntaxHighlightingRegexps,
-			LogsStyles:                *servCfg.styles,
-			ServerLogs:                getArchiveLogs(filepath.Join(logsDir, filePathUnescape(logFile)), maxLines),
+			Server:            servCfg.ServerTag,
+			Instance:          serverId,
+			ServerDisplayName: servCfg.DisplayName,
+			ServerLogs:        rows,
+			LevelCounts:       servCfg.parser.countLevels(rows),
+			HasTimestamps:     servCfg.parser.hasTimestamps(),
+			Columns:           servCfg.parser.columns(rows),
+			Query:             r.URL.Query().Get("q"),
+			QueryError:        queryErr,
+			DownloadUrl:       templateCommonData.UrlPrefix + "/download/" + servCfg.ServerTag + "/" + entry.Id + "?instance=" + url.QueryEscape(serverId),
 		},
 	})
 	if doDebug {
@@ -409,26 +513,17 @@ func listArchivesHandler(w http.ResponseWriter, templateCommonData CommonWebData
 		return
 	}
 
-	availableLogs, err := listArchivedLogFiles(servCfg.getArchivedLogsDirPath(), servCfg.ArchivedLogFilenameFormat)
-	if err != nil {
-		handleTemplateError(w, http.StatusInternalServerError, err)
-		return
-	}
-
 	templateCommonData.ExecDate = time.Now().Format("15:04:05")
 	templateCommonData.AreArchivedLogsAvailable = true
 	templateCommonData.NoLogsLoadedYet = true
-	templateCommonData.AvailableLogsArchives = availableLogs
 	err = tmpl.Execute(w, struct {
 		CommonWebData
 		ServerWebData
 	}{
 		CommonWebData: templateCommonData,
 		ServerWebData: ServerWebData{
-			Server:                    servCfg.ServerTag,
-			ServerDisplayName:         servCfg.DisplayName,
-			SyntaxHighlightingRegexps: servCfg.SyntaxHighlightingRegexps,
-			LogsStyles:                *servCfg.styles,
+			Server:            servCfg.ServerTag,
+			ServerDisplayName: servCfg.DisplayName,
 		},
 	})
 	if doDebug {
@@ -438,16 +533,12 @@ func listArchivesHandler(w http.ResponseWriter, templateCommonData CommonWebData
 	}
 }
 
-func listDynamicArchivesHandler(w http.ResponseWriter, templateCommonData CommonWebData, servCfg DynamicServerConfig, serverId string) {
-	tmpl, err := parseTemplates(getFuncMapFor(servCfg.ServerTag, false, true, true), "archive", "navbar", "archive-loader", "common-scripts")
-	if err != nil {
-		handleTemplateError(w, http.StatusInternalServerError, err)
+func listDynamicArchivesHandler(w http.ResponseWriter, r *http.Request, urlPrefix string, templateCommonData CommonWebData, servCfg DynamicServerConfig, serverId string) {
+	if _, found, _ := getDynamicArchivesCatalog(servCfg, serverId); !found {
+		http.Redirect(w, r, urlPrefix+"/", http.StatusSeeOther)
 		return
 	}
-
-	logsDir := strings.ReplaceAll(servCfg.getArchivedLogsRootDir(), "%id%", serverId)
-	logsFilePattern := strings.ReplaceAll(servCfg.ArchivedLogsFilePattern, "%id%", serverId)
-	availableLogs, err := listArchivedLogFiles(logsDir, logsFilePattern)
+	tmpl, err := parseTemplates(getFuncMapFor(servCfg.ServerTag, false, true, true), "archive", "navbar", "archive-loader", "common-scripts")
 	if err != nil {
 		handleTemplateError(w, http.StatusInternalServerError, err)
 		return
@@ -456,18 +547,15 @@ func listDynamicArchivesHandler(w http.ResponseWriter, templateCommonData Common
 	templateCommonData.ExecDate = time.Now().Format("15:04:05")
 	templateCommonData.AreArchivedLogsAvailable = true
 	templateCommonData.NoLogsLoadedYet = true
-	templateCommonData.AvailableLogsArchives = availableLogs
 	err = tmpl.Execute(w, struct {
 		CommonWebData
 		ServerWebData
 	}{
 		CommonWebData: templateCommonData,
 		ServerWebData: ServerWebData{
-			Server:                    servCfg.ServerTag,
-			Instance:                  serverId,
-			ServerDisplayName:         servCfg.DisplayName,
-			SyntaxHighlightingRegexps: servCfg.SyntaxHighlightingRegexps,
-			LogsStyles:                *servCfg.styles,
+			Server:            servCfg.ServerTag,
+			Instance:          serverId,
+			ServerDisplayName: servCfg.DisplayName,
 		},
 	})
 	if doDebug {
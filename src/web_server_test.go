@@ -0,0 +1,134 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestArchivesConfig returns the config of a classic server and of a dynamic server with two instances: a, which has
+// a log file, and b, which only has archives. Their archives directories are next to a secret archive which must never be shown.
+func newTestArchivesConfig(t *testing.T) Config {
+	root := t.TempDir()
+	writeTestArchive(t, root, "secret.log.gz", "[10:00:00] secret")
+	for _, dir := range []string{"logs", "servers/a/logs", "servers/b/logs"} {
+		assert.NoError(t, os.MkdirAll(filepath.Join(root, dir), 0755))
+	}
+	writeTestArchive(t, filepath.Join(root, "logs"), "2026-10-16-1.log.gz", "[10:00:00] classic")
+	writeTestArchive(t, filepath.Join(root, "servers/a/logs"), "2026-10-16-1.log.gz", "[10:00:00] instance a")
+	writeTestArchive(t, filepath.Join(root, "servers/b/logs"), "2026-10-16-1.log.gz", "[10:00:00] instance b")
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "servers/a/latest.log"), []byte("[12:00:00] live\n"), 0644))
+
+	var config Config
+	config.Servers.Classic = []ClassicServerConfig{{
+		ServerConfig:              ServerConfig{ServerTag: "serv", DisplayName: "Server", parser: &logParser{}},
+		ArchivedLogsDirPath:       filepath.Join(root, "logs"),
+		ArchivedLogFilenameFormat: "*.log.gz",
+		archivesEnabled:           true,
+	}}
+	config.Servers.Dynamic = []DynamicServerConfig{{
+		ServerConfig:            ServerConfig{ServerTag: "dyn", DisplayName: "Instance %id%", parser: &logParser{}},
+		LogFilePattern:          filepath.Join(root, "servers/*/latest.log"),
+		logFileIdentifierRegexp: regexp.MustCompile(`servers/(?P<id>[^/]+)/latest\.log$`),
+		ArchivedLogsRootDir:     filepath.Join(root, "servers/%id%/logs"),
+		ArchivedLogsFilePattern: "*.log.gz",
+		archivesEnabled:         true,
+	}}
+	return config
+}
+
+func TestArchiveHandlersTraversal(t *testing.T) {
+	config := newTestArchivesConfig(t)
+	classic, dynamic := config.Servers.Classic[0], config.Servers.Dynamic[0]
+	// a bundle whose members try to escape the archives directory
+	var zipped bytes.Buffer
+	zipWriter := zip.NewWriter(&zipped)
+	for _, name := range []string{"../secret.log.gz", "../../secret.log.gz", "/etc/passwd", "x!/../../secret.log.gz", "ok.log"} {
+		writer, err := zipWriter.Create(name)
+		assert.NoError(t, err)
+		_, _ = writer.Write([]byte("[10:00:00] bundled " + name + "\n"))
+	}
+	assert.NoError(t, zipWriter.Close())
+	assert.NoError(t, os.WriteFile(filepath.Join(classic.ArchivedLogsDirPath, "2026-10-15-1.log.gz"), zipped.Bytes(), 0644))
+
+	view := func(handler handlerFunc, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+	archives, _, err := getServerArchives(config, "serv", "")
+	if !assert.NoError(t, err) {
+		return
+	}
+	entries, _ := archives.catalog.list()
+	if !assert.Len(t, entries, 2, "Only the members of the bundle with safe names should be listed") {
+		return
+	}
+	if entries[0].Name != "2026-10-16-1.log.gz" {
+		entries[0], entries[1] = entries[1], entries[0]
+	}
+	assert.Equal(t, "2026-10-15-1.log.gz/ok.log", entries[1].Name)
+	w := view(createArchiveHandlerFor("", classic, CommonWebData{}), "/archive/serv/"+entries[1].Id)
+	assert.Contains(t, w.Body.String(), "bundled ok.log")
+
+	w = view(createArchiveHandlerFor("", classic, CommonWebData{}), "/archive/serv/"+entries[0].Id)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "classic")
+	for _, id := range []string{
+		archiveId("../secret.log.gz"),
+		archiveId("2026-10-15-1.log.gz" + bundleMemberSeparator + "../secret.log.gz"),
+		archiveId("2026-10-15-1.log.gz" + bundleMemberSeparator + "../../secret.log.gz"),
+		url.QueryEscape(base64.StdEncoding.EncodeToString([]byte("/../secret.log.gz"))),
+		"..%2Fsecret.log.gz",
+		"secret.log.gz",
+	} {
+		w = view(createArchiveHandlerFor("", classic, CommonWebData{}), "/archive/serv/"+id)
+		assert.Equal(t, http.StatusSeeOther, w.Code, id)
+		assert.NotContains(t, w.Body.String(), "secret", "Only the archives listed by the catalog should be shown")
+	}
+
+	instanceArchives, _, err := getServerArchives(config, "dyn", "a")
+	if !assert.NoError(t, err) {
+		return
+	}
+	instanceEntries, _ := instanceArchives.catalog.list()
+	if !assert.Len(t, instanceEntries, 1) {
+		return
+	}
+	w = view(createDynamicArchiveHandlerFor("", dynamic, CommonWebData{}), "/dyn-archive/dyn/a/"+instanceEntries[0].Id)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "instance a")
+	for _, path := range []string{
+		"/dyn-archive/dyn/a/" + archiveId("../../../secret.log.gz"),
+		// c has neither a log file nor an archives directory, it is not an instance of the server
+		"/dyn-archive/dyn/c/" + instanceEntries[0].Id,
+		"/dyn-archive/dyn/c/",
+		"/dyn-archive/dyn/..%2F..%2F..%2Flogs/" + entries[0].Id,
+		"/dyn-archive/dyn/*/" + instanceEntries[0].Id,
+	} {
+		w = view(createDynamicArchiveHandlerFor("", dynamic, CommonWebData{}), path)
+		assert.Equal(t, http.StatusSeeOther, w.Code, path)
+		assert.NotContains(t, w.Body.String(), "classic", path)
+	}
+
+	for _, path := range []string{
+		"/download/serv/" + archiveId("../secret.log.gz"),
+		"/download/serv/" + archiveId("2026-10-15-1.log.gz"+bundleMemberSeparator+"../secret.log.gz"),
+		"/download/serv?archive=" + archiveId("../secret.log.gz"),
+		"/download/dyn/" + entries[0].Id + "?instance=" + url.QueryEscape("../../../logs"),
+	} {
+		w = httptest.NewRecorder()
+		downloadHandler(w, httptest.NewRequest(http.MethodGet, path, nil), config)
+		assert.Equal(t, http.StatusNotFound, w.Code, path)
+		assert.NotContains(t, w.Body.String(), "secret", "Only the archives listed by the catalog should be downloaded")
+	}
+}
@@ -24,12 +24,12 @@ func TestWebSocket(t *testing.T) {
 
 	outputChannel := make(chan Event, 16)
 
-	hub := newHub()
-	hub.clientsByServer[serverTag] = []*Client{}
+	hub := newHub(policyDisconnect)
 	go hub.run(outputChannel)
+	hub.addServer(serverTag)
 
 	logFile, logQueue := newLogFile(serverTag, t)
-	go unstack(serverTag, logQueue, outputChannel)
+	go unstack(serverTag, nil, logQueue, outputChannel)
 
 	muxServer := http.NewServeMux()
 	muxServer.HandleFunc("/ws", hub.serveWs)
@@ -77,29 +77,38 @@ func TestWebSocket(t *testing.T) {
 	}
 
 	wsClient.OnTextMessage = func(message string, ws gowebsocket.Socket) {
-		message = strings.TrimSuffix(message, string(messageSeparator))
-		decodedMessage := make([]byte, base64.StdEncoding.DecodedLen(len(message)))
-		n, err := base64.StdEncoding.Decode(decodedMessage, []byte(message))
-		if err != nil {
-			t.Error("Failed to decode received event:", err)
-			exitChan <- struct{}{}
-			<-expectedLogLinesChan // releasing the value to unblock the channel
-			return
-		}
+		// several events can be sent in the same message
+		for _, data := range strings.Split(message, string(messageSeparator)) {
+			data = strings.TrimSpace(data)
+			if data == "" {
+				continue
+			}
+			decodedMessage := make([]byte, base64.StdEncoding.DecodedLen(len(data)))
+			n, err := base64.StdEncoding.Decode(decodedMessage, []byte(data))
+			if err != nil {
+				t.Error("Failed to decode received event:", err)
+				exitChan <- struct{}{}
+				<-expectedLogLinesChan // releasing the value to unblock the channel
+				return
+			}
 
-		var receivedEvt Event
-		err = json.Unmarshal(decodedMessage[:n], &receivedEvt)
-		if err != nil {
-			t.Error("Failed to unmarshal received event:", err)
-			exitChan <- struct{}{}
-			<-expectedLogLinesChan // releasing the value to unblock the channel
-			return
-		}
+			var receivedEvt Event
+			err = json.Unmarshal(decodedMessage[:n], &receivedEvt)
+			if err != nil {
+				t.Error("Failed to unmarshal received event:", err)
+				exitChan <- struct{}{}
+				<-expectedLogLinesChan // releasing the value to unblock the channel
+				return
+			}
+			if receivedEvt.Type == eventViewers {
+				continue // the viewers are sent whenever a client subscribes
+			}
 
-		assert.Equal(t, eventAdd, receivedEvt.Type, "Incorrect event type.")
-		assert.Equal(t, serverTag, receivedEvt.Server, "Incorrect event server.")
-		assert.Equal(t, <-expectedLogLinesChan, receivedEvt.Content, "Incorrect event content.")
-		assert.Equal(t, "", receivedEvt.Message, "Incorrect event message.")
+			assert.Equal(t, eventAdd, receivedEvt.Type, "Incorrect event type.")
+			assert.Equal(t, serverTag, receivedEvt.Server, "Incorrect event server.")
+			assert.Equal(t, <-expectedLogLinesChan, receivedEvt.Content, "Incorrect event content.")
+			assert.Equal(t, "", receivedEvt.Message, "Incorrect event message.")
+		}
 	}
 
 	wsClient.OnBinaryMessage = func(data []byte, ws gowebsocket.Socket) {